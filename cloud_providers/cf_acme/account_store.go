@@ -0,0 +1,176 @@
+package cf_acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/registration"
+)
+
+const (
+	accountFileName    = "account.json"
+	accountKeyFileName = "account.key"
+)
+
+// ErrAccountNotFound is returned by AccountStore.Load when no account has been saved
+// for the requested CA directory URL and email.
+var ErrAccountNotFound = errors.New("acme account not found in account store")
+
+type AccountStore struct {
+	RootDir string `json:"rootDir"`
+}
+
+type storedAccount struct {
+	Email        string                 `json:"email"`
+	CADirURL     string                 `json:"caDirUrl"`
+	Registration *registration.Resource `json:"registration"`
+}
+
+// DefaultAccountStoreDir returns ACME_ACCOUNT_DIR if set, otherwise goinfra/acme-accounts
+// under the user's config directory.
+func DefaultAccountStoreDir() string {
+	if dir := os.Getenv("ACME_ACCOUNT_DIR"); dir != "" {
+		return dir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".goinfra", "acme-accounts")
+	}
+	return filepath.Join(configDir, "goinfra", "acme-accounts")
+}
+
+func NewAccountStore(rootDir string) *AccountStore {
+	if rootDir == "" {
+		rootDir = DefaultAccountStoreDir()
+	}
+	return &AccountStore{RootDir: rootDir}
+}
+
+// AccountDir returns the directory holding the account for a CA directory URL and email.
+// Accounts are keyed by CA host and path so staging and production never share a key.
+func (s *AccountStore) AccountDir(caDirURL string, email string) string {
+	caKey := caDirURL
+	if u, err := url.Parse(caDirURL); err == nil && u.Host != "" {
+		caKey = u.Host + u.Path
+	}
+	replacer := strings.NewReplacer("/", "_", ":", "_", "\\", "_")
+	caKey = strings.Trim(replacer.Replace(caKey), "_")
+	if email == "" {
+		email = "noemail"
+	}
+	return filepath.Join(s.RootDir, caKey, replacer.Replace(email))
+}
+
+// Load reads the stored account key and registration. If a key exists but registration
+// never completed, the returned user has a key and a nil Registration.
+func (s *AccountStore) Load(caDirURL string, email string) (*AcmeUser, error) {
+	dir := s.AccountDir(caDirURL, email)
+	keyBytes, err := os.ReadFile(filepath.Join(dir, accountKeyFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		slog.Error("error reading acme account key", slog.String("dir", dir), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	key, err := certcrypto.ParsePEMPrivateKey(keyBytes)
+	if err != nil {
+		slog.Error("error parsing acme account key", slog.String("dir", dir), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	acmeUser := &AcmeUser{Email: email, key: key}
+
+	accountBytes, err := os.ReadFile(filepath.Join(dir, accountFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return acmeUser, nil
+	}
+	if err != nil {
+		slog.Error("error reading acme account", slog.String("dir", dir), slog.String("error", err.Error()))
+		return acmeUser, err
+	}
+
+	account := &storedAccount{}
+	err = json.Unmarshal(accountBytes, account)
+	if err != nil {
+		slog.Error("error parsing acme account", slog.String("dir", dir), slog.String("error", err.Error()))
+		return acmeUser, err
+	}
+	acmeUser.Registration = account.Registration
+
+	return acmeUser, nil
+}
+
+// LoadOrNew loads the stored account, or returns a user with a freshly generated
+// P-256 key when none exists. The new key is not persisted until Save is called.
+func (s *AccountStore) LoadOrNew(caDirURL string, email string) (*AcmeUser, error) {
+	acmeUser, err := s.Load(caDirURL, email)
+	if err == nil {
+		slog.Info("loaded acme account from store", slog.String("email", email), slog.String("dir", s.AccountDir(caDirURL, email)))
+		return acmeUser, nil
+	}
+	if !errors.Is(err, ErrAccountNotFound) {
+		return nil, err
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		slog.Error("error creating private key", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return &AcmeUser{Email: email, key: privateKey}, nil
+}
+
+// Save writes the account key and registration with owner-only permissions.
+func (s *AccountStore) Save(caDirURL string, acmeUser *AcmeUser) error {
+	dir := s.AccountDir(caDirURL, acmeUser.Email)
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		slog.Error("error creating acme account dir", slog.String("dir", dir), slog.String("error", err.Error()))
+		return err
+	}
+
+	keyBlock := certcrypto.PEMBlock(acmeUser.key)
+	if keyBlock == nil {
+		return fmt.Errorf("unsupported acme account key type %T", acmeUser.key)
+	}
+	err = os.WriteFile(filepath.Join(dir, accountKeyFileName), pem.EncodeToMemory(keyBlock), 0o600)
+	if err != nil {
+		slog.Error("error writing acme account key", slog.String("dir", dir), slog.String("error", err.Error()))
+		return err
+	}
+
+	account := storedAccount{Email: acmeUser.Email, CADirURL: caDirURL, Registration: acmeUser.Registration}
+	accountBytes, err := json.MarshalIndent(account, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(dir, accountFileName), accountBytes, 0o600)
+	if err != nil {
+		slog.Error("error writing acme account", slog.String("dir", dir), slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// Remove deletes the stored account for a CA directory URL and email.
+func (s *AccountStore) Remove(caDirURL string, email string) error {
+	return os.RemoveAll(s.AccountDir(caDirURL, email))
+}
+
+func (u *AcmeUser) SetPrivateKey(key crypto.PrivateKey) {
+	u.key = key
+}
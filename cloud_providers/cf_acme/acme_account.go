@@ -0,0 +1,137 @@
+package cf_acme
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-jose/go-jose/v4"
+)
+
+func (a *AcmeAccountRequest) store() *AccountStore {
+	return NewAccountStore(a.AccountDir)
+}
+
+func (a *AcmeAccountRequest) loadRegisteredAccount() (*lego.Client, *AcmeUser, error) {
+	acmeUser, err := a.store().Load(a.AcmeUrl, a.AcmeEmail)
+	if err != nil {
+		slog.Error("error loading acme account", slog.String("email", a.AcmeEmail), slog.String("acmeUrl", a.AcmeUrl), slog.String("error", err.Error()))
+		return nil, acmeUser, err
+	}
+	if acmeUser.Registration == nil {
+		return nil, acmeUser, fmt.Errorf("acme account for %s has a key but no registration", a.AcmeEmail)
+	}
+
+	config := lego.NewConfig(acmeUser)
+	config.CADirURL = a.AcmeUrl
+	client, err := lego.NewClient(config)
+	if err != nil {
+		slog.Error("error creating client", slog.String("error", err.Error()))
+	}
+	return client, acmeUser, err
+}
+
+func (a *AcmeAccountRequest) accountInfo(acmeUser *AcmeUser) AcmeAccountInfo {
+	info := AcmeAccountInfo{
+		Email:        acmeUser.Email,
+		AcmeUrl:      a.AcmeUrl,
+		AccountDir:   a.store().AccountDir(a.AcmeUrl, a.AcmeEmail),
+		KeyType:      fmt.Sprintf("%T", acmeUser.key),
+		Registration: acmeUser.Registration,
+	}
+	if signer, ok := acmeUser.key.(crypto.Signer); ok {
+		jwk := jose.JSONWebKey{Key: signer.Public()}
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err == nil {
+			info.KeyThumbprint = base64.RawURLEncoding.EncodeToString(thumbprint)
+		}
+	}
+	return info
+}
+
+// CliShow prints the stored account. When query is true the registration is refreshed from the CA.
+func (a *AcmeAccountRequest) CliShow(query bool) (AcmeAccountInfo, error) {
+	if !query {
+		acmeUser, err := a.store().Load(a.AcmeUrl, a.AcmeEmail)
+		if err != nil {
+			slog.Error("error loading acme account", slog.String("email", a.AcmeEmail), slog.String("error", err.Error()))
+			return AcmeAccountInfo{}, err
+		}
+		info := a.accountInfo(acmeUser)
+		printJson(info)
+		return info, nil
+	}
+
+	client, acmeUser, err := a.loadRegisteredAccount()
+	if err != nil {
+		return AcmeAccountInfo{}, err
+	}
+	reg, err := client.Registration.QueryRegistration()
+	if err != nil {
+		slog.Error("error querying acme registration", slog.String("error", err.Error()))
+		return a.accountInfo(acmeUser), err
+	}
+	acmeUser.Registration = reg
+	err = a.store().Save(a.AcmeUrl, acmeUser)
+
+	info := a.accountInfo(acmeUser)
+	printJson(info)
+	return info, err
+}
+
+// CliDeactivate deactivates the account at the CA and removes it from the store.
+// Deactivation is permanent, the next renewal registers a new account.
+func (a *AcmeAccountRequest) CliDeactivate() error {
+	client, _, err := a.loadRegisteredAccount()
+	if err != nil {
+		return err
+	}
+	err = client.Registration.DeleteRegistration()
+	if err != nil {
+		slog.Error("error deactivating acme account", slog.String("email", a.AcmeEmail), slog.String("error", err.Error()))
+		return err
+	}
+
+	err = a.store().Remove(a.AcmeUrl, a.AcmeEmail)
+	if err != nil {
+		slog.Error("error removing acme account from store", slog.String("error", err.Error()))
+		return err
+	}
+	slog.Info("acme account deactivated", slog.String("email", a.AcmeEmail), slog.String("acmeUrl", a.AcmeUrl))
+	return nil
+}
+
+// CliRotateKey rolls the account over to a newly generated key of keyType and saves it.
+func (a *AcmeAccountRequest) CliRotateKey(keyType certcrypto.KeyType) (AcmeAccountInfo, error) {
+	_, acmeUser, err := a.loadRegisteredAccount()
+	if err != nil {
+		return AcmeAccountInfo{}, err
+	}
+
+	newKey, err := certcrypto.GeneratePrivateKey(keyType)
+	if err != nil {
+		slog.Error("error generating new account key", slog.String("keyType", string(keyType)), slog.String("error", err.Error()))
+		return AcmeAccountInfo{}, err
+	}
+	signer, ok := newKey.(crypto.Signer)
+	if !ok {
+		return AcmeAccountInfo{}, fmt.Errorf("unsupported acme account key type %T", newKey)
+	}
+
+	err = acmeUser.RolloverAccountKey(a.AcmeUrl, signer)
+	if err != nil {
+		slog.Error("error rotating acme account key", slog.String("email", a.AcmeEmail), slog.String("error", err.Error()))
+		return a.accountInfo(acmeUser), err
+	}
+
+	err = a.store().Save(a.AcmeUrl, acmeUser)
+	if err != nil {
+		slog.Error("account key was rotated at the CA but could not be saved", slog.String("error", err.Error()))
+	}
+	info := a.accountInfo(acmeUser)
+	printJson(info)
+	return info, err
+}
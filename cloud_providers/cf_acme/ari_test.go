@@ -0,0 +1,152 @@
+package cf_acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
+)
+
+// writeForeignCert writes a certificate for domain issued by a throwaway CA, so the ACME server
+// has never seen it and its renewalInfo lookup fails. The key follows the certificate in the file.
+func writeForeignCert(t *testing.T, domain string, validFor time.Duration) string {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "foreign test ca"},
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(leafKey)
+	path := filepath.Join(t.TempDir(), "certificate.pem")
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})...)
+	os.WriteFile(path, data, 0o600)
+	return path
+}
+
+// obtainTestCertificate issues a certificate for c with lego's webroot http-01 provider, which
+// only passes validation when Pebble runs with AlwaysValid.
+func obtainTestCertificate(t *testing.T, c *CertificateRenewalRequest) *certificate.Resource {
+	t.Helper()
+	client, acmeUser, err := c.NewAcmeClient()
+	if err != nil {
+		t.Fatalf("NewAcmeClient: %v", err)
+	}
+	if err := c.EnsureRegistration(client, acmeUser); err != nil {
+		t.Fatalf("EnsureRegistration: %v", err)
+	}
+	provider, err := webroot.NewHTTPProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Challenge.SetHTTP01Provider(provider); err != nil {
+		t.Fatal(err)
+	}
+	issued, err := client.Certificate.Obtain(certificate.ObtainRequest{Domains: c.DomainNames, Bundle: true})
+	if err != nil {
+		t.Fatalf("Obtain: %v", err)
+	}
+	return issued
+}
+
+func TestRenewUsesARIWindow(t *testing.T) {
+	srv := startPebble(t, pebbleOptions{AlwaysValid: true})
+	first := newPebbleRequest(t, srv, "ari.example.com")
+	issued := obtainTestCertificate(t, first)
+	existingDir := t.TempDir()
+	os.WriteFile(filepath.Join(existingDir, "certificate.pem"), append(issued.Certificate, issued.PrivateKey...), 0o600)
+
+	c := newPebbleRequest(t, srv, "ari.example.com")
+	c.AccountDir = first.AccountDir
+	c.ExistingCertSource = ExistingCertSourcePemDir
+	c.ExistingCertPath = existingDir
+	c.RenewBefore = 30 * 24 * time.Hour
+	c.UseARI = true
+	if _, err := c.CheckRenewalWindow(); err != nil {
+		t.Fatalf("CheckRenewalWindow: %v", err)
+	}
+	certData, err := c.Renew("", nil, 0)
+	if !errors.Is(err, ErrRenewalNotDue) {
+		t.Fatalf("expected ErrRenewalNotDue for a fresh certificate, got %v", err)
+	}
+	ari := certData.RenewalCheck.Ari
+	if ari == nil || !ari.Supported || ari.Due || ari.WindowStart.IsZero() {
+		t.Fatalf("expected a supported ARI window that has not started, got %+v", ari)
+	}
+}
+
+func TestRenewFallsBackToRenewBeforeOnARIError(t *testing.T) {
+	srv := startPebble(t, pebbleOptions{AlwaysValid: true})
+	tests := []struct {
+		name     string
+		validFor time.Duration
+		wantDue  bool
+	}{
+		{name: "not due", validFor: 60 * 24 * time.Hour, wantDue: false},
+		{name: "due", validFor: 10 * 24 * time.Hour, wantDue: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPebbleRequest(t, srv, "fallback.example.com")
+			c.ExistingCertSource = ExistingCertSourcePemDir
+			c.ExistingCertPath = filepath.Dir(writeForeignCert(t, "fallback.example.com", tt.validFor))
+			c.RenewBefore = 30 * 24 * time.Hour
+			c.UseARI = true
+			if _, err := c.CheckRenewalWindow(); err != nil {
+				t.Fatalf("CheckRenewalWindow: %v", err)
+			}
+			client, acmeUser, err := c.NewAcmeClient()
+			if err != nil {
+				t.Fatalf("NewAcmeClient: %v", err)
+			}
+			if err := c.EnsureRegistration(client, acmeUser); err != nil {
+				t.Fatalf("EnsureRegistration: %v", err)
+			}
+
+			ari, err := c.CheckARI(client)
+			if err != nil {
+				t.Fatalf("CheckARI: %v", err)
+			}
+			if ari.Supported || ari.Error == "" {
+				t.Fatalf("expected an unsupported ARI result with the query error, got %+v", ari)
+			}
+			c.renewalCheck.Ari = ari
+			if c.renewalDue() != tt.wantDue {
+				t.Fatalf("expected due %t from the renew-before fallback, got %t", tt.wantDue, c.renewalDue())
+			}
+			if !tt.wantDue {
+				if _, err := c.Renew("", nil, 0); !errors.Is(err, ErrRenewalNotDue) {
+					t.Fatalf("expected Renew to skip the certificate, got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -22,14 +22,14 @@ func GetCloudflareZoneIdFromDomainName(token string, domainName string) (string,
 		return "", err
 	}
 
-	zoneID, err := api.ZoneIDByName(getRootDomain(domainName))
+	match, err := ZoneResolverForApi(api).Resolve(domainName)
 	if err != nil {
 		msg := fmt.Sprintf("Error retrieving ZoneId for Domain: %s error: %s", domainName, err.Error())
 		slog.Error(msg)
-		return zoneID, err
+		return match.ZoneID, err
 	}
 
-	return zoneID, err
+	return match.ZoneID, err
 }
 
 func CheckRecordNameExists(token string, zoneId string, domainName string) (RecordQueryResult, error) {
@@ -4,9 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -15,15 +13,19 @@ import (
 
 	"github.com/babbage88/go-acme-cli/internal/pretty"
 	"github.com/babbage88/go-acme-cli/storage/goinfra_minio"
-	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
 	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
 	"github.com/joho/godotenv"
 )
 
-type InfraDnsChallengeSolver interface{}
+// InfraDnsChallengeSolver is a DNS-01 challenge provider that also sets its own propagation timeout.
+type InfraDnsChallengeSolver interface {
+	challenge.Provider
+	Timeout() (timeout, interval time.Duration)
+}
 
 func (u *AcmeUser) GetEmail() string {
 	return u.Email
@@ -37,30 +39,105 @@ func (u *AcmeUser) GetPrivateKey() crypto.PrivateKey {
 	return u.key
 }
 
-func (c *CertificateRenewalRequest) InitialzeClientandPovider(token string, recursiveNameServers []string, timeout time.Duration) (*lego.Client, *AcmeUser, error) {
-	// Create a user. New accounts need an email and private key to start.
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+// NewAcmeClient loads the stored ACME account for AcmeUrl and AcmeEmail, or creates a new
+// unregistered one, and returns a lego client acting on its behalf.
+func (c *CertificateRenewalRequest) NewAcmeClient() (*lego.Client, *AcmeUser, error) {
+	acmeUser, err := NewAccountStore(c.AccountDir).LoadOrNew(c.AcmeUrl, c.AcmeEmail)
 	if err != nil {
-		slog.Error("error creating private key", slog.String("error", err.Error()))
+		slog.Error("error loading acme account", slog.String("error", err.Error()))
 		return &lego.Client{}, &AcmeUser{}, err
 	}
 
-	acmeUser := AcmeUser{
-		Email: c.AcmeEmail,
-		key:   privateKey,
-	}
-
-	config := lego.NewConfig(&acmeUser)
+	config := lego.NewConfig(acmeUser)
 
 	// This CA URL is configured for a local dev instance of Boulder running in Docker in a VM.
 	config.CADirURL = c.AcmeUrl
-	config.Certificate.KeyType = certcrypto.RSA2048
+	config.Certificate.KeyType = c.certKeyType()
 
 	// A client facilitates communication with the CA server.
 	client, err := lego.NewClient(config)
 	if err != nil {
 		slog.Error("error creating client", slog.String("error", err.Error()))
-		return &lego.Client{}, &acmeUser, err
+		return &lego.Client{}, acmeUser, err
+	}
+	return client, acmeUser, err
+}
+
+// EnsureRegistration registers the account only when the store has no registration for it,
+// then saves the key and registration so later runs reuse the same ACME account.
+func (c *CertificateRenewalRequest) EnsureRegistration(client *lego.Client, acmeUser *AcmeUser) error {
+	if acmeUser.Registration != nil {
+		return nil
+	}
+
+	// Save the key before registering so an account the CA creates is not lost if this run fails
+	// before the registration is saved.
+	store := NewAccountStore(c.AccountDir)
+	err := store.Save(c.AcmeUrl, acmeUser)
+	if err != nil {
+		slog.Error("error saving acme account key", slog.String("error", err.Error()))
+		return err
+	}
+
+	// A saved key without a registration means a previous run failed part way, try to recover the account first.
+	reg, err := client.Registration.ResolveAccountByKey()
+	if err != nil {
+		reg, err = c.register(client)
+		if err != nil {
+			slog.Error("Error creating registration", slog.String("error", err.Error()))
+			return err
+		}
+	}
+	acmeUser.Registration = reg
+
+	err = store.Save(c.AcmeUrl, acmeUser)
+	if err != nil {
+		slog.Error("error saving acme account", slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// register creates the ACME account, binding it to an external account when EAB credentials are set.
+func (c *CertificateRenewalRequest) register(client *lego.Client) (*registration.Resource, error) {
+	if c.EabKid != "" || c.EabHmac != "" {
+		if c.EabKid == "" || c.EabHmac == "" {
+			return nil, fmt.Errorf("both an EAB key id and HMAC key are required for external account binding")
+		}
+		slog.Info("registering acme account with external account binding", slog.String("eabKid", c.EabKid), slog.String("acmeUrl", c.AcmeUrl))
+		return client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: true,
+			Kid:                  c.EabKid,
+			HmacEncoded:          c.EabHmac,
+		})
+	}
+	if client.GetExternalAccountRequired() {
+		return nil, fmt.Errorf("acme server %s requires external account binding, set --eab-kid and --eab-hmac", c.AcmeUrl)
+	}
+	return client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+}
+
+func (c *CertificateRenewalRequest) InitialzeClientandPovider(token string, recursiveNameServers []string, timeout time.Duration) (*lego.Client, *AcmeUser, error) {
+	client, acmeUser, err := c.NewAcmeClient()
+	if err != nil {
+		return client, acmeUser, err
+	}
+
+	switch c.Challenge {
+	case "", ChallengeDns01:
+	case ChallengeHttp01:
+		err = c.setHttpProvider(client)
+		if err != nil {
+			return &lego.Client{}, acmeUser, err
+		}
+		return client, acmeUser, err
+	case ChallengeTlsAlpn01:
+		err = c.setTlsAlpnProvider(client)
+		if err != nil {
+			return &lego.Client{}, acmeUser, err
+		}
+		return client, acmeUser, err
+	default:
+		return &lego.Client{}, acmeUser, fmt.Errorf("unsupported challenge type %q, use dns-01, http-01 or tls-alpn-01", c.Challenge)
 	}
 	/*
 		provider, err := lego_cloudflare.NewDNSProviderConfig(&lego_cloudflare.Config{
@@ -70,19 +147,44 @@ func (c *CertificateRenewalRequest) InitialzeClientandPovider(token string, recu
 			PropagationTimeout: timeout,
 		})
 	*/
-	provider, err := NewInfraCfCustomDNSProvider(token, token, recursiveNameServers, timeout)
+	provider, err := c.newDnsChallengeSolver(token, recursiveNameServers, timeout)
 	if err != nil {
-		slog.Error("error initializing cloudflare DNS challenge provider", slog.String("error", err.Error()))
-		return &lego.Client{}, &acmeUser, err
+		return &lego.Client{}, acmeUser, err
 	}
 	recursiveServersOption := dns01.AddRecursiveNameservers(recursiveNameServers)
 	timeoutOption := dns01.AddDNSTimeout(timeout)
 	err = client.Challenge.SetDNS01Provider(provider, recursiveServersOption, timeoutOption)
 	if err != nil {
 		slog.Error("Failed to set DNS challenge.", slog.String("error", err.Error()))
-		return &lego.Client{}, &acmeUser, err
+		return &lego.Client{}, acmeUser, err
 	}
-	return client, &acmeUser, err
+	return client, acmeUser, err
+}
+
+// newDnsChallengeSolver returns the Cloudflare solver, or the generic solver for any other
+// DnsProvider or per zone routes. Both write into the delegated zones of ChallengeZoneTokens
+// with the zone's own token.
+func (c *CertificateRenewalRequest) newDnsChallengeSolver(token string, recursiveNameServers []string, timeout time.Duration) (InfraDnsChallengeSolver, error) {
+	if (c.DnsProvider == "" || c.DnsProvider == DnsProviderCloudflare) && !ZoneRoutesConfigured() {
+		provider, err := NewInfraCfCustomDNSProvider(token, token, recursiveNameServers, timeout)
+		if err != nil {
+			slog.Error("error initializing cloudflare DNS challenge provider", slog.String("error", err.Error()))
+			return nil, err
+		}
+		provider.DelegatedZoneTokens = c.ChallengeZoneTokens
+		return provider, nil
+	}
+
+	dnsProvider, err := NewDnsProviderFromConfig(c.DnsProvider, token)
+	if err != nil {
+		slog.Error("error initializing DNS challenge provider", slog.String("provider", c.DnsProvider), slog.String("error", err.Error()))
+		return nil, err
+	}
+	dnsProvider, err = withDelegatedZoneTokens(dnsProvider, c.ChallengeZoneTokens)
+	if err != nil {
+		return nil, err
+	}
+	return NewProviderDNSChallenge(dnsProvider, recursiveNameServers, timeout), nil
 }
 
 func (c *CertificateRenewalRequest) RenewCertWithDnsFromEnv() (CertificateData, error) {
@@ -96,14 +198,11 @@ func (c *CertificateRenewalRequest) RenewCertWithDnsFromEnv() (CertificateData,
 		return *certdata, err
 	}
 	// New users will need to register
-	reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	err = c.EnsureRegistration(client, acmeUser)
 	if err != nil {
-		slog.Error("Error creating registration", slog.String("error", err.Error()))
 		return *certdata, err
 	}
 
-	acmeUser.Registration = reg
-
 	request := certificate.ObtainRequest{
 		Domains: c.DomainNames,
 		Bundle:  true,
@@ -133,7 +232,31 @@ func (c *CertificateRenewalRequest) CliRenewal() (CertificateData, error) {
 		return CertificateData{DomainNames: c.DomainNames}, err
 	}
 
+	if c.ExistingCertSource != "" {
+		renewalCheck, err := c.CheckRenewalWindow()
+		if err != nil {
+			slog.Error("error checking existing certificate", slog.String("error", err.Error()))
+			return CertificateData{DomainNames: c.DomainNames}, err
+		}
+		// With ARI the CA's suggested window is checked in Renew once the client exists.
+		if !c.UseARI && !c.renewalDue() {
+			certData := CertificateData{DomainNames: c.DomainNames, ZipDir: c.ZipDir, RenewalCheck: renewalCheck}
+			printJson(certData)
+			pretty.Printf("Certificate not due for renewal, expires %s", pretty.DateTimeSting(renewalCheck.NotAfter))
+			return certData, nil
+		}
+	}
+
 	certData, err := c.Renew(c.Token, c.RecursiveNameServers, c.Timeout)
+	if errors.Is(err, ErrRenewalNotDue) {
+		printJson(certData)
+		if ari := certData.RenewalCheck.Ari; ari != nil && ari.Supported {
+			pretty.Printf("Certificate not due for renewal, CA suggested window starts %s", pretty.DateTimeSting(ari.WindowStart))
+		} else {
+			pretty.Printf("Certificate not due for renewal, expires %s (renew-before %s)", pretty.DateTimeSting(certData.RenewalCheck.NotAfter), certData.RenewalCheck.RenewBefore)
+		}
+		return certData, nil
+	}
 	if err != nil {
 		slog.Error("error renewing certificate", slog.String("error", err.Error()))
 		return CertificateData{DomainNames: c.DomainNames}, err
@@ -158,7 +281,41 @@ func (c *CertificateRenewalRequest) CliRenewal() (CertificateData, error) {
 	return certData, err
 }
 
+// ExistingCertLocation returns ExistingCertPath, defaulting to the zip name used by SaveZip and PushS3.
+func (c *CertificateRenewalRequest) ExistingCertLocation() string {
+	if c.ExistingCertPath != "" {
+		return c.ExistingCertPath
+	}
+	return c.ZipDir
+}
+
+// CheckRenewalWindow loads the existing certificate and compares its NotAfter to RenewBefore.
+// A missing local certificate is treated as due so the first run issues one.
+func (c *CertificateRenewalRequest) CheckRenewalWindow() (*RenewalCheckResult, error) {
+	location := c.ExistingCertLocation()
+	existing, err := LoadExistingCertificate(c.ExistingCertSource, location)
+	if errors.Is(err, os.ErrNotExist) {
+		c.renewalCheck = &RenewalCheckResult{Source: c.ExistingCertSource, Location: location, Due: true, Forced: c.Force, Reason: "no existing certificate found"}
+		return c.renewalCheck, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := existing.CheckRenewalWindow(c.RenewBefore, time.Now())
+	result.Forced = c.Force
+	slog.Info("checked existing certificate", slog.String("notAfter", result.NotAfter.String()), slog.Bool("due", result.Due), slog.Bool("force", c.Force))
+	c.existing = existing
+	c.renewalCheck = &result
+	return c.renewalCheck, nil
+}
+
 func (c *CertificateRenewalRequest) Renew(token string, recursiveNameservers []string, timeout time.Duration) (CertificateData, error) {
+	err := c.loadCSR()
+	if err != nil {
+		return CertificateData{}, err
+	}
+
 	client, acmeUser, err := c.InitialzeClientandPovider(token, recursiveNameservers, timeout)
 	if err != nil {
 		slog.Error("error initializing ACME client", slog.String("error", err.Error()))
@@ -166,19 +323,46 @@ func (c *CertificateRenewalRequest) Renew(token string, recursiveNameservers []s
 	}
 
 	// New users will need to register
-	reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	err = c.EnsureRegistration(client, acmeUser)
 	if err != nil {
-		slog.Error("Error creating registration", slog.String("error", err.Error()))
 		return CertificateData{}, err
 	}
 
-	acmeUser.Registration = reg
-
+	privateKey, err := c.reusablePrivateKey()
+	if err != nil {
+		return CertificateData{}, err
+	}
 	request := certificate.ObtainRequest{
-		Domains: c.DomainNames,
-		Bundle:  true,
+		Domains:    c.DomainNames,
+		Bundle:     true,
+		PrivateKey: privateKey,
+	}
+
+	if c.UseARI && c.existing != nil {
+		ari, err := c.CheckARI(client)
+		if err != nil {
+			return CertificateData{DomainNames: c.DomainNames, RenewalCheck: c.renewalCheck}, err
+		}
+		c.renewalCheck.Ari = ari
+		if !c.renewalDue() {
+			return CertificateData{DomainNames: c.DomainNames, ZipDir: c.ZipDir, RenewalCheck: c.renewalCheck}, ErrRenewalNotDue
+		}
+		if ari.Supported {
+			request.ReplacesCertID = ari.CertID
+		}
+	}
+
+	var certificates *certificate.Resource
+	if c.csr != nil {
+		// The private key stays with whoever generated the CSR, so certificates.PrivateKey is empty.
+		certificates, err = client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{
+			CSR:            c.csr,
+			Bundle:         true,
+			ReplacesCertID: request.ReplacesCertID,
+		})
+	} else {
+		certificates, err = client.Certificate.Obtain(request)
 	}
-	certificates, err := client.Certificate.Obtain(request)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -195,6 +379,7 @@ func (c *CertificateRenewalRequest) Renew(token string, recursiveNameservers []s
 		Fullchain:       fullChain,
 		FullchainAndKey: fmt.Sprint(fullChain, privKey),
 		ZipDir:          c.ZipDir,
+		RenewalCheck:    c.renewalCheck,
 	}
 
 	return certdata, err
@@ -0,0 +1,50 @@
+package cf_acme
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnsureRegistrationExternalAccountBinding(t *testing.T) {
+	const kid, hmac = "kid-1", "zWNDZM6eQGHWpSRTPal5eIUYFTu7EajVIoguysqZ9wG44nMEtx3MUAsUDkMTQ12W"
+	srv := startPebble(t, pebbleOptions{EabKeys: map[string]string{kid: hmac}})
+	tests := []struct {
+		name    string
+		kid     string
+		hmac    string
+		wantErr string
+	}{
+		{name: "binding", kid: kid, hmac: hmac},
+		{name: "missing binding", wantErr: "requires external account binding"},
+		{name: "missing hmac", kid: kid, wantErr: "both an EAB key id and HMAC key are required"},
+		{name: "unknown kid", kid: "kid-unknown", hmac: hmac, wantErr: "unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPebbleRequest(t, srv, "eab.example.com")
+			c.EabKid, c.EabHmac = tt.kid, tt.hmac
+			client, acmeUser, err := c.NewAcmeClient()
+			if err != nil {
+				t.Fatalf("NewAcmeClient: %v", err)
+			}
+			err = c.EnsureRegistration(client, acmeUser)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EnsureRegistration: %v", err)
+			}
+			if acmeUser.Registration == nil || acmeUser.Registration.URI == "" {
+				t.Fatal("expected the account to be registered")
+			}
+
+			stored, err := NewAccountStore(c.AccountDir).Load(c.AcmeUrl, c.AcmeEmail)
+			if err != nil || stored.Registration == nil || stored.Registration.URI != acmeUser.Registration.URI {
+				t.Fatalf("expected the registration to be saved, got %+v, %v", stored, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package cf_acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const DnsProviderCloudflare = "cloudflare"
+
+// CloudflareProvider is the Cloudflare implementation of dnsprovider.Provider.
+type CloudflareProvider struct {
+	Api *cloudflare.API
+}
+
+func NewCloudflareProvider(api *cloudflare.API) *CloudflareProvider {
+	return &CloudflareProvider{Api: api}
+}
+
+func NewCloudflareProviderFromToken(token string) (*CloudflareProvider, error) {
+	api, err := cloudflare.NewWithAPIToken(token)
+	if err != nil {
+		slog.Error("Error initializing cf api client. Verify token.")
+		return nil, err
+	}
+	return NewCloudflareProvider(api), nil
+}
+
+func (p *CloudflareProvider) Name() string {
+	return DnsProviderCloudflare
+}
+
+func (p *CloudflareProvider) ZoneForName(ctx context.Context, name string) (dnsprovider.Zone, error) {
+	match, err := ZoneResolverForApi(p.Api).Resolve(dnsprovider.TrimDot(name))
+	if err != nil {
+		return dnsprovider.Zone{}, err
+	}
+	return dnsprovider.Zone{ID: match.ZoneID, Name: match.ZoneName}, nil
+}
+
+// ListZones returns every zone the API token can read.
+func (p *CloudflareProvider) ListZones(ctx context.Context) ([]dnsprovider.ZoneInfo, error) {
+	zones, err := p.Api.ListZones(ctx)
+	if err != nil {
+		slog.Error("error listing cloudflare zones", slog.String("error", err.Error()))
+		return nil, err
+	}
+	infos := make([]dnsprovider.ZoneInfo, 0, len(zones))
+	for _, zone := range zones {
+		infos = append(infos, dnsprovider.ZoneInfo{
+			Zone:        dnsprovider.Zone{ID: zone.ID, Name: dnsprovider.TrimDot(zone.Name)},
+			Status:      zone.Status,
+			Plan:        zone.Plan.Name,
+			NameServers: zone.NameServers,
+		})
+	}
+	return infos, nil
+}
+
+// ListRecords fetches every page of the listing, see dnsprovider.FetchPages.
+func (p *CloudflareProvider) ListRecords(ctx context.Context, zone dnsprovider.Zone, filter dnsprovider.RecordFilter) ([]dnsprovider.Record, error) {
+	records := []dnsprovider.Record{}
+	_, err := dnsprovider.StreamRecords(ctx, p, zone, filter, dnsprovider.DefaultPerPage, dnsprovider.DefaultPageConcurrency, 0, func(page []dnsprovider.Record) error {
+		records = append(records, page...)
+		return nil
+	})
+	if err != nil {
+		slog.Error("error listing cloudflare dns records", slog.String("zoneId", zone.ID), slog.String("error", err.Error()))
+		return nil, err
+	}
+	return records, nil
+}
+
+func (p *CloudflareProvider) ListRecordsPage(ctx context.Context, zone dnsprovider.Zone, filter dnsprovider.RecordFilter, page int, perPage int) ([]dnsprovider.Record, dnsprovider.PageInfo, error) {
+	params := cloudflare.ListDNSRecordsParams{
+		Name:       dnsprovider.TrimDot(filter.Name),
+		Type:       filter.Type,
+		Content:    filter.Content,
+		Comment:    filter.Comment,
+		Tags:       filter.Tags,
+		Priority:   filter.Priority,
+		Proxied:    filter.Proxied,
+		ResultInfo: cloudflare.ResultInfo{Page: page, PerPage: perPage},
+	}
+	cfRecords, info, err := p.Api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zone.ID), params)
+	if err != nil {
+		return nil, dnsprovider.PageInfo{}, wrapRateLimit(err)
+	}
+	records := make([]dnsprovider.Record, 0, len(cfRecords))
+	for _, v := range cfRecords {
+		records = append(records, RecordFromCloudflare(zone, v))
+	}
+	return records, pageInfoFromCloudflare(info), nil
+}
+
+func pageInfoFromCloudflare(info *cloudflare.ResultInfo) dnsprovider.PageInfo {
+	if info == nil {
+		return dnsprovider.PageInfo{}
+	}
+	return dnsprovider.PageInfo{Page: info.Page, PerPage: info.PerPage, Count: info.Count, Total: info.Total, TotalPages: info.TotalPages}
+}
+
+// ListAllCloudflareDnsRecords returns the records of zoneID matching params. params.Page selects a
+// single page, otherwise every page is fetched with a bounded number of concurrent requests.
+func ListAllCloudflareDnsRecords(ctx context.Context, api *cloudflare.API, zoneID string, params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, *cloudflare.ResultInfo, error) {
+	if params.Page > 0 {
+		return api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), params)
+	}
+	records := []cloudflare.DNSRecord{}
+	fetch := func(ctx context.Context, page int, perPage int) ([]cloudflare.DNSRecord, dnsprovider.PageInfo, error) {
+		pageParams := params
+		pageParams.ResultInfo = cloudflare.ResultInfo{Page: page, PerPage: perPage}
+		found, info, err := api.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneID), pageParams)
+		return found, pageInfoFromCloudflare(info), err
+	}
+	info, err := dnsprovider.FetchPages(ctx, params.PerPage, dnsprovider.DefaultPageConcurrency, 0, fetch, func(page []cloudflare.DNSRecord) error {
+		records = append(records, page...)
+		return nil
+	})
+	return records, &cloudflare.ResultInfo{Page: 1, PerPage: info.PerPage, TotalPages: info.TotalPages, Count: len(records), Total: info.Total}, err
+}
+
+func (p *CloudflareProvider) GetRecord(ctx context.Context, zone dnsprovider.Zone, id string) (dnsprovider.Record, error) {
+	record, err := p.Api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(zone.ID), id)
+	if err != nil {
+		return dnsprovider.Record{}, wrapRateLimit(err)
+	}
+	return RecordFromCloudflare(zone, record), nil
+}
+
+func (p *CloudflareProvider) CreateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	params := cloudflare.CreateDNSRecordParams{
+		Name:     record.Name,
+		Type:     record.Type,
+		Content:  record.Content,
+		TTL:      record.TTL,
+		Priority: record.Priority,
+		Proxied:  record.Proxied,
+		Comment:  record.CommentText(),
+		Tags:     record.Tags,
+	}
+	created, err := p.Api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zone.ID), params)
+	if err != nil {
+		return dnsprovider.Record{}, wrapRateLimit(err)
+	}
+	return RecordFromCloudflare(zone, created), nil
+}
+
+func (p *CloudflareProvider) UpdateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	params := cloudflare.UpdateDNSRecordParams{
+		ID:       record.ID,
+		Name:     record.Name,
+		Type:     record.Type,
+		Content:  record.Content,
+		TTL:      record.TTL,
+		Priority: record.Priority,
+		Proxied:  record.Proxied,
+		Comment:  record.Comment,
+		Tags:     record.Tags,
+	}
+	updated, err := p.Api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zone.ID), params)
+	if err != nil {
+		return dnsprovider.Record{}, wrapRateLimit(err)
+	}
+	return RecordFromCloudflare(zone, updated), nil
+}
+
+func (p *CloudflareProvider) DeleteRecord(ctx context.Context, zone dnsprovider.Zone, id string) error {
+	slog.Info("Deleting Cloudflare DNS Record", slog.String("ZoneID", zone.ID), slog.String("RecordID", id))
+	return wrapRateLimit(p.Api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zone.ID), id))
+}
+
+// IsRateLimitError reports whether err is Cloudflare answering 429. The client retries 429s
+// itself and then returns a plain error, only a response it does not retry is a RatelimitError.
+func IsRateLimitError(err error) bool {
+	var rateLimit *cloudflare.RatelimitError
+	return err != nil && (errors.As(err, &rateLimit) || strings.Contains(err.Error(), "exceeded available rate limit retries"))
+}
+
+// wrapRateLimit wraps Cloudflare rate limit errors with dnsprovider.ErrRateLimited.
+func wrapRateLimit(err error) error {
+	if IsRateLimitError(err) {
+		return fmt.Errorf("%w: %w", dnsprovider.ErrRateLimited, err)
+	}
+	return err
+}
+
+func (p *CloudflareProvider) CreateTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string, ttl int) (dnsprovider.Record, error) {
+	return p.CreateRecord(ctx, zone, dnsprovider.Record{Name: dnsprovider.TrimDot(fqdn), Type: "TXT", Content: value, TTL: ttl})
+}
+
+func (p *CloudflareProvider) DeleteTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string) error {
+	records, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{Name: fqdn, Type: "TXT"})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if trimTxtQuotes(record.Content) != value {
+			continue
+		}
+		err = p.DeleteRecord(ctx, zone, record.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordFromCloudflare converts a Cloudflare record in zone to the provider neutral type.
+func RecordFromCloudflare(zone dnsprovider.Zone, record cloudflare.DNSRecord) dnsprovider.Record {
+	var comment *string
+	if record.Comment != "" {
+		comment = &record.Comment
+	}
+	return dnsprovider.Record{
+		ID:         record.ID,
+		ZoneID:     zone.ID,
+		ZoneName:   zone.Name,
+		Name:       record.Name,
+		Type:       record.Type,
+		Content:    record.Content,
+		TTL:        record.TTL,
+		Priority:   record.Priority,
+		Proxied:    record.Proxied,
+		Comment:    comment,
+		Tags:       record.Tags,
+		CreatedOn:  record.CreatedOn,
+		ModifiedOn: record.ModifiedOn,
+		Native:     record,
+	}
+}
+
+// CloudflareRecord converts a provider neutral record to the Cloudflare type the dns command
+// tables, json output and sqlite inventory are built around. Records read from Cloudflare are
+// returned as the API sent them.
+func CloudflareRecord(record dnsprovider.Record) cloudflare.DNSRecord {
+	if native, ok := record.Native.(cloudflare.DNSRecord); ok {
+		return native
+	}
+	return cloudflare.DNSRecord{
+		ID:         record.ID,
+		Name:       record.Name,
+		Type:       record.Type,
+		Content:    record.Content,
+		TTL:        record.TTL,
+		Priority:   record.Priority,
+		Proxied:    record.Proxied,
+		Comment:    record.CommentText(),
+		Tags:       record.Tags,
+		CreatedOn:  record.CreatedOn,
+		ModifiedOn: record.ModifiedOn,
+	}
+}
+
+func trimTxtQuotes(content string) string {
+	return strings.Trim(content, "\"")
+}
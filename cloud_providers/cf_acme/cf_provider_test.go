@@ -0,0 +1,86 @@
+package cf_acme
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const testCloudflareRecord = `{"success":true,"errors":[],"messages":[],"result":{"id":"rec1","name":"www.example.com","type":"A","content":"192.0.2.1","ttl":1,"proxied":true,"proxiable":true,"meta":{"auto_added":false},"comment":"web"}}`
+
+func newTestCloudflareProvider(t *testing.T, handler http.HandlerFunc) *CloudflareProvider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	api, err := cloudflare.NewWithAPIToken("test-token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCloudflareProvider(api)
+}
+
+func TestCloudflareProviderUpdateRecordComment(t *testing.T) {
+	empty, web := "", "web"
+	tests := []struct {
+		name    string
+		comment *string
+		want    any
+	}{
+		{name: "unset keeps the comment", comment: nil, want: nil},
+		{name: "empty clears the comment", comment: &empty, want: ""},
+		{name: "set replaces the comment", comment: &web, want: "web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]any
+			p := newTestCloudflareProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				json.Unmarshal(data, &body)
+				w.Write([]byte(testCloudflareRecord))
+			})
+			_, err := p.UpdateRecord(context.Background(), dnsprovider.Zone{ID: "zone1", Name: "example.com"}, dnsprovider.Record{ID: "rec1", Content: "192.0.2.1", Comment: tt.comment})
+			if err != nil {
+				t.Fatalf("UpdateRecord: %v", err)
+			}
+			got, sent := body["comment"]
+			if tt.want == nil {
+				if sent {
+					t.Fatalf("expected no comment in the request, got %v", got)
+				}
+				return
+			}
+			if !sent || got != tt.want {
+				t.Fatalf("expected comment %q in the request, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCloudflareRecordKeepsApiFields(t *testing.T) {
+	p := newTestCloudflareProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testCloudflareRecord))
+	})
+	record, err := p.GetRecord(context.Background(), dnsprovider.Zone{ID: "zone1", Name: "example.com"}, "rec1")
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	if record.CommentText() != "web" {
+		t.Fatalf("expected comment web, got %q", record.CommentText())
+	}
+
+	cf := CloudflareRecord(record)
+	if !cf.Proxiable || cf.Meta == nil || cf.Comment != "web" {
+		t.Fatalf("expected the api fields to survive the conversion, got %+v", cf)
+	}
+
+	converted := CloudflareRecord(dnsprovider.Record{ID: "rec2", Name: "a.example.com", Type: "A", Content: "192.0.2.2", TTL: 300})
+	if converted.ID != "rec2" || converted.Content != "192.0.2.2" || converted.Comment != "" {
+		t.Fatalf("unexpected conversion of a record from another backend: %+v", converted)
+	}
+}
@@ -0,0 +1,35 @@
+package cf_acme
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// ParseZoneTokens parses zone=token pairs used to write challenge records into validation
+// zones that belong to another Cloudflare account.
+func ParseZoneTokens(pairs []string) (map[string]string, error) {
+	tokens := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		zone, token, found := strings.Cut(pair, "=")
+		if !found || zone == "" || token == "" {
+			return tokens, fmt.Errorf("invalid zone token %q, expected zone=token", pair)
+		}
+		tokens[strings.ToLower(dns01.UnFqdn(zone))] = token
+	}
+	return tokens, nil
+}
+
+// tokenForName returns the token configured for the longest delegated zone containing name,
+// falling back to the provider's own DnsToken.
+func (d *InfraCfCustomDNSProvider) tokenForName(name string) string {
+	name = strings.ToLower(dns01.UnFqdn(name))
+	token, longest := d.DnsToken, 0
+	for zone, zoneToken := range d.DelegatedZoneTokens {
+		if (name == zone || strings.HasSuffix(name, "."+zone)) && len(zone) > longest {
+			token, longest = zoneToken, len(zone)
+		}
+	}
+	return token
+}
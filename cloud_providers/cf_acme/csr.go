@@ -0,0 +1,63 @@
+package cf_acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// LoadCSR reads a PEM or DER encoded certificate signing request and verifies its signature.
+func LoadCSR(path string) (*x509.CertificateRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("error reading csr file", slog.String("path", path), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		if !strings.HasSuffix(block.Type, "CERTIFICATE REQUEST") {
+			return nil, fmt.Errorf("unexpected PEM block %q in %s, expected CERTIFICATE REQUEST", block.Type, path)
+		}
+		der = block.Bytes
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		slog.Error("error parsing csr", slog.String("path", path), slog.String("error", err.Error()))
+		return nil, err
+	}
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csr signature in %s: %w", path, err)
+	}
+	return csr, nil
+}
+
+// loadCSR parses CsrFile and replaces DomainNames with the names in the CSR.
+func (c *CertificateRenewalRequest) loadCSR() error {
+	if c.CsrFile == "" || c.csr != nil {
+		return nil
+	}
+	if c.ReuseKey {
+		return fmt.Errorf("--reuse-key cannot be combined with a csr, the csr already fixes the key")
+	}
+
+	csr, err := LoadCSR(c.CsrFile)
+	if err != nil {
+		return err
+	}
+	domains := certcrypto.ExtractDomainsCSR(csr)
+	if len(domains) == 0 {
+		return fmt.Errorf("csr %s does not contain any domain names", c.CsrFile)
+	}
+	c.csr = csr
+	c.DomainNames = domains
+	slog.Info("loaded csr", slog.String("path", c.CsrFile), slog.String("domains", strings.Join(domains, ",")))
+	return nil
+}
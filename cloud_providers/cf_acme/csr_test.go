@@ -0,0 +1,103 @@
+package cf_acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTestCSR writes a CSR for commonName and sans, PEM encoded unless der is set.
+func writeTestCSR(t *testing.T, commonName string, sans []string, der bool) string {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	data, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}, DNSNames: sans}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !der {
+		data = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: data})
+	}
+	path := filepath.Join(t.TempDir(), "request.csr")
+	os.WriteFile(path, data, 0o600)
+	return path
+}
+
+func TestLoadCSR(t *testing.T) {
+	for _, der := range []bool{false, true} {
+		csr, err := LoadCSR(writeTestCSR(t, "csr.example.com", []string{"csr.example.com", "www.csr.example.com"}, der))
+		if err != nil {
+			t.Fatalf("LoadCSR der=%t: %v", der, err)
+		}
+		if !slices.Equal(csr.DNSNames, []string{"csr.example.com", "www.csr.example.com"}) {
+			t.Fatalf("unexpected names %v", csr.DNSNames)
+		}
+	}
+
+	tampered := filepath.Join(t.TempDir(), "tampered.csr")
+	data, _ := os.ReadFile(writeTestCSR(t, "csr.example.com", nil, true))
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(tampered, data, 0o600)
+
+	wrongBlock, _ := writeTestServingCert(t, t.TempDir())
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "certificate instead of csr", path: wrongBlock, wantErr: "expected CERTIFICATE REQUEST"},
+		{name: "bad signature", path: tampered, wantErr: "invalid csr signature"},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.csr"), wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadCSR(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRequestLoadCSR(t *testing.T) {
+	tests := []struct {
+		name       string
+		commonName string
+		sans       []string
+		reuseKey   bool
+		want       []string
+		wantErr    string
+	}{
+		{name: "names replaced by the sans", commonName: "csr.example.com", sans: []string{"csr.example.com", "www.csr.example.com"}, want: []string{"csr.example.com", "www.csr.example.com"}},
+		{name: "common name without sans", commonName: "cn.example.com", want: []string{"cn.example.com"}},
+		{name: "common name first", commonName: "cn.example.com", sans: []string{"www.example.com"}, want: []string{"cn.example.com", "www.example.com"}},
+		{name: "no names", wantErr: "does not contain any domain names"},
+		{name: "reuse key", commonName: "csr.example.com", reuseKey: true, wantErr: "cannot be combined with a csr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CertificateRenewalRequest{DomainNames: []string{"flag.example.com"}, CsrFile: writeTestCSR(t, tt.commonName, tt.sans, false), ReuseKey: tt.reuseKey}
+			err := c.loadCSR()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				if c.csr != nil {
+					t.Fatal("expected the csr not kept after an error")
+				}
+				return
+			}
+			if err != nil || !slices.Equal(c.DomainNames, tt.want) {
+				t.Fatalf("expected domains %q, got %q, %v", tt.want, c.DomainNames, err)
+			}
+		})
+	}
+}
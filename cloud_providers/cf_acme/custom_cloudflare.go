@@ -1,9 +1,9 @@
 package cf_acme
 
 import (
-	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go"
@@ -15,15 +15,27 @@ type ICertRenewalService interface {
 }
 
 type InfraCfCustomDNSProvider struct {
-	DnsToken                 string        `json:"dnsToken"`
-	ZoneToken                string        `json:"zoneToken"`
-	RecursiveNameServers     []string      `json:"recursiveNameServers"`
-	NewTxtRecordId           string        `json:"newTxtRecordId"`
-	CreatedChallengeTXT      bool          `json:"txtCreated"`
-	TTL                      int           `json:"ttl"`
-	ZoneID                   string        `json:"zoneID"`
-	PropagationTimeout       time.Duration `json:"propagationTimout"`
-	PropagationCheckInterval time.Duration `json:"checkInterval"`
+	DnsToken                 string                     `json:"dnsToken"`
+	ZoneToken                string                     `json:"zoneToken"`
+	RecursiveNameServers     []string                   `json:"recursiveNameServers"`
+	ChallengeRecords         map[string]challengeRecord `json:"challengeRecords"`
+	TTL                      int                        `json:"ttl"`
+	ZoneID                   string                     `json:"zoneID"`
+	PropagationTimeout       time.Duration              `json:"propagationTimout"`
+	PropagationCheckInterval time.Duration              `json:"checkInterval"`
+	DelegatedZoneTokens      map[string]string          `json:"-"`
+	zoneIDs                  map[string]string
+	mu                       sync.Mutex
+}
+
+// challengeRecord is a TXT record created by Present, keyed by domain and keyAuth so an
+// order for example.com and *.example.com can hold two values on the same _acme-challenge name.
+type challengeRecord struct {
+	ZoneID   string `json:"zoneId"`
+	RecordID string `json:"recordId"`
+	FQDN     string `json:"fqdn"`
+	Value    string `json:"value"`
+	token    string
 }
 
 func (d *InfraCfCustomDNSProvider) Timeout() (timeout, interval time.Duration) {
@@ -34,9 +46,19 @@ func NewInfraCfCustomDNSProvider(dnsApiToken string, zoneApiToken string, recurs
 	ttl := int(120)
 	checkInterval := 2 * time.Second
 
-	return &InfraCfCustomDNSProvider{DnsToken: dnsApiToken, ZoneToken: zoneApiToken, RecursiveNameServers: recursiveNameServers, TTL: ttl, PropagationTimeout: timeout, PropagationCheckInterval: checkInterval}, nil
+	return &InfraCfCustomDNSProvider{
+		DnsToken:                 dnsApiToken,
+		ZoneToken:                zoneApiToken,
+		RecursiveNameServers:     recursiveNameServers,
+		ChallengeRecords:         make(map[string]challengeRecord),
+		TTL:                      ttl,
+		PropagationTimeout:       timeout,
+		PropagationCheckInterval: checkInterval,
+		zoneIDs:                  make(map[string]string),
+	}, nil
 }
 
+// SetZoneId pins the provider to the zone of domain for every challenge.
 func (d *InfraCfCustomDNSProvider) SetZoneId(domain string) error {
 	zoneid, err := GetCloudflareZoneIdFromDomainName(d.ZoneToken, domain)
 	if err != nil {
@@ -47,77 +69,105 @@ func (d *InfraCfCustomDNSProvider) SetZoneId(domain string) error {
 	return err
 }
 
-func (d *InfraCfCustomDNSProvider) Present(domain, token, keyAuth string) error {
-	if len(d.ZoneID) < 1 {
-		err := d.SetZoneId(domain)
-		if err != nil {
-			slog.Error("error during Present retrieving zone id from domain name", slog.String("domain", domain), slog.String("error", err.Error()))
-			return err
-		}
+// zoneIdForName returns the pinned ZoneID for undelegated challenges, otherwise looks up and
+// caches the zone holding name using lookupToken. Callers must hold d.mu.
+func (d *InfraCfCustomDNSProvider) zoneIdForName(name string, lookupToken string, delegated bool) (string, error) {
+	if len(d.ZoneID) > 0 && !delegated {
+		return d.ZoneID, nil
+	}
+	if d.zoneIDs == nil {
+		d.zoneIDs = make(map[string]string)
+	}
+	cacheKey := lookupToken + "|" + name
+	if zoneId, ok := d.zoneIDs[cacheKey]; ok {
+		return zoneId, nil
+	}
+	zoneId, err := GetCloudflareZoneIdFromDomainName(lookupToken, name)
+	if err != nil {
+		slog.Error("error retrieving zone id from domain name", slog.String("domain", name), slog.String("error", err.Error()))
+		return "", err
 	}
+	d.zoneIDs[cacheKey] = zoneId
+	return zoneId, nil
+}
+
+func challengeKey(domain, keyAuth string) string {
+	return domain + "|" + keyAuth
+}
+
+func (d *InfraCfCustomDNSProvider) Present(domain, token, keyAuth string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	info := dns01.GetChallengeInfo(domain, keyAuth)
+	key := challengeKey(domain, keyAuth)
+	if _, ok := d.ChallengeRecords[key]; ok {
+		return nil
+	}
+
+	// _acme-challenge may be a CNAME into a validation zone, possibly in another Cloudflare account.
+	// lego follows the chain through the recursive nameservers set with AddRecursiveNameservers.
+	delegated := info.EffectiveFQDN != info.FQDN
+	recordName := dns01.UnFqdn(info.EffectiveFQDN)
+	apiToken, lookupToken := d.DnsToken, d.ZoneToken
+	if delegated {
+		apiToken = d.tokenForName(recordName)
+		if apiToken != d.DnsToken {
+			lookupToken = apiToken
+		}
+	}
 
-	qry, err := CheckRecordNameExists(d.DnsToken, d.ZoneID, info.FQDN)
+	zoneId, err := d.zoneIdForName(recordName, lookupToken, delegated)
 	if err != nil {
-		slog.Error("error in InfraCfCustomProvider checking of txt record name exists", slog.String("error", err.Error()), slog.String("infoFQDN", info.FQDN))
+		slog.Error("error during Present retrieving zone id from domain name", slog.String("domain", recordName), slog.String("error", err.Error()))
 		return err
 	}
 
-	switch qry.Exists {
-	case true:
-		return fmt.Errorf("txt record already exists, please remove it")
-	default:
-		params := cloudflare.CreateDNSRecordParams{Name: info.FQDN, Content: info.Value, TTL: d.TTL, Type: "TXT"}
-		record, err := CreateCloudflareDnsRecord(d.DnsToken, d.ZoneID, params)
-		if err != nil {
-			slog.Error("error creating dns record", slog.String("error", err.Error()), slog.String("infofqdn", info.FQDN))
-			return err
-		}
-		d.NewTxtRecordId = record.ID
-		d.CreatedChallengeTXT = true
-
+	qry, err := CheckRecordNameExists(apiToken, zoneId, recordName)
+	if err != nil {
+		slog.Error("error in InfraCfCustomProvider checking of txt record name exists", slog.String("error", err.Error()), slog.String("infoFQDN", recordName))
+		return err
 	}
-	return err
-}
 
-func (d *InfraCfCustomDNSProvider) CleanUp(domain, token, keyAuth string) error {
-	if len(d.ZoneID) < 1 {
-		err := d.SetZoneId(domain)
-		if err != nil {
-			slog.Error("error during Present retrieving zone id from domain name", slog.String("domain", domain), slog.String("error", err.Error()))
-			return err
+	// Other TXT values on the same name are fine, only skip creating a duplicate of this value.
+	for _, existing := range qry.Records {
+		if strings.Trim(existing.Content, "\"") == info.Value {
+			slog.Info("challenge txt record already present, leaving it unmanaged", slog.String("fqdn", recordName), slog.String("recordId", existing.ID))
+			return nil
 		}
 	}
 
-	if d.CreatedChallengeTXT {
-		if len(d.NewTxtRecordId) < 1 {
-			return fmt.Errorf("no txt record id specified for cleanup")
-		}
-		err := DeleteCloudFlareDnsRecord(d.DnsToken, d.ZoneID, d.NewTxtRecordId)
-		if err != nil {
-			slog.Error("error deleting txt record id during cleanup", slog.String("error", err.Error()), slog.String("id", d.NewTxtRecordId), slog.String("zoneId", d.ZoneID))
-			return err
-		}
-		d.CreatedChallengeTXT = false
-		d.NewTxtRecordId = ""
+	params := cloudflare.CreateDNSRecordParams{Name: recordName, Content: info.Value, TTL: d.TTL, Type: "TXT"}
+	record, err := CreateCloudflareDnsRecord(apiToken, zoneId, params)
+	if err != nil {
+		slog.Error("error creating dns record", slog.String("error", err.Error()), slog.String("infofqdn", recordName))
 		return err
 	}
-	return fmt.Errorf("error during cleanup, CreatedChallengeTXT set to false")
+	if d.ChallengeRecords == nil {
+		d.ChallengeRecords = make(map[string]challengeRecord)
+	}
+	d.ChallengeRecords[key] = challengeRecord{ZoneID: zoneId, RecordID: record.ID, FQDN: recordName, Value: info.Value, token: apiToken}
+	return nil
 }
 
-// getRootDomain extracts the root domain from a given subdomain.
-func getRootDomain(subdomain string) string {
-	parts := strings.Split(subdomain, ".")
-	if len(parts) < 2 {
-		return subdomain // Return as is if it's not a valid domain format
+// CleanUp deletes only the TXT record Present created for this domain and keyAuth, in
+// whichever zone and account it was written to.
+func (d *InfraCfCustomDNSProvider) CleanUp(domain, token, keyAuth string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := challengeKey(domain, keyAuth)
+	record, ok := d.ChallengeRecords[key]
+	if !ok {
+		slog.Info("no challenge txt record created for domain, nothing to clean up", slog.String("domain", domain))
+		return nil
 	}
 
-	// Handle wildcard prefixes like "*.example.com"
-	if parts[0] == "*" {
-		parts = parts[1:]
+	err := DeleteCloudFlareDnsRecord(record.token, record.ZoneID, record.RecordID)
+	if err != nil {
+		slog.Error("error deleting txt record id during cleanup", slog.String("error", err.Error()), slog.String("id", record.RecordID), slog.String("zoneId", record.ZoneID))
+		return err
 	}
-
-	// Return the last two segments as the root domain
-	return strings.Join(parts[len(parts)-2:], ".")
+	delete(d.ChallengeRecords, key)
+	return nil
 }
@@ -0,0 +1,145 @@
+package cf_acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// testTxtApi is a Cloudflare API holding the TXT records of zone1 in memory. Like Cloudflare it
+// stores and matches names without the trailing dot.
+type testTxtApi struct {
+	mu      sync.Mutex
+	records map[string]map[string]string
+	nextID  int
+	deleted []string
+}
+
+// values returns the TXT values stored under name.
+func (a *testTxtApi) values(name string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	values := []string{}
+	for _, v := range a.records {
+		if v["name"] == name {
+			values = append(values, v["content"])
+		}
+	}
+	return values
+}
+
+func (a *testTxtApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/zones/zone1/dns_records")
+	var result any
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		records := []map[string]string{}
+		for _, v := range a.records {
+			if v["name"] == strings.TrimSuffix(r.URL.Query().Get("name"), ".") {
+				records = append(records, v)
+			}
+		}
+		result = records
+	case r.Method == http.MethodPost && path == "":
+		record := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&record)
+		a.nextID++
+		id := fmt.Sprintf("rec%d", a.nextID)
+		a.records[id] = map[string]string{"id": id, "name": strings.TrimSuffix(record["name"], "."), "type": "TXT", "content": record["content"]}
+		result = a.records[id]
+	case r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(path, "/")
+		delete(a.records, id)
+		a.deleted = append(a.deleted, id)
+		result = map[string]string{"id": id}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := json.Marshal(result)
+	w.Write(fmt.Appendf(nil, `{"success":true,"errors":[],"messages":[],"result":%s,"result_info":{"page":1,"per_page":100,"total_pages":1}}`, data))
+}
+
+// redirectTransport sends every request to the test server instead of api.cloudflare.com.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = rt.target.Scheme, rt.target.Host
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, "/client/v4")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// startTestTxtApi points the Cloudflare clients created by cf_api.go at a testTxtApi.
+func startTestTxtApi(t *testing.T) *testTxtApi {
+	t.Helper()
+	api := &testTxtApi{records: make(map[string]map[string]string)}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = redirectTransport{target: target}
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+	return api
+}
+
+func TestPresentAndCleanUpPerKeyAuth(t *testing.T) {
+	t.Setenv("LEGO_DISABLE_CNAME_SUPPORT", "true")
+	api := startTestTxtApi(t)
+	d, err := NewInfraCfCustomDNSProvider("dns-token", "zone-token", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ZoneID = "zone1"
+
+	// example.com and *.example.com in one order share _acme-challenge.example.com.
+	keyAuths := []string{"token-a.thumbprint", "token-b.thumbprint"}
+	run := func(f func(domain, token, keyAuth string) error) {
+		t.Helper()
+		var wg sync.WaitGroup
+		errs := make([]error, len(keyAuths))
+		for i, keyAuth := range keyAuths {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = f("example.com", "", keyAuth)
+			}()
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	run(d.Present)
+	values := api.values("_acme-challenge.example.com")
+	if len(values) != 2 || len(d.ChallengeRecords) != 2 {
+		t.Fatalf("expected a TXT record per key authorization, got %q", values)
+	}
+
+	wantLeft := dns01.GetChallengeInfo("example.com", keyAuths[1]).Value
+	if err := d.CleanUp("example.com", "", keyAuths[0]); err != nil {
+		t.Fatalf("CleanUp: %v", err)
+	}
+	if values := api.values("_acme-challenge.example.com"); len(values) != 1 || values[0] != wantLeft {
+		t.Fatalf("expected only the other key authorization's record left, got %q", values)
+	}
+
+	run(d.CleanUp)
+	if values := api.values("_acme-challenge.example.com"); len(values) != 0 || len(api.deleted) != 2 {
+		t.Fatalf("expected each record deleted once, got %q left and deletes %q", values, api.deleted)
+	}
+}
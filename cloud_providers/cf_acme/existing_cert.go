@@ -0,0 +1,195 @@
+package cf_acme
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/storage/goinfra_minio"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	ExistingCertSourceZip    = "zip"
+	ExistingCertSourcePemDir = "pem-dir"
+	ExistingCertSourceS3     = "s3"
+)
+
+// File names written by saveToZip and SaveFilesWithCertsToZipBuffer, plus the common certbot names.
+var (
+	certFileNames   = []string{"certificate.pem", "cert.pem", "fullchain.pem"}
+	keyFileNames    = []string{"private_key.pem", "privkey.pem", "key.pem"}
+	issuerFileNames = []string{"issuer_ca.pem", "issuer.pem", "chain.pem"}
+)
+
+type ExistingCertificate struct {
+	Source     string            `json:"source"`
+	Location   string            `json:"location"`
+	CertPEM    []byte            `json:"-"`
+	PrivKeyPEM []byte            `json:"-"`
+	IssuerPEM  []byte            `json:"-"`
+	Leaf       *x509.Certificate `json:"-"`
+}
+
+type RenewalCheckResult struct {
+	Source      string          `json:"source"`
+	Location    string          `json:"location"`
+	DomainNames []string        `json:"domainNames"`
+	NotAfter    time.Time       `json:"notAfter"`
+	Remaining   string          `json:"remaining"`
+	RenewBefore string          `json:"renewBefore"`
+	Due         bool            `json:"due"`
+	Forced      bool            `json:"forced"`
+	Reason      string          `json:"reason"`
+	Ari         *AriRenewalInfo `json:"ari,omitempty"`
+}
+
+// LoadExistingCertificate reads a previously issued certificate from a zip file, a PEM file,
+// a PEM directory or an object in the default S3 bucket.
+func LoadExistingCertificate(source string, location string) (*ExistingCertificate, error) {
+	var files map[string][]byte
+	var err error
+
+	switch source {
+	case ExistingCertSourceZip:
+		var data []byte
+		data, err = os.ReadFile(location)
+		if err != nil {
+			return nil, err
+		}
+		files, err = readZipFiles(data)
+	case ExistingCertSourcePemDir:
+		files, err = readPemDir(location)
+	case ExistingCertSourcePemFile:
+		files, err = loadPemFile(location)
+	case ExistingCertSourceS3:
+		files, err = readS3CertObject(location)
+	default:
+		err = fmt.Errorf("unsupported existing certificate source %q, use zip, pem, pem-dir or s3", source)
+	}
+	if err != nil {
+		slog.Error("error reading existing certificate", slog.String("source", source), slog.String("location", location), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	existing := &ExistingCertificate{
+		Source:     source,
+		Location:   location,
+		CertPEM:    firstFile(files, certFileNames),
+		PrivKeyPEM: firstFile(files, keyFileNames),
+		IssuerPEM:  firstFile(files, issuerFileNames),
+	}
+	if len(existing.CertPEM) == 0 {
+		return existing, fmt.Errorf("no certificate found in %s %s", source, location)
+	}
+
+	certs, err := certcrypto.ParsePEMBundle(existing.CertPEM)
+	if err != nil {
+		return existing, err
+	}
+	existing.Leaf = certs[0]
+	return existing, nil
+}
+
+// CheckRenewalWindow reports whether the certificate expires within renewBefore.
+func (e *ExistingCertificate) CheckRenewalWindow(renewBefore time.Duration, now time.Time) RenewalCheckResult {
+	remaining := e.Leaf.NotAfter.Sub(now)
+	result := RenewalCheckResult{
+		Source:      e.Source,
+		Location:    e.Location,
+		DomainNames: certcrypto.ExtractDomains(e.Leaf),
+		NotAfter:    e.Leaf.NotAfter,
+		Remaining:   remaining.Truncate(time.Minute).String(),
+		RenewBefore: renewBefore.String(),
+		Due:         remaining <= renewBefore,
+	}
+	if result.Due {
+		result.Reason = fmt.Sprintf("certificate expires within %s", renewBefore)
+	} else {
+		result.Reason = "not due"
+	}
+	return result
+}
+
+func readZipFiles(data []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return files, err
+	}
+	for _, f := range zipReader.File {
+		rc, err := f.Open()
+		if err != nil {
+			return files, fmt.Errorf("failed to open zip entry %s: %w", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return files, fmt.Errorf("failed to read zip entry %s: %w", f.Name, err)
+		}
+		files[filepath.Base(f.Name)] = content
+	}
+	return files, nil
+}
+
+func readPemDir(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return files, err
+		}
+		files[entry.Name()] = content
+	}
+	return files, nil
+}
+
+// readS3CertObject fetches an object from the default bucket. The object may be a zip
+// produced by PushZipDirToS3 or a single PEM file.
+func readS3CertObject(objectName string) (map[string][]byte, error) {
+	s3client, err := goinfra_minio.NewS3ClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := s3client.GetObjectFromDefaultBucket(objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, fmt.Errorf("s3 object %s: %w", objectName, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("PK")) {
+		return readZipFiles(data)
+	}
+	return map[string][]byte{"certificate.pem": data}, nil
+}
+
+func firstFile(files map[string][]byte, names []string) []byte {
+	for _, name := range names {
+		if content, ok := files[name]; ok {
+			return content
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package cf_acme
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/babbage88/go-acme-cli/storage/goinfra_minio"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	ChallengeDns01     = "dns-01"
+	ChallengeHttp01    = "http-01"
+	ChallengeTlsAlpn01 = "tls-alpn-01"
+
+	HttpChallengeListener = "listener"
+	HttpChallengeWebroot  = "webroot"
+	HttpChallengeS3       = "s3"
+)
+
+// S3HttpProvider writes HTTP-01 key authorizations to .well-known/acme-challenge/<token> in a
+// bucket, for hosts whose site is served straight from object storage.
+type S3HttpProvider struct {
+	Client *goinfra_minio.S3ClientWithBucket
+	Prefix string
+}
+
+// NewS3HttpProviderFromEnv uses the S3_* env vars, optionally overriding S3_DEFAULT_BUCKET.
+func NewS3HttpProviderFromEnv(bucket string, prefix string) (*S3HttpProvider, error) {
+	s3client, err := goinfra_minio.NewS3ClientFromEnv()
+	if err != nil {
+		slog.Error("error initializing s3 client for http-01 challenge", slog.String("error", err.Error()))
+		return nil, err
+	}
+	if bucket != "" {
+		s3client.DefaultBucketName = bucket
+	}
+	return &S3HttpProvider{Client: s3client, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+func (p *S3HttpProvider) objectName(token string) string {
+	name := strings.TrimPrefix(http01.ChallengePath(token), "/")
+	if p.Prefix == "" {
+		return name
+	}
+	return p.Prefix + "/" + name
+}
+
+func (p *S3HttpProvider) Present(domain, token, keyAuth string) error {
+	objName := p.objectName(token)
+	_, err := p.Client.PushBytesToDefaultBucket(objName, []byte(keyAuth))
+	if err != nil {
+		return fmt.Errorf("error pushing http-01 challenge %s for %s: %w", objName, domain, err)
+	}
+	slog.Info("pushed http-01 challenge to s3", slog.String("bucket", p.Client.DefaultBucketName), slog.String("object", objName))
+	return nil
+}
+
+func (p *S3HttpProvider) CleanUp(domain, token, keyAuth string) error {
+	objName := p.objectName(token)
+	err := p.Client.Client.RemoveObject(context.Background(), p.Client.DefaultBucketName, objName, minio.RemoveObjectOptions{})
+	if err != nil {
+		slog.Error("error removing http-01 challenge from s3", slog.String("object", objName), slog.String("error", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// newHttpProvider builds the HTTP-01 provider selected by HttpMode.
+func (c *CertificateRenewalRequest) newHttpProvider() (challenge.Provider, error) {
+	switch c.HttpMode {
+	case "", HttpChallengeListener:
+		address := c.HttpAddress
+		if address == "" {
+			address = ":80"
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http-01 listen address %q: %w", address, err)
+		}
+		return http01.NewProviderServer(host, port), nil
+	case HttpChallengeWebroot:
+		if c.HttpWebroot == "" {
+			return nil, fmt.Errorf("http-01 webroot mode requires a webroot directory")
+		}
+		return webroot.NewHTTPProvider(c.HttpWebroot)
+	case HttpChallengeS3:
+		return NewS3HttpProviderFromEnv(c.HttpS3Bucket, c.HttpS3Prefix)
+	default:
+		return nil, fmt.Errorf("unsupported http-01 mode %q, use listener, webroot or s3", c.HttpMode)
+	}
+}
+
+func (c *CertificateRenewalRequest) setHttpProvider(client *lego.Client) error {
+	provider, err := c.newHttpProvider()
+	if err != nil {
+		slog.Error("error initializing http-01 challenge provider", slog.String("error", err.Error()))
+		return err
+	}
+	err = client.Challenge.SetHTTP01Provider(provider)
+	if err != nil {
+		slog.Error("Failed to set HTTP challenge.", slog.String("error", err.Error()))
+		return err
+	}
+	slog.Info("using http-01 challenge", slog.String("mode", c.HttpMode))
+	return nil
+}
@@ -0,0 +1,125 @@
+package cf_acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/miekg/dns"
+)
+
+// startLoopbackResolver answers every A query with 127.0.0.1, and every other query with no
+// records, over udp and tcp so Pebble connects back to the test for validation.
+func startLoopbackResolver(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		msg := new(dns.Msg)
+		msg.SetReply(r)
+		for _, q := range r.Question {
+			if q.Qtype == dns.TypeA {
+				msg.Answer = append(msg.Answer, &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("127.0.0.1")})
+			}
+		}
+		w.WriteMsg(msg)
+	})
+	ln, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, server := range []*dns.Server{{PacketConn: conn, Handler: handler}, {Listener: ln, Handler: handler}} {
+		go server.ActivateAndServe()
+		t.Cleanup(func() { server.Shutdown() })
+	}
+	return conn.LocalAddr().String()
+}
+
+func TestNewHttpProvider(t *testing.T) {
+	t.Setenv("S3_ENDPOINT", "")
+	tests := []struct {
+		name    string
+		request CertificateRenewalRequest
+		want    string
+		wantErr string
+	}{
+		{name: "listener by default", want: "*http01.ProviderServer"},
+		{name: "listener", request: CertificateRenewalRequest{HttpMode: HttpChallengeListener, HttpAddress: "127.0.0.1:8080"}, want: "*http01.ProviderServer"},
+		{name: "listener without port", request: CertificateRenewalRequest{HttpMode: HttpChallengeListener, HttpAddress: "8080"}, wantErr: "invalid http-01 listen address"},
+		{name: "webroot", request: CertificateRenewalRequest{HttpMode: HttpChallengeWebroot, HttpWebroot: t.TempDir()}, want: "*webroot.HTTPProvider"},
+		{name: "webroot without directory", request: CertificateRenewalRequest{HttpMode: HttpChallengeWebroot}, wantErr: "requires a webroot directory"},
+		{name: "s3 without endpoint", request: CertificateRenewalRequest{HttpMode: HttpChallengeS3}, wantErr: "Endpoint"},
+		{name: "unknown mode", request: CertificateRenewalRequest{HttpMode: "ftp"}, wantErr: "unsupported http-01 mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := tt.request.newHttpProvider()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || fmt.Sprintf("%T", provider) != tt.want {
+				t.Fatalf("expected a %s, got %T, %v", tt.want, provider, err)
+			}
+		})
+	}
+
+	server, _ := (&CertificateRenewalRequest{}).newHttpProvider()
+	if got := server.(*http01.ProviderServer).GetAddress(); got != ":80" {
+		t.Fatalf("expected the listener on :80 by default, got %s", got)
+	}
+}
+
+func TestS3HttpProviderObjectName(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: ".well-known/acme-challenge/token123"},
+		{prefix: "site", want: "site/.well-known/acme-challenge/token123"},
+		{prefix: "sites/example.com", want: "sites/example.com/.well-known/acme-challenge/token123"},
+	}
+	for _, tt := range tests {
+		p := &S3HttpProvider{Prefix: tt.prefix}
+		if got := p.objectName("token123"); got != tt.want {
+			t.Fatalf("expected %s for prefix %q, got %s", tt.want, tt.prefix, got)
+		}
+	}
+}
+
+func TestRenewWithHttpChallenge(t *testing.T) {
+	pebbleBinary(t)
+	httpPort := freePort(t)
+	srv := startPebble(t, pebbleOptions{DnsServer: startLoopbackResolver(t), HttpPort: httpPort})
+
+	c := newPebbleRequest(t, srv, "http.example.com", "www.http.example.com")
+	c.Challenge = ChallengeHttp01
+	c.HttpMode = HttpChallengeListener
+	c.HttpAddress = fmt.Sprintf("127.0.0.1:%d", httpPort)
+	certData, err := c.Renew("", nil, 0)
+	if err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(certData.CertPEM))
+	if block == nil {
+		t.Fatal("expected a PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(cert.DNSNames)
+	if !slices.Equal(cert.DNSNames, []string{"http.example.com", "www.http.example.com"}) {
+		t.Fatalf("unexpected certificate names %v", cert.DNSNames)
+	}
+}
@@ -0,0 +1,148 @@
+package cf_acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// lego has no key change support, so the RFC 8555 section 7.3.5 rollover request is built here.
+type keyChangeDirectory struct {
+	NewNonceURL  string `json:"newNonce"`
+	KeyChangeURL string `json:"keyChange"`
+}
+
+type keyChangePayload struct {
+	Account string          `json:"account"`
+	OldKey  jose.JSONWebKey `json:"oldKey"`
+}
+
+const acmeBadNonce = "urn:ietf:params:acme:error:badNonce"
+
+// RolloverAccountKey replaces the account key registered with the CA by newKey.
+// On success the AcmeUser holds newKey and must be saved back to the AccountStore.
+func (u *AcmeUser) RolloverAccountKey(caDirURL string, newKey crypto.Signer) error {
+	if u.Registration == nil || u.Registration.URI == "" {
+		return fmt.Errorf("acme account for %s is not registered", u.Email)
+	}
+	oldKey, ok := u.key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("unsupported acme account key type %T", u.key)
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	dir := &keyChangeDirectory{}
+	resp, err := httpClient.Get(caDirURL)
+	if err != nil {
+		slog.Error("error fetching acme directory", slog.String("url", caDirURL), slog.String("error", err.Error()))
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(dir)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if dir.KeyChangeURL == "" {
+		return fmt.Errorf("acme directory %s does not advertise a keyChange endpoint", caDirURL)
+	}
+
+	innerSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: jwsAlgorithm(newKey), Key: newKey}, &jose.SignerOptions{
+		EmbedJWK:     true,
+		ExtraHeaders: map[jose.HeaderKey]any{"url": dir.KeyChangeURL},
+	})
+	if err != nil {
+		return err
+	}
+	innerPayload, err := json.Marshal(keyChangePayload{Account: u.Registration.URI, OldKey: jose.JSONWebKey{Key: oldKey.Public()}})
+	if err != nil {
+		return err
+	}
+	inner, err := innerSigner.Sign(innerPayload)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := fetchNonce(httpClient, dir.NewNonceURL)
+	if err != nil {
+		return err
+	}
+	// A stale nonce is rejected with badNonce, retry once with the fresh nonce the CA returns.
+	for attempt := 0; ; attempt++ {
+		outerSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: jwsAlgorithm(oldKey), Key: oldKey}, &jose.SignerOptions{
+			ExtraHeaders: map[jose.HeaderKey]any{
+				"kid":   u.Registration.URI,
+				"url":   dir.KeyChangeURL,
+				"nonce": nonce,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		outer, err := outerSigner.Sign([]byte(inner.FullSerialize()))
+		if err != nil {
+			return err
+		}
+
+		resp, err = httpClient.Post(dir.KeyChangeURL, "application/jose+json", strings.NewReader(outer.FullSerialize()))
+		if err != nil {
+			slog.Error("error posting acme key change", slog.String("url", dir.KeyChangeURL), slog.String("error", err.Error()))
+			return err
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		if attempt == 0 && strings.Contains(string(body), acmeBadNonce) {
+			slog.Warn("acme key change rejected with badNonce, retrying", slog.String("url", dir.KeyChangeURL))
+			nonce = resp.Header.Get("Replay-Nonce")
+			if nonce == "" {
+				nonce, err = fetchNonce(httpClient, dir.NewNonceURL)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		return fmt.Errorf("acme key change failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	u.key = newKey
+	return nil
+}
+
+func fetchNonce(httpClient *http.Client, newNonceURL string) (string, error) {
+	resp, err := httpClient.Head(newNonceURL)
+	if err != nil {
+		slog.Error("error fetching acme nonce", slog.String("url", newNonceURL), slog.String("error", err.Error()))
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Replay-Nonce"), nil
+}
+
+func jwsAlgorithm(key crypto.Signer) jose.SignatureAlgorithm {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P384():
+			return jose.ES384
+		case elliptic.P521():
+			return jose.ES512
+		}
+		return jose.ES256
+	case *rsa.PrivateKey:
+		return jose.RS256
+	}
+	return jose.ES256
+}
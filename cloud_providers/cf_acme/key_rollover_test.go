@@ -0,0 +1,92 @@
+package cf_acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/go-jose/go-jose/v4"
+)
+
+func TestRolloverAccountKeyRetriesBadNonce(t *testing.T) {
+	var posts atomic.Int32
+	nonces := []string{}
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/dir", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keyChangeDirectory{NewNonceURL: srv.URL + "/nonce", KeyChangeURL: srv.URL + "/key-change"})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "stale")
+	})
+	mux.HandleFunc("/key-change", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		jws, err := jose.ParseSigned(string(body), []jose.SignatureAlgorithm{jose.ES256})
+		if err != nil {
+			t.Errorf("invalid jws: %v", err)
+		}
+		nonces = append(nonces, jws.Signatures[0].Protected.Nonce)
+		if posts.Add(1) == 1 {
+			w.Header().Set("Replay-Nonce", "fresh")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"type":"` + acmeBadNonce + `","detail":"JWS has an invalid anti-replay nonce"}`))
+			return
+		}
+	})
+
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	user := &AcmeUser{Email: "test@example.com", key: oldKey, Registration: &registration.Resource{URI: srv.URL + "/acct/1"}}
+	if err := user.RolloverAccountKey(srv.URL+"/dir", newKey); err != nil {
+		t.Fatalf("RolloverAccountKey: %v", err)
+	}
+	if posts.Load() != 2 {
+		t.Fatalf("expected 2 key change posts, got %d", posts.Load())
+	}
+	if strings.Join(nonces, ",") != "stale,fresh" {
+		t.Fatalf("expected the retry to use the nonce from the badNonce response, got %v", nonces)
+	}
+	if user.key != newKey {
+		t.Fatal("account key was not replaced")
+	}
+}
+
+func TestRolloverAccountKeyFailsAfterSecondBadNonce(t *testing.T) {
+	var posts atomic.Int32
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/dir", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keyChangeDirectory{NewNonceURL: srv.URL + "/nonce", KeyChangeURL: srv.URL + "/key-change"})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "n")
+	})
+	mux.HandleFunc("/key-change", func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"type":"` + acmeBadNonce + `"}`))
+	})
+
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	user := &AcmeUser{Email: "test@example.com", key: oldKey, Registration: &registration.Resource{URI: srv.URL + "/acct/1"}}
+	if err := user.RolloverAccountKey(srv.URL+"/dir", newKey); err == nil {
+		t.Fatal("expected an error after the retry was also rejected")
+	}
+	if posts.Load() != 2 {
+		t.Fatalf("expected exactly one retry, got %d posts", posts.Load())
+	}
+	if user.key != oldKey {
+		t.Fatal("account key must not change when the rollover fails")
+	}
+}
@@ -0,0 +1,79 @@
+package cf_acme
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+var certKeyTypes = map[string]certcrypto.KeyType{
+	"EC256":   certcrypto.EC256,
+	"P256":    certcrypto.EC256,
+	"EC384":   certcrypto.EC384,
+	"P384":    certcrypto.EC384,
+	"RSA2048": certcrypto.RSA2048,
+	"2048":    certcrypto.RSA2048,
+	"RSA3072": certcrypto.RSA3072,
+	"3072":    certcrypto.RSA3072,
+	"RSA4096": certcrypto.RSA4096,
+	"4096":    certcrypto.RSA4096,
+	"RSA8192": certcrypto.RSA8192,
+	"8192":    certcrypto.RSA8192,
+}
+
+// ParseKeyType accepts EC256, EC384, RSA2048, RSA3072, RSA4096 or RSA8192, as well as lego's
+// own P256/P384/2048 style names.
+func ParseKeyType(keyType string) (certcrypto.KeyType, error) {
+	kt, ok := certKeyTypes[strings.ToUpper(strings.ReplaceAll(keyType, "-", ""))]
+	if !ok {
+		return "", fmt.Errorf("unsupported key type %q, use EC256, EC384, RSA2048, RSA3072, RSA4096 or RSA8192", keyType)
+	}
+	return kt, nil
+}
+
+func (c *CertificateRenewalRequest) certKeyType() certcrypto.KeyType {
+	if c.KeyType == "" {
+		return certcrypto.RSA2048
+	}
+	return c.KeyType
+}
+
+// reusablePrivateKey returns the private key of the existing certificate when ReuseKey is set.
+// A nil key without error means there is nothing to reuse yet and lego generates a new one.
+func (c *CertificateRenewalRequest) reusablePrivateKey() (crypto.PrivateKey, error) {
+	if !c.ReuseKey {
+		return nil, nil
+	}
+
+	if c.existing == nil {
+		source := c.ExistingCertSource
+		if source == "" {
+			source = ExistingCertSourceZip
+		}
+		existing, err := LoadExistingCertificate(source, c.ExistingCertLocation())
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Info("no existing certificate found, generating a new private key", slog.String("location", c.ExistingCertLocation()))
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.existing = existing
+	}
+
+	if len(c.existing.PrivKeyPEM) == 0 {
+		return nil, fmt.Errorf("existing certificate at %s has no private key to reuse", c.existing.Location)
+	}
+	key, err := certcrypto.ParsePEMPrivateKey(c.existing.PrivKeyPEM)
+	if err != nil {
+		slog.Error("error parsing existing private key", slog.String("error", err.Error()))
+		return nil, err
+	}
+	slog.Info("reusing existing certificate private key", slog.String("location", c.existing.Location), slog.String("keyType", fmt.Sprintf("%T", key)))
+	return key, nil
+}
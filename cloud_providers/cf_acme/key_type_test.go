@@ -0,0 +1,95 @@
+package cf_acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+func TestParseKeyType(t *testing.T) {
+	tests := []struct {
+		keyType string
+		want    certcrypto.KeyType
+		wantErr bool
+	}{
+		{keyType: "EC256", want: certcrypto.EC256},
+		{keyType: "ec-384", want: certcrypto.EC384},
+		{keyType: "P256", want: certcrypto.EC256},
+		{keyType: "RSA2048", want: certcrypto.RSA2048},
+		{keyType: "rsa-4096", want: certcrypto.RSA4096},
+		{keyType: "8192", want: certcrypto.RSA8192},
+		{keyType: "EC521", wantErr: true},
+		{keyType: "RSA1024", wantErr: true},
+		{keyType: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyType, func(t *testing.T) {
+			got, err := ParseKeyType(tt.keyType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error for %q, got %s", tt.keyType, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("expected %s, got %s, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestReusablePrivateKey(t *testing.T) {
+	existingPath := writeForeignCert(t, "reuse.example.com", 24*time.Hour)
+	data, _ := os.ReadFile(existingPath)
+	certBlock, rest := pem.Decode(data)
+	keyBlock, _ := pem.Decode(rest)
+	existingKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certOnly := filepath.Join(t.TempDir(), "cert.pem")
+	os.WriteFile(certOnly, pem.EncodeToMemory(certBlock), 0o600)
+
+	tests := []struct {
+		name    string
+		reuse   bool
+		path    string
+		wantKey bool
+		wantErr bool
+	}{
+		{name: "reuse off", path: existingPath},
+		{name: "no existing certificate", reuse: true, path: filepath.Join(t.TempDir(), "missing.pem")},
+		{name: "existing key", reuse: true, path: existingPath, wantKey: true},
+		{name: "certificate without key", reuse: true, path: certOnly, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CertificateRenewalRequest{ReuseKey: tt.reuse, ExistingCertSource: ExistingCertSourcePemFile, ExistingCertPath: tt.path}
+			key, err := c.reusablePrivateKey()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error for a certificate without a private key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reusablePrivateKey: %v", err)
+			}
+			if !tt.wantKey {
+				if key != nil {
+					t.Fatalf("expected no key, got %T", key)
+				}
+				return
+			}
+			if ec, ok := key.(*ecdsa.PrivateKey); !ok || !ec.Equal(existingKey) {
+				t.Fatalf("expected the existing private key, got %T", key)
+			}
+		})
+	}
+}
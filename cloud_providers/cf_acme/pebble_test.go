@@ -0,0 +1,185 @@
+package cf_acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pebbleOptions configures the local Pebble CA started by startPebble.
+type pebbleOptions struct {
+	// AlwaysValid skips challenge validation so tests do not need to serve challenges.
+	AlwaysValid bool
+	// EabKeys requires external account binding with these kid to base64url HMAC keys.
+	EabKeys map[string]string
+	// DnsServer is the resolver Pebble validates challenges against, host:port.
+	DnsServer string
+	// HttpPort is the port Pebble connects to for http-01.
+	HttpPort int
+	// TlsPort is the port Pebble connects to for tls-alpn-01.
+	TlsPort int
+}
+
+// pebbleServer is a Pebble CA running for one test.
+type pebbleServer struct {
+	DirURL string
+}
+
+// pebbleBinary returns PEBBLE_BIN or pebble from PATH, skipping the test when neither exists.
+func pebbleBinary(t *testing.T) string {
+	t.Helper()
+	if bin := os.Getenv("PEBBLE_BIN"); bin != "" {
+		return bin
+	}
+	bin, err := exec.LookPath("pebble")
+	if err != nil {
+		t.Skip("pebble not found, set PEBBLE_BIN or add it to PATH to run this test")
+	}
+	return bin
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// writeTestServingCert writes a self-signed certificate for localhost and 127.0.0.1 and its key.
+func writeTestServingCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	return certPath, keyPath
+}
+
+// startPebble runs Pebble on free local ports until the test ends and points lego at its
+// serving certificate through LEGO_CA_CERTIFICATES.
+func startPebble(t *testing.T, opts pebbleOptions) *pebbleServer {
+	t.Helper()
+	bin := pebbleBinary(t)
+	dir := t.TempDir()
+	certPath, keyPath := writeTestServingCert(t, dir)
+
+	listen := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	httpPort := opts.HttpPort
+	if httpPort == 0 {
+		httpPort = freePort(t)
+	}
+	tlsPort := opts.TlsPort
+	if tlsPort == 0 {
+		tlsPort = freePort(t)
+	}
+	config := map[string]any{
+		"pebble": map[string]any{
+			"listenAddress":                  listen,
+			"managementListenAddress":        fmt.Sprintf("127.0.0.1:%d", freePort(t)),
+			"certificate":                    certPath,
+			"privateKey":                     keyPath,
+			"httpPort":                       httpPort,
+			"tlsPort":                        tlsPort,
+			"ocspResponderURL":               "",
+			"externalAccountBindingRequired": len(opts.EabKeys) > 0,
+			"externalAccountMACKeys":         opts.EabKeys,
+		},
+	}
+	configPath := filepath.Join(dir, "pebble-config.json")
+	data, _ := json.Marshal(config)
+	if err := os.WriteFile(configPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-config", configPath}
+	if opts.DnsServer != "" {
+		args = append(args, "-dnsserver", opts.DnsServer)
+	}
+	cmd := exec.Command(bin, args...)
+	cmd.Env = append(os.Environ(), "PEBBLE_VA_NOSLEEP=1", "PEBBLE_WFE_NONCEREJECT=0", "PEBBLE_AUTHZREUSE=0")
+	if opts.AlwaysValid {
+		cmd.Env = append(cmd.Env, "PEBBLE_VA_ALWAYS_VALID=1")
+	}
+	output := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("error starting pebble: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if t.Failed() {
+			t.Logf("pebble output:\n%s", output.String())
+		}
+	})
+
+	dirURL := "https://" + listen + "/dir"
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		resp, err := client.Get(dirURL)
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pebble did not start: %v\n%s", err, output.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Setenv("LEGO_CA_CERTIFICATES", certPath)
+	return &pebbleServer{DirURL: dirURL}
+}
+
+// newPebbleRequest returns a renewal request against srv with an account directory of its own.
+func newPebbleRequest(t *testing.T, srv *pebbleServer, domains ...string) *CertificateRenewalRequest {
+	t.Helper()
+	return &CertificateRenewalRequest{
+		DomainNames: domains,
+		AcmeEmail:   "test@example.com",
+		AcmeUrl:     srv.DirURL,
+		AccountDir:  t.TempDir(),
+		Timeout:     30 * time.Second,
+	}
+}
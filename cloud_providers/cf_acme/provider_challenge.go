@@ -0,0 +1,172 @@
+package cf_acme
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/cloud_providers/powerdns"
+	"github.com/babbage88/go-acme-cli/cloud_providers/rfc2136"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// ProviderDNSChallenge solves DNS-01 challenges through any dnsprovider.Provider.
+type ProviderDNSChallenge struct {
+	Provider                 dnsprovider.Provider
+	RecursiveNameServers     []string
+	TTL                      int
+	PropagationTimeout       time.Duration
+	PropagationCheckInterval time.Duration
+	records                  map[string]providerChallengeRecord
+	mu                       sync.Mutex
+}
+
+type providerChallengeRecord struct {
+	zone  dnsprovider.Zone
+	fqdn  string
+	value string
+}
+
+func NewProviderDNSChallenge(provider dnsprovider.Provider, recursiveNameServers []string, timeout time.Duration) *ProviderDNSChallenge {
+	return &ProviderDNSChallenge{
+		Provider:                 provider,
+		RecursiveNameServers:     recursiveNameServers,
+		TTL:                      120,
+		PropagationTimeout:       timeout,
+		PropagationCheckInterval: 2 * time.Second,
+		records:                  make(map[string]providerChallengeRecord),
+	}
+}
+
+func (d *ProviderDNSChallenge) Timeout() (timeout, interval time.Duration) {
+	return d.PropagationTimeout, d.PropagationCheckInterval
+}
+
+func (d *ProviderDNSChallenge) Present(domain, token, keyAuth string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	info := dns01.GetChallengeInfo(domain, keyAuth)
+	key := challengeKey(domain, keyAuth)
+	if _, ok := d.records[key]; ok {
+		return nil
+	}
+
+	target := info.EffectiveFQDN
+	ctx := context.Background()
+	zone, err := d.Provider.ZoneForName(ctx, target)
+	if err != nil {
+		slog.Error("error finding zone for challenge record", slog.String("provider", d.Provider.Name()), slog.String("fqdn", target), slog.String("error", err.Error()))
+		return err
+	}
+
+	_, err = d.Provider.CreateTXTRecord(ctx, zone, target, info.Value, d.TTL)
+	if err != nil {
+		slog.Error("error creating challenge txt record", slog.String("provider", d.Provider.Name()), slog.String("fqdn", target), slog.String("error", err.Error()))
+		return err
+	}
+	d.records[key] = providerChallengeRecord{zone: zone, fqdn: target, value: info.Value}
+	return nil
+}
+
+func (d *ProviderDNSChallenge) CleanUp(domain, token, keyAuth string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := challengeKey(domain, keyAuth)
+	record, ok := d.records[key]
+	if !ok {
+		slog.Info("no challenge txt record created for domain, nothing to clean up", slog.String("domain", domain))
+		return nil
+	}
+
+	err := d.Provider.DeleteTXTRecord(context.Background(), record.zone, record.fqdn, record.value)
+	if err != nil {
+		slog.Error("error deleting challenge txt record", slog.String("provider", d.Provider.Name()), slog.String("fqdn", record.fqdn), slog.String("error", err.Error()))
+		return err
+	}
+	delete(d.records, key)
+	return nil
+}
+
+// NewDnsProvider returns the named backend. Cloudflare uses cfToken, other backends read their
+// settings from env.
+func NewDnsProvider(name string, cfToken string) (dnsprovider.Provider, error) {
+	switch name {
+	case "", DnsProviderCloudflare:
+		return NewCloudflareProviderFromToken(cfToken)
+	case rfc2136.ProviderName:
+		return rfc2136.NewProviderFromEnv()
+	case powerdns.ProviderName:
+		return powerdns.NewProviderFromEnv()
+	default:
+		return nil, fmt.Errorf("unsupported dns provider %q, use cloudflare, rfc2136 or powerdns", name)
+	}
+}
+
+// ZoneRoutesConfigured reports whether DNS_ZONE_PROVIDERS assigns backends per zone.
+func ZoneRoutesConfigured() bool {
+	return os.Getenv("DNS_ZONE_PROVIDERS") != ""
+}
+
+// NewDnsProviderFromConfig routes zones to the backends listed in DNS_ZONE_PROVIDERS as
+// zone=provider pairs, sending every other zone to defaultName. Without routes it is NewDnsProvider.
+func NewDnsProviderFromConfig(defaultName string, cfToken string) (dnsprovider.Provider, error) {
+	routes, err := dnsprovider.ParseZoneRoutes(os.Getenv("DNS_ZONE_PROVIDERS"))
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return NewDnsProvider(defaultName, cfToken)
+	}
+
+	providers := make(map[string]dnsprovider.Provider)
+	router := &dnsprovider.Router{Routes: make(map[string]dnsprovider.Provider)}
+	for zone, name := range routes {
+		provider, ok := providers[name]
+		if !ok {
+			provider, err = NewDnsProvider(name, cfToken)
+			if err != nil {
+				slog.Error("error initializing dns provider for zone", slog.String("zone", zone), slog.String("provider", name), slog.String("error", err.Error()))
+				return nil, err
+			}
+			providers[name] = provider
+		}
+		router.Routes[zone] = provider
+	}
+
+	router.Default = providers[defaultName]
+	if router.Default == nil {
+		router.Default, err = NewDnsProvider(defaultName, cfToken)
+		if err != nil {
+			slog.Info("default dns provider unavailable, only routed zones can be used", slog.String("provider", defaultName), slog.String("error", err.Error()))
+			router.Default = nil
+		}
+	}
+	return router, nil
+}
+
+// withDelegatedZoneTokens routes each validation zone in tokens to Cloudflare with the zone's
+// own token, so _acme-challenge CNAMEs into another Cloudflare account work with any backend.
+func withDelegatedZoneTokens(provider dnsprovider.Provider, tokens map[string]string) (dnsprovider.Provider, error) {
+	if len(tokens) == 0 {
+		return provider, nil
+	}
+	router, ok := provider.(*dnsprovider.Router)
+	if !ok {
+		router = &dnsprovider.Router{Routes: make(map[string]dnsprovider.Provider), Default: provider}
+	}
+	for zone, token := range tokens {
+		delegated, err := NewCloudflareProviderFromToken(token)
+		if err != nil {
+			slog.Error("error initializing cloudflare provider for delegated zone", slog.String("zone", zone), slog.String("error", err.Error()))
+			return nil, err
+		}
+		router.Routes[zone] = delegated
+	}
+	return router, nil
+}
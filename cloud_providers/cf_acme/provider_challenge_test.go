@@ -0,0 +1,54 @@
+package cf_acme
+
+import (
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/cloud_providers/powerdns"
+)
+
+func TestDnsChallengeSolverKeepsDelegatedZoneTokens(t *testing.T) {
+	t.Setenv("DNS_ZONE_PROVIDERS", "lab.example.com=powerdns")
+	t.Setenv("PDNS_API_URL", "http://127.0.0.1:8081/")
+	t.Setenv("PDNS_API_KEY", "secret")
+
+	c := &CertificateRenewalRequest{ChallengeZoneTokens: map[string]string{"validation.example.net": "delegated-token"}}
+	solver, err := c.newDnsChallengeSolver("default-token", nil, 0)
+	if err != nil {
+		t.Fatalf("newDnsChallengeSolver: %v", err)
+	}
+	challenge, ok := solver.(*ProviderDNSChallenge)
+	if !ok {
+		t.Fatalf("expected the generic solver with zone routes, got %T", solver)
+	}
+	router, ok := challenge.Provider.(*dnsprovider.Router)
+	if !ok {
+		t.Fatalf("expected a router, got %T", challenge.Provider)
+	}
+	if _, ok := router.Routes["lab.example.com"].(*powerdns.Provider); !ok {
+		t.Fatalf("expected the configured powerdns route, got %T", router.Routes["lab.example.com"])
+	}
+	if _, ok := router.Routes["validation.example.net"].(*CloudflareProvider); !ok {
+		t.Fatalf("expected the delegated zone routed to its own cloudflare token, got %T", router.Routes["validation.example.net"])
+	}
+}
+
+func TestWithDelegatedZoneTokensWrapsProvider(t *testing.T) {
+	defaultProvider, err := NewCloudflareProviderFromToken("default-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := withDelegatedZoneTokens(defaultProvider, nil)
+	if err != nil || provider != dnsprovider.Provider(defaultProvider) {
+		t.Fatalf("expected the provider unchanged without tokens, got %T, %v", provider, err)
+	}
+
+	provider, err = withDelegatedZoneTokens(defaultProvider, map[string]string{"validation.example.net": "delegated-token"})
+	if err != nil {
+		t.Fatalf("withDelegatedZoneTokens: %v", err)
+	}
+	router, ok := provider.(*dnsprovider.Router)
+	if !ok || router.Default != dnsprovider.Provider(defaultProvider) || len(router.Routes) != 1 {
+		t.Fatalf("expected a router around the default provider, got %+v", provider)
+	}
+}
@@ -0,0 +1,158 @@
+package cf_acme
+
+import (
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/joho/godotenv"
+)
+
+const ExistingCertSourcePemFile = "pem"
+
+// revocationReasons maps RFC 5280 CRLReason names to their codes. Code 7 is unused.
+var revocationReasons = map[string]uint{
+	"unspecified":          acme.CRLReasonUnspecified,
+	"keycompromise":        acme.CRLReasonKeyCompromise,
+	"cacompromise":         acme.CRLReasonCACompromise,
+	"affiliationchanged":   acme.CRLReasonAffiliationChanged,
+	"superseded":           acme.CRLReasonSuperseded,
+	"cessationofoperation": acme.CRLReasonCessationOfOperation,
+	"certificatehold":      acme.CRLReasonCertificateHold,
+	"removefromcrl":        acme.CRLReasonRemoveFromCRL,
+	"privilegewithdrawn":   acme.CRLReasonPrivilegeWithdrawn,
+	"aacompromise":         acme.CRLReasonAACompromise,
+}
+
+type RevocationResult struct {
+	Source       string   `json:"source"`
+	Location     string   `json:"location"`
+	DomainNames  []string `json:"domainNames"`
+	SerialNumber string   `json:"serialNumber"`
+	Reason       uint     `json:"reason"`
+	SignedWith   string   `json:"signedWith"`
+	Revoked      bool     `json:"revoked"`
+}
+
+// ParseRevocationReason accepts an RFC 5280 reason code or name, e.g. "1", "keyCompromise" or "key-compromise".
+func ParseRevocationReason(reason string) (uint, error) {
+	if code, err := strconv.ParseUint(reason, 10, 8); err == nil {
+		for _, v := range revocationReasons {
+			if uint(code) == v {
+				return v, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid revocation reason code %d", code)
+	}
+	name := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(reason))
+	code, ok := revocationReasons[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown revocation reason %q", reason)
+	}
+	return code, nil
+}
+
+// loadPemFile reads a single PEM file that may hold the certificate, the chain and the private key.
+func loadPemFile(path string) (map[string][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{"certificate.pem": data}
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			files["private_key.pem"] = pem.EncodeToMemory(block)
+			break
+		}
+	}
+	return files, nil
+}
+
+// CliRevoke revokes the certificate at the CA, signing with the stored account key or,
+// when SignWithCertKey is set, with the certificate's own private key.
+func (r *RevokeCertificateRequest) CliRevoke() (RevocationResult, error) {
+	if r.CertSource == ExistingCertSourceS3 {
+		if err := godotenv.Load(r.EnvFile); err != nil {
+			slog.Warn("error loading .env file", slog.String("error", err.Error()))
+		}
+	}
+
+	existing, err := LoadExistingCertificate(r.CertSource, r.CertPath)
+	if err != nil {
+		slog.Error("error loading certificate to revoke", slog.String("source", r.CertSource), slog.String("path", r.CertPath), slog.String("error", err.Error()))
+		return RevocationResult{}, err
+	}
+
+	result := RevocationResult{
+		Source:       r.CertSource,
+		Location:     r.CertPath,
+		DomainNames:  certcrypto.ExtractDomains(existing.Leaf),
+		SerialNumber: existing.Leaf.SerialNumber.Text(16),
+		Reason:       r.Reason,
+		SignedWith:   "account-key",
+	}
+
+	var client *lego.Client
+	if r.SignWithCertKey {
+		client, err = r.certKeyClient(existing)
+		result.SignedWith = "certificate-key"
+	} else {
+		account := &AcmeAccountRequest{AcmeEmail: r.AcmeEmail, AcmeUrl: r.AcmeUrl, AccountDir: r.AccountDir}
+		client, _, err = account.loadRegisteredAccount()
+	}
+	if err != nil {
+		return result, err
+	}
+
+	reason := r.Reason
+	err = client.Certificate.RevokeWithReason(existing.CertPEM, &reason)
+	if err != nil {
+		slog.Error("error revoking certificate", slog.String("serial", result.SerialNumber), slog.String("error", err.Error()))
+		return result, err
+	}
+	result.Revoked = true
+	printJson(result)
+	return result, nil
+}
+
+// certKeyClient builds an unregistered client that signs requests with the certificate's
+// private key as a JWK, as allowed by RFC 8555 section 7.6.
+func (r *RevokeCertificateRequest) certKeyClient(existing *ExistingCertificate) (*lego.Client, error) {
+	keyPEM := existing.PrivKeyPEM
+	if r.KeyPath != "" {
+		var err error
+		keyPEM, err = os.ReadFile(r.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keyPEM) == 0 {
+		return nil, fmt.Errorf("no private key found for certificate, use --key to specify one")
+	}
+
+	certKey, err := certcrypto.ParsePEMPrivateKey(keyPEM)
+	if err != nil {
+		slog.Error("error parsing certificate private key", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	config := lego.NewConfig(&AcmeUser{Email: r.AcmeEmail, key: certKey})
+	config.CADirURL = r.AcmeUrl
+	client, err := lego.NewClient(config)
+	if err != nil {
+		slog.Error("error creating client", slog.String("error", err.Error()))
+	}
+	return client, err
+}
@@ -0,0 +1,80 @@
+package cf_acme
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+func TestParseRevocationReason(t *testing.T) {
+	tests := []struct {
+		reason  string
+		want    uint
+		wantErr bool
+	}{
+		{reason: "0", want: acme.CRLReasonUnspecified},
+		{reason: "1", want: acme.CRLReasonKeyCompromise},
+		{reason: "keyCompromise", want: acme.CRLReasonKeyCompromise},
+		{reason: "key-compromise", want: acme.CRLReasonKeyCompromise},
+		{reason: "CESSATION_OF_OPERATION", want: acme.CRLReasonCessationOfOperation},
+		{reason: "superseded", want: acme.CRLReasonSuperseded},
+		{reason: "10", want: acme.CRLReasonAACompromise},
+		{reason: "7", wantErr: true},
+		{reason: "11", wantErr: true},
+		{reason: "-1", wantErr: true},
+		{reason: "stolen", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			got, err := ParseRevocationReason(tt.reason)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error for %q, got %d", tt.reason, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("expected %d, got %d, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestLoadPemFile(t *testing.T) {
+	path := writeForeignCert(t, "revoke.example.com", 24*time.Hour)
+	files, err := loadPemFile(path)
+	if err != nil {
+		t.Fatalf("loadPemFile: %v", err)
+	}
+	block, _ := pem.Decode(files["private_key.pem"])
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Fatalf("expected the private key split out, got %q", files["private_key.pem"])
+	}
+	existing, err := LoadExistingCertificate(ExistingCertSourcePemFile, path)
+	if err != nil {
+		t.Fatalf("LoadExistingCertificate: %v", err)
+	}
+	if existing.Leaf.Subject.CommonName != "revoke.example.com" || len(existing.PrivKeyPEM) == 0 {
+		t.Fatalf("expected the certificate and its key, got %s with %d key bytes", existing.Leaf.Subject.CommonName, len(existing.PrivKeyPEM))
+	}
+
+	certOnly := filepath.Join(t.TempDir(), "cert.pem")
+	data, _ := os.ReadFile(path)
+	certBlock, _ := pem.Decode(data)
+	os.WriteFile(certOnly, pem.EncodeToMemory(certBlock), 0o600)
+	files, err = loadPemFile(certOnly)
+	if err != nil {
+		t.Fatalf("loadPemFile: %v", err)
+	}
+	if _, ok := files["private_key.pem"]; ok || len(files["certificate.pem"]) == 0 {
+		t.Fatalf("expected only the certificate, got %v", files)
+	}
+
+	if _, err := loadPemFile(filepath.Join(t.TempDir(), "missing.pem")); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}
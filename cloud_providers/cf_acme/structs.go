@@ -2,34 +2,87 @@ package cf_acme
 
 import (
 	"crypto"
+	"crypto/x509"
 	"time"
 
+	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/registration"
 )
 
 type CertificateData struct {
-	DomainNames     []string `json:"domainName"`
-	CertPEM         string   `json:"cert_pem"`
-	ChainPEM        string   `json:"chain_pem"`
-	Fullchain       string   `json:"fullchain_pem"`
-	FullchainAndKey string   `json:"fullchain_and_key"`
-	PrivKey         string   `json:"priv_key"`
-	ZipDir          string   `json:"zipDir"`
-	S3DownloadUrl   string   `json:"s3DownloadUrl"`
+	DomainNames     []string            `json:"domainName"`
+	CertPEM         string              `json:"cert_pem"`
+	ChainPEM        string              `json:"chain_pem"`
+	Fullchain       string              `json:"fullchain_pem"`
+	FullchainAndKey string              `json:"fullchain_and_key"`
+	PrivKey         string              `json:"priv_key"`
+	ZipDir          string              `json:"zipDir"`
+	S3DownloadUrl   string              `json:"s3DownloadUrl"`
+	RenewalCheck    *RenewalCheckResult `json:"renewalCheck,omitempty"`
 }
 
 type CertificateRenewalRequest struct {
-	EnvFile              string        `json:"envFile"`
-	DomainNames          []string      `json:"domainName"`
-	AcmeEmail            string        `json:"acmeEmail"`
-	AcmeUrl              string        `json:"acmeUrl"`
-	SaveZip              bool          `json:"saveZip"`
-	ZipDir               string        `json:"zipDir"`
-	PushS3               bool          `json:"pushS3"`
-	TTL                  int           `json:"ttl"`
-	Token                string        `json:"token"`
-	RecursiveNameServers []string      `json:"recurseServers"`
-	Timeout              time.Duration `json:"timeout"`
+	EnvFile              string             `json:"envFile"`
+	DomainNames          []string           `json:"domainName"`
+	AcmeEmail            string             `json:"acmeEmail"`
+	AcmeUrl              string             `json:"acmeUrl"`
+	SaveZip              bool               `json:"saveZip"`
+	ZipDir               string             `json:"zipDir"`
+	PushS3               bool               `json:"pushS3"`
+	TTL                  int                `json:"ttl"`
+	Token                string             `json:"token"`
+	RecursiveNameServers []string           `json:"recurseServers"`
+	Timeout              time.Duration      `json:"timeout"`
+	AccountDir           string             `json:"accountDir"`
+	ExistingCertSource   string             `json:"existingCertSource"`
+	ExistingCertPath     string             `json:"existingCertPath"`
+	RenewBefore          time.Duration      `json:"renewBefore"`
+	Force                bool               `json:"force"`
+	UseARI               bool               `json:"useAri"`
+	KeyType              certcrypto.KeyType `json:"keyType"`
+	ReuseKey             bool               `json:"reuseKey"`
+	CsrFile              string             `json:"csrFile"`
+	EabKid               string             `json:"eabKid"`
+	EabHmac              string             `json:"-"`
+	ChallengeZoneTokens  map[string]string  `json:"-"`
+	Challenge            string             `json:"challenge"`
+	DnsProvider          string             `json:"dnsProvider"`
+	HttpMode             string             `json:"httpMode"`
+	HttpAddress          string             `json:"httpAddress"`
+	HttpWebroot          string             `json:"httpWebroot"`
+	HttpS3Bucket         string             `json:"httpS3Bucket"`
+	HttpS3Prefix         string             `json:"httpS3Prefix"`
+	TlsAlpnAddress       string             `json:"tlsAlpnAddress"`
+	csr                  *x509.CertificateRequest
+	existing             *ExistingCertificate
+	renewalCheck         *RenewalCheckResult
+}
+
+type AcmeAccountRequest struct {
+	AcmeEmail  string `json:"acmeEmail"`
+	AcmeUrl    string `json:"acmeUrl"`
+	AccountDir string `json:"accountDir"`
+}
+
+type RevokeCertificateRequest struct {
+	EnvFile         string `json:"envFile"`
+	AcmeEmail       string `json:"acmeEmail"`
+	AcmeUrl         string `json:"acmeUrl"`
+	AccountDir      string `json:"accountDir"`
+	CertSource      string `json:"certSource"`
+	CertPath        string `json:"certPath"`
+	KeyPath         string `json:"keyPath"`
+	Reason          uint   `json:"reason"`
+	SignWithCertKey bool   `json:"signWithCertKey"`
+}
+
+type AcmeAccountInfo struct {
+	Email         string                 `json:"email"`
+	AcmeUrl       string                 `json:"acmeUrl"`
+	AccountDir    string                 `json:"accountDir"`
+	KeyType       string                 `json:"keyType"`
+	KeyThumbprint string                 `json:"keyThumbprint"`
+	Registration  *registration.Resource `json:"registration"`
 }
 
 type AcmeUser struct {
@@ -0,0 +1,31 @@
+package cf_acme
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+	"github.com/go-acme/lego/v4/lego"
+)
+
+// setTlsAlpnProvider answers acme-tls/1 challenges on TlsAlpnAddress, for hosts where port 80
+// is closed and DNS is managed outside Cloudflare.
+func (c *CertificateRenewalRequest) setTlsAlpnProvider(client *lego.Client) error {
+	address := c.TlsAlpnAddress
+	if address == "" {
+		address = ":443"
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid tls-alpn-01 listen address %q: %w", address, err)
+	}
+
+	err = client.Challenge.SetTLSALPN01Provider(tlsalpn01.NewProviderServer(host, port))
+	if err != nil {
+		slog.Error("Failed to set TLS-ALPN challenge.", slog.String("error", err.Error()))
+		return err
+	}
+	slog.Info("using tls-alpn-01 challenge", slog.String("address", address))
+	return nil
+}
@@ -0,0 +1,43 @@
+package cf_acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestRenewWithTlsAlpnChallenge(t *testing.T) {
+	pebbleBinary(t)
+	tlsPort := freePort(t)
+	srv := startPebble(t, pebbleOptions{DnsServer: startLoopbackResolver(t), TlsPort: tlsPort})
+
+	c := newPebbleRequest(t, srv, "alpn.example.com", "www.alpn.example.com")
+	c.Challenge = ChallengeTlsAlpn01
+	c.TlsAlpnAddress = fmt.Sprintf("127.0.0.1:%d", tlsPort)
+	certData, err := c.Renew("", nil, 0)
+	if err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(certData.CertPEM))
+	if block == nil {
+		t.Fatal("expected a PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(cert.DNSNames)
+	if !slices.Equal(cert.DNSNames, []string{"alpn.example.com", "www.alpn.example.com"}) {
+		t.Fatalf("unexpected certificate names %v", cert.DNSNames)
+	}
+}
+
+func TestSetTlsAlpnProviderRejectsInvalidAddress(t *testing.T) {
+	c := &CertificateRenewalRequest{Challenge: ChallengeTlsAlpn01, TlsAlpnAddress: "443"}
+	if err := c.setTlsAlpnProvider(nil); err == nil {
+		t.Fatal("expected an error for a listen address without a port")
+	}
+}
@@ -35,6 +35,10 @@ func saveToZip(filename string, certPEM []byte, keyPEM []byte, issuerCA []byte)
 	}
 
 	for name, content := range files {
+		// Certificates obtained from a CSR have no private key to store.
+		if len(content) == 0 {
+			continue
+		}
 		writer, err := zipWriter.Create(name)
 
 		if err != nil {
@@ -63,6 +67,9 @@ func saveZipToBuffer(objectName string, certPEM, keyPEM, issuerCA []byte) (*byte
 
 	// Add each file to the ZIP archive
 	for name, content := range files {
+		if len(content) == 0 {
+			continue
+		}
 		writer, err := zipWriter.Create(name)
 		if err != nil {
 			return buf, fmt.Errorf("failed to create zip entry %s: %w", name, err)
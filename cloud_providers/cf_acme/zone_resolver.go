@@ -0,0 +1,117 @@
+package cf_acme
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/cloudflare/cloudflare-go"
+	"golang.org/x/net/publicsuffix"
+)
+
+type ZoneMatch struct {
+	Domain   string `json:"domain"`
+	ZoneName string `json:"zoneName"`
+	ZoneID   string `json:"zoneId"`
+}
+
+// ZoneResolver finds the Cloudflare zone holding a name. Zones the token can see are listed
+// once and every lookup is cached, so a run only pays for a single zone listing.
+type ZoneResolver struct {
+	api      *cloudflare.API
+	mu       sync.Mutex
+	zones    map[string]string
+	resolved map[string]ZoneMatch
+}
+
+var (
+	zoneResolversMu sync.Mutex
+	zoneResolvers   = make(map[string]*ZoneResolver)
+)
+
+func NewZoneResolver(api *cloudflare.API) *ZoneResolver {
+	return &ZoneResolver{api: api, resolved: make(map[string]ZoneMatch)}
+}
+
+// ZoneResolverForApi returns the resolver shared by every client using the same API token.
+func ZoneResolverForApi(api *cloudflare.API) *ZoneResolver {
+	zoneResolversMu.Lock()
+	defer zoneResolversMu.Unlock()
+	resolver, ok := zoneResolvers[api.APIToken]
+	if !ok {
+		resolver = NewZoneResolver(api)
+		zoneResolvers[api.APIToken] = resolver
+	}
+	return resolver
+}
+
+// ZoneCandidates returns domain and each parent down to the registrable domain from the
+// public suffix list, longest first. foo.lab.example.co.uk yields foo.lab.example.co.uk,
+// lab.example.co.uk and example.co.uk.
+func ZoneCandidates(domain string) ([]string, error) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine registrable domain for %s: %w", domain, err)
+	}
+
+	candidates := []string{}
+	for {
+		candidates = append(candidates, name)
+		if name == registrable {
+			break
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+	return candidates, nil
+}
+
+func (r *ZoneResolver) loadZones() error {
+	if r.zones != nil {
+		return nil
+	}
+	resp, err := r.api.ListZonesContext(context.Background())
+	if err != nil {
+		slog.Error("error listing cloudflare zones", slog.String("error", err.Error()))
+		return err
+	}
+	r.zones = make(map[string]string, len(resp.Result))
+	for _, zone := range resp.Result {
+		r.zones[strings.ToLower(zone.Name)] = zone.ID
+	}
+	return nil
+}
+
+// Resolve returns the longest zone visible to the token that contains domain.
+func (r *ZoneResolver) Resolve(domain string) (ZoneMatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if match, ok := r.resolved[domain]; ok {
+		return match, nil
+	}
+
+	candidates, err := ZoneCandidates(domain)
+	if err != nil {
+		return ZoneMatch{}, err
+	}
+	err = r.loadZones()
+	if err != nil {
+		return ZoneMatch{}, err
+	}
+
+	for _, candidate := range candidates {
+		if zoneId, ok := r.zones[candidate]; ok {
+			match := ZoneMatch{Domain: domain, ZoneName: candidate, ZoneID: zoneId}
+			r.resolved[domain] = match
+			return match, nil
+		}
+	}
+	return ZoneMatch{}, fmt.Errorf("no cloudflare zone visible to this token contains %s", domain)
+}
@@ -0,0 +1,89 @@
+package cf_acme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+func TestZoneCandidates(t *testing.T) {
+	tests := []struct {
+		domain  string
+		want    []string
+		wantErr bool
+	}{
+		{domain: "foo.lab.example.com", want: []string{"foo.lab.example.com", "lab.example.com", "example.com"}},
+		{domain: "WWW.Example.co.uk.", want: []string{"www.example.co.uk", "example.co.uk"}},
+		{domain: "*.blog.user.github.io", want: []string{"blog.user.github.io", "user.github.io"}},
+		{domain: "example.com", want: []string{"example.com"}},
+		{domain: "co.uk", wantErr: true},
+		{domain: "github.io", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, err := ZoneCandidates(tt.domain)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error for the public suffix %s, got %q", tt.domain, got)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %q, got %q, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestZoneResolverResolve(t *testing.T) {
+	listings := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listings.Add(1)
+		w.Write([]byte(`{"success":true,"errors":[],"messages":[],"result":[
+			{"id":"zone-com","name":"example.com"},
+			{"id":"zone-lab","name":"lab.example.com"},
+			{"id":"zone-uk","name":"Example.co.uk"}
+		],"result_info":{"page":1,"per_page":50,"total_pages":1,"count":3,"total_count":3}}`))
+	}))
+	t.Cleanup(srv.Close)
+	api, err := cloudflare.NewWithAPIToken("test-token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := NewZoneResolver(api)
+
+	tests := []struct {
+		domain  string
+		zone    string
+		zoneID  string
+		wantErr bool
+	}{
+		{domain: "_acme-challenge.foo.lab.example.com", zone: "lab.example.com", zoneID: "zone-lab"},
+		{domain: "lab.example.com", zone: "lab.example.com", zoneID: "zone-lab"},
+		{domain: "www.example.com", zone: "example.com", zoneID: "zone-com"},
+		{domain: "*.www.example.co.uk", zone: "example.co.uk", zoneID: "zone-uk"},
+		{domain: "www.example.org", wantErr: true},
+		{domain: "co.uk", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			match, err := resolver.Resolve(tt.domain)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected no zone for %s, got %+v", tt.domain, match)
+				}
+				return
+			}
+			if err != nil || match.ZoneName != tt.zone || match.ZoneID != tt.zoneID {
+				t.Fatalf("expected zone %s (%s), got %+v, %v", tt.zone, tt.zoneID, match, err)
+			}
+		})
+	}
+	if listings.Load() != 1 {
+		t.Fatalf("expected the zones listed once, got %d listings", listings.Load())
+	}
+}
@@ -0,0 +1,192 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// DefaultPerPage is the page size used when listing through a PagedLister.
+	DefaultPerPage = 100
+	// DefaultPageConcurrency bounds the pages fetched at once.
+	DefaultPageConcurrency = 4
+)
+
+type PageInfo struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"perPage"`
+	Count      int `json:"count"`
+	Total      int `json:"total"`
+	TotalPages int `json:"totalPages"`
+}
+
+// PagedLister is implemented by providers whose API pages record listings, so large zones can be
+// fetched a page at a time.
+type PagedLister interface {
+	ListRecordsPage(ctx context.Context, zone Zone, filter RecordFilter, page int, perPage int) ([]Record, PageInfo, error)
+}
+
+// FetchPages fetches page 1 to learn the page count, then the remaining pages with at most
+// concurrency requests in flight. Pages are handed to fn in page order as soon as they and every
+// page before them have arrived. limit > 0 stops after the first limit items.
+func FetchPages[T any](ctx context.Context, perPage int, concurrency int, limit int, fetch func(ctx context.Context, page int, perPage int) ([]T, PageInfo, error), fn func([]T) error) (PageInfo, error) {
+	if perPage <= 0 {
+		perPage = DefaultPerPage
+	}
+	first, info, err := fetch(ctx, 1, perPage)
+	if err != nil {
+		return info, err
+	}
+
+	emitted := 0
+	emit := func(items []T) error {
+		if limit > 0 && emitted+len(items) > limit {
+			items = items[:limit-emitted]
+		}
+		emitted += len(items)
+		if len(items) == 0 {
+			return nil
+		}
+		return fn(items)
+	}
+	if err := emit(first); err != nil {
+		return info, err
+	}
+
+	lastPage := info.TotalPages
+	if limit > 0 {
+		lastPage = min(lastPage, (limit+perPage-1)/perPage)
+	}
+	if lastPage <= 1 {
+		info.Count = emitted
+		return info, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type pageResult struct {
+		page  int
+		items []T
+		err   error
+	}
+	results := make(chan pageResult)
+	slots := make(chan struct{}, max(concurrency, 1))
+	go func() {
+		for page := 2; page <= lastPage; page++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				items, _, err := fetch(ctx, page, perPage)
+				select {
+				case results <- pageResult{page: page, items: items, err: err}:
+				case <-ctx.Done():
+				}
+			}()
+		}
+	}()
+
+	pending := make(map[int][]T)
+	next := 2
+	for next <= lastPage {
+		var result pageResult
+		select {
+		case result = <-results:
+			<-slots
+		case <-ctx.Done():
+			return info, ctx.Err()
+		}
+		if result.err != nil {
+			return info, fmt.Errorf("error fetching page %d: %w", result.page, result.err)
+		}
+		pending[result.page] = result.items
+		for items, ok := pending[next]; ok; items, ok = pending[next] {
+			delete(pending, next)
+			if err := emit(items); err != nil {
+				return info, err
+			}
+			next++
+		}
+	}
+	info.Count = emitted
+	return info, nil
+}
+
+// pagedProvider returns the backend serving zone, looking through a Router.
+func pagedProvider(p Provider, zone Zone) (Provider, error) {
+	if router, ok := p.(*Router); ok {
+		return router.ProviderFor(zone.Name)
+	}
+	return p, nil
+}
+
+// StreamRecords passes the records of zone to fn a page at a time. Paged backends fetch up to
+// concurrency pages at once, others hand the whole listing to fn in one call.
+func StreamRecords(ctx context.Context, p Provider, zone Zone, filter RecordFilter, perPage int, concurrency int, limit int, fn func([]Record) error) (PageInfo, error) {
+	p, err := pagedProvider(p, zone)
+	if err != nil {
+		return PageInfo{}, err
+	}
+	if lister, ok := p.(PagedLister); ok {
+		fetch := func(ctx context.Context, page int, perPage int) ([]Record, PageInfo, error) {
+			return lister.ListRecordsPage(ctx, zone, filter, page, perPage)
+		}
+		return FetchPages(ctx, perPage, concurrency, limit, fetch, fn)
+	}
+
+	records, err := p.ListRecords(ctx, zone, filter)
+	if err != nil {
+		return PageInfo{}, err
+	}
+	info := PageInfo{Page: 1, PerPage: len(records), Total: len(records), TotalPages: 1}
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	info.Count = len(records)
+	if len(records) == 0 {
+		return info, nil
+	}
+	return info, fn(records)
+}
+
+// ListPage returns one page of the records of zone. Backends that cannot page have their full
+// listing sliced.
+func ListPage(ctx context.Context, p Provider, zone Zone, filter RecordFilter, page int, perPage int) ([]Record, PageInfo, error) {
+	p, err := pagedProvider(p, zone)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	if perPage <= 0 {
+		perPage = DefaultPerPage
+	}
+	if lister, ok := p.(PagedLister); ok {
+		return lister.ListRecordsPage(ctx, zone, filter, page, perPage)
+	}
+
+	records, err := p.ListRecords(ctx, zone, filter)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	info := PageInfo{Page: page, PerPage: perPage, Total: len(records), TotalPages: (len(records) + perPage - 1) / perPage}
+	start := min((page-1)*perPage, len(records))
+	end := min(start+perPage, len(records))
+	info.Count = end - start
+	return records[start:end], info, nil
+}
+
+// CountRecords returns the number of records in zone, asking paged backends for a single small
+// page and reading the total from it.
+func CountRecords(ctx context.Context, p Provider, zone Zone) (int, error) {
+	p, err := pagedProvider(p, zone)
+	if err != nil {
+		return 0, err
+	}
+	if lister, ok := p.(PagedLister); ok {
+		_, info, err := lister.ListRecordsPage(ctx, zone, RecordFilter{}, 1, 5)
+		return info.Total, err
+	}
+	records, err := p.ListRecords(ctx, zone, RecordFilter{})
+	return len(records), err
+}
@@ -0,0 +1,123 @@
+package dnsprovider
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPages serves total numbered items perPage at a time. block, when set, is called before a
+// page is returned and can hold it back.
+type testPages struct {
+	total int
+	block func(ctx context.Context, page int) error
+	mu    sync.Mutex
+	pages []int
+}
+
+func (p *testPages) fetch(ctx context.Context, page int, perPage int) ([]int, PageInfo, error) {
+	p.mu.Lock()
+	p.pages = append(p.pages, page)
+	p.mu.Unlock()
+	if p.block != nil {
+		if err := p.block(ctx, page); err != nil {
+			return nil, PageInfo{}, err
+		}
+	}
+	items := []int{}
+	for i := (page - 1) * perPage; i < min(page*perPage, p.total); i++ {
+		items = append(items, i)
+	}
+	info := PageInfo{Page: page, PerPage: perPage, Count: len(items), Total: p.total, TotalPages: (p.total + perPage - 1) / perPage}
+	return items, info, nil
+}
+
+func (p *testPages) fetched() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pages := slices.Clone(p.pages)
+	slices.Sort(pages)
+	return pages
+}
+
+// collect returns fn for FetchPages appending the items it is given to items.
+func collect(items *[]int) func([]int) error {
+	return func(page []int) error {
+		*items = append(*items, page...)
+		return nil
+	}
+}
+
+func TestFetchPagesEmitsInPageOrder(t *testing.T) {
+	// Page 2 is held back until page 4 has been fetched, so later pages arrive first.
+	page4 := make(chan struct{})
+	p := &testPages{total: 10, block: func(ctx context.Context, page int) error {
+		switch page {
+		case 2:
+			<-page4
+		case 4:
+			close(page4)
+		}
+		return nil
+	}}
+
+	items := []int{}
+	info, err := FetchPages(context.Background(), 3, 3, 0, p.fetch, collect(&items))
+	if err != nil {
+		t.Fatalf("FetchPages: %v", err)
+	}
+	if !slices.Equal(items, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("expected every item in order, got %v", items)
+	}
+	if info.Count != 10 || info.Total != 10 || info.TotalPages != 4 {
+		t.Fatalf("unexpected page info %+v", info)
+	}
+}
+
+func TestFetchPagesStopsAtLimit(t *testing.T) {
+	p := &testPages{total: 10}
+	items := []int{}
+	info, err := FetchPages(context.Background(), 3, 4, 5, p.fetch, collect(&items))
+	if err != nil {
+		t.Fatalf("FetchPages: %v", err)
+	}
+	if !slices.Equal(items, []int{0, 1, 2, 3, 4}) || info.Count != 5 {
+		t.Fatalf("expected the first 5 items, got %v and count %d", items, info.Count)
+	}
+	if pages := p.fetched(); !slices.Equal(pages, []int{1, 2}) {
+		t.Fatalf("expected only the pages holding the limit fetched, got %v", pages)
+	}
+}
+
+func TestFetchPagesErrorCancelsOtherPages(t *testing.T) {
+	cancelled := make(chan struct{})
+	p := &testPages{total: 20, block: func(ctx context.Context, page int) error {
+		switch page {
+		case 2:
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		case 3:
+			return errors.New("backend unavailable")
+		}
+		return nil
+	}}
+
+	items := []int{}
+	_, err := FetchPages(context.Background(), 2, 2, 0, p.fetch, collect(&items))
+	if err == nil || !strings.Contains(err.Error(), "page 3") {
+		t.Fatalf("expected the page 3 error, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the fetch of page 2 to be cancelled")
+	}
+	if !slices.Equal(items, []int{0, 1}) {
+		t.Fatalf("expected only page 1 emitted, got %v", items)
+	}
+}
@@ -0,0 +1,132 @@
+package dnsprovider
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrRecordNotFound = errors.New("dns record not found")
+
+// ErrRateLimited is wrapped by providers when the backend asks the client to slow down.
+var ErrRateLimited = errors.New("dns provider rate limit exceeded")
+
+type Zone struct {
+	ID   string `json:"zoneId"`
+	Name string `json:"zoneName"`
+}
+
+// Record is a single resource record. Names are fully qualified without the trailing dot and
+// ID is whatever the backend needs to address the record again. Comment is nil when unset, so
+// updates can tell leaving the comment alone from clearing it.
+type Record struct {
+	ID         string    `json:"id"`
+	ZoneID     string    `json:"zoneId"`
+	ZoneName   string    `json:"zoneName"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Content    string    `json:"content"`
+	TTL        int       `json:"ttl"`
+	Priority   *uint16   `json:"priority,omitempty"`
+	Proxied    *bool     `json:"proxied,omitempty"`
+	Comment    *string   `json:"comment,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	CreatedOn  time.Time `json:"createdOn"`
+	ModifiedOn time.Time `json:"modifiedOn"`
+	// Native is the backend's own type for the record when it has one, kept so callers that
+	// work with that type get back every field the backend returned.
+	Native any `json:"-"`
+}
+
+// CommentText returns the comment, empty when it is unset.
+func (r Record) CommentText() string {
+	if r.Comment == nil {
+		return ""
+	}
+	return *r.Comment
+}
+
+// RecordFilter narrows ListRecords. Empty fields match everything.
+type RecordFilter struct {
+	Name     string
+	Type     string
+	Content  string
+	Comment  string
+	Tags     []string
+	Priority *uint16
+	Proxied  *bool
+}
+
+// Provider is a DNS backend usable by the dns commands and for ACME DNS-01 challenges.
+type Provider interface {
+	// Name identifies the backend, e.g. cloudflare or rfc2136.
+	Name() string
+	// ZoneForName returns the zone holding name.
+	ZoneForName(ctx context.Context, name string) (Zone, error)
+	ListRecords(ctx context.Context, zone Zone, filter RecordFilter) ([]Record, error)
+	GetRecord(ctx context.Context, zone Zone, id string) (Record, error)
+	CreateRecord(ctx context.Context, zone Zone, record Record) (Record, error)
+	// UpdateRecord changes the set fields of record on the record with record.ID, zero values
+	// are left unchanged. A Comment pointing to an empty string removes the comment.
+	UpdateRecord(ctx context.Context, zone Zone, record Record) (Record, error)
+	DeleteRecord(ctx context.Context, zone Zone, id string) error
+	// CreateTXTRecord adds value to the TXT record set at fqdn, leaving other values in place.
+	CreateTXTRecord(ctx context.Context, zone Zone, fqdn string, value string, ttl int) (Record, error)
+	// DeleteTXTRecord removes only value from the TXT record set at fqdn.
+	DeleteTXTRecord(ctx context.Context, zone Zone, fqdn string, value string) error
+}
+
+// Matches reports whether record satisfies every set field of the filter. Backends without
+// server side filtering use it on the full record list.
+func (f RecordFilter) Matches(record Record) bool {
+	if f.Name != "" && !strings.EqualFold(TrimDot(f.Name), record.Name) {
+		return false
+	}
+	if f.Type != "" && !strings.EqualFold(f.Type, record.Type) {
+		return false
+	}
+	if f.Content != "" && f.Content != record.Content {
+		return false
+	}
+	if f.Comment != "" && f.Comment != record.CommentText() {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(record.Tags, tag) {
+			return false
+		}
+	}
+	if f.Priority != nil && (record.Priority == nil || *record.Priority != *f.Priority) {
+		return false
+	}
+	if f.Proxied != nil && (record.Proxied == nil || *record.Proxied != *f.Proxied) {
+		return false
+	}
+	return true
+}
+
+// TrimDot lowercases name and strips the trailing dot of a fully qualified name.
+func TrimDot(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// InZone reports whether name is zoneName or below it. Both must already be trimmed with TrimDot.
+func InZone(name string, zoneName string) bool {
+	return name == zoneName || strings.HasSuffix(name, "."+zoneName)
+}
+
+// QualifyName returns the fully qualified name of record name in zoneName without the trailing
+// dot. @, empty names and names outside the zone are taken as relative to it.
+func QualifyName(name string, zoneName string) string {
+	name = TrimDot(name)
+	zoneName = TrimDot(zoneName)
+	switch {
+	case name == "" || name == "@":
+		return zoneName
+	case !InZone(name, zoneName):
+		return name + "." + zoneName
+	}
+	return name
+}
@@ -0,0 +1,121 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Router sends each zone to the provider configured for it, so one run can manage zones split
+// across Cloudflare, PowerDNS and RFC 2136 servers.
+type Router struct {
+	// Routes maps zone names to providers, the longest zone containing a name wins.
+	Routes map[string]Provider
+	// Default handles zones without a route, nil means they are rejected.
+	Default Provider
+}
+
+// ParseZoneRoutes parses comma separated zone=provider pairs, e.g.
+// "lab.example.com=powerdns,example.com=cloudflare".
+func ParseZoneRoutes(config string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		zone, provider, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(zone) == "" || strings.TrimSpace(provider) == "" {
+			return routes, fmt.Errorf("invalid zone provider %q, expected zone=provider", pair)
+		}
+		routes[TrimDot(strings.TrimSpace(zone))] = strings.TrimSpace(provider)
+	}
+	return routes, nil
+}
+
+func (r *Router) Name() string {
+	return "router"
+}
+
+// ProviderFor returns the provider routed for name.
+func (r *Router) ProviderFor(name string) (Provider, error) {
+	name = TrimDot(name)
+	var provider Provider
+	longest := -1
+	for zone, routed := range r.Routes {
+		if InZone(name, zone) && len(zone) > longest {
+			provider, longest = routed, len(zone)
+		}
+	}
+	if provider == nil {
+		provider = r.Default
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("no dns provider configured for %s", name)
+	}
+	return provider, nil
+}
+
+func (r *Router) ZoneForName(ctx context.Context, name string) (Zone, error) {
+	provider, err := r.ProviderFor(name)
+	if err != nil {
+		return Zone{}, err
+	}
+	return provider.ZoneForName(ctx, name)
+}
+
+func (r *Router) ListRecords(ctx context.Context, zone Zone, filter RecordFilter) ([]Record, error) {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ListRecords(ctx, zone, filter)
+}
+
+func (r *Router) GetRecord(ctx context.Context, zone Zone, id string) (Record, error) {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return Record{}, err
+	}
+	return provider.GetRecord(ctx, zone, id)
+}
+
+func (r *Router) CreateRecord(ctx context.Context, zone Zone, record Record) (Record, error) {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return Record{}, err
+	}
+	return provider.CreateRecord(ctx, zone, record)
+}
+
+func (r *Router) UpdateRecord(ctx context.Context, zone Zone, record Record) (Record, error) {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return Record{}, err
+	}
+	return provider.UpdateRecord(ctx, zone, record)
+}
+
+func (r *Router) DeleteRecord(ctx context.Context, zone Zone, id string) error {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return err
+	}
+	return provider.DeleteRecord(ctx, zone, id)
+}
+
+func (r *Router) CreateTXTRecord(ctx context.Context, zone Zone, fqdn string, value string, ttl int) (Record, error) {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return Record{}, err
+	}
+	return provider.CreateTXTRecord(ctx, zone, fqdn, value, ttl)
+}
+
+func (r *Router) DeleteTXTRecord(ctx context.Context, zone Zone, fqdn string, value string) error {
+	provider, err := r.ProviderFor(zone.Name)
+	if err != nil {
+		return err
+	}
+	return provider.DeleteTXTRecord(ctx, zone, fqdn, value)
+}
@@ -0,0 +1,105 @@
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ZoneInfo describes a zone in account wide listings.
+type ZoneInfo struct {
+	Zone
+	Status      string   `json:"status"`
+	Plan        string   `json:"plan"`
+	NameServers []string `json:"nameServers"`
+	RecordCount int      `json:"recordCount"`
+}
+
+// ZoneLister is implemented by providers that can enumerate every zone the credentials reach.
+type ZoneLister interface {
+	ListZones(ctx context.Context) ([]ZoneInfo, error)
+}
+
+// ZoneDescriber is implemented by providers that read a zone's nameservers and record count from
+// the same request, so listing zones does not need a request per zone for each.
+type ZoneDescriber interface {
+	DescribeZone(ctx context.Context, info *ZoneInfo) error
+}
+
+// DescribeZone fills in the record count of info, and its nameservers when the provider only
+// reports them per zone.
+func DescribeZone(ctx context.Context, p Provider, info *ZoneInfo) error {
+	p, err := pagedProvider(p, info.Zone)
+	if err != nil {
+		return err
+	}
+	if describer, ok := p.(ZoneDescriber); ok {
+		return describer.DescribeZone(ctx, info)
+	}
+	info.RecordCount, err = CountRecords(ctx, p, info.Zone)
+	return err
+}
+
+// ListZones returns the zones p manages, sorted by name. A Router lists the zones of each of its
+// providers that are routed back to that provider, and looks up routed zones of providers that
+// cannot list.
+func ListZones(ctx context.Context, p Provider) ([]ZoneInfo, error) {
+	router, ok := p.(*Router)
+	if !ok {
+		lister, ok := p.(ZoneLister)
+		if !ok {
+			return nil, fmt.Errorf("dns provider %s cannot list zones", p.Name())
+		}
+		zones, err := lister.ListZones(ctx)
+		sortZones(zones)
+		return zones, err
+	}
+
+	providers := []Provider{}
+	if router.Default != nil {
+		providers = append(providers, router.Default)
+	}
+	for _, v := range router.Routes {
+		providers = append(providers, v)
+	}
+
+	zones := []ZoneInfo{}
+	seen := make(map[string]bool)
+	listed := make(map[Provider]bool)
+	for _, provider := range providers {
+		lister, ok := provider.(ZoneLister)
+		if !ok || listed[provider] {
+			continue
+		}
+		listed[provider] = true
+		found, err := lister.ListZones(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, zone := range found {
+			routed, err := router.ProviderFor(zone.Name)
+			if err != nil || routed != provider || seen[zone.Name] {
+				continue
+			}
+			seen[zone.Name] = true
+			zones = append(zones, zone)
+		}
+	}
+	for name, provider := range router.Routes {
+		if listed[provider] || seen[name] {
+			continue
+		}
+		zone, err := provider.ZoneForName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		seen[name] = true
+		zones = append(zones, ZoneInfo{Zone: zone})
+	}
+	sortZones(zones)
+	return zones, nil
+}
+
+func sortZones(zones []ZoneInfo) {
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+}
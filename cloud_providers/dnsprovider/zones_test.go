@@ -0,0 +1,180 @@
+package dnsprovider
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var errTestUnsupported = errors.New("not supported by the test provider")
+
+// testZoneProvider keeps the records of its zones in memory and can only read them.
+type testZoneProvider struct {
+	name    string
+	records map[string][]Record
+}
+
+func (p *testZoneProvider) Name() string { return p.name }
+
+func (p *testZoneProvider) ZoneForName(ctx context.Context, name string) (Zone, error) {
+	zone := ""
+	for v := range p.records {
+		if InZone(TrimDot(name), v) && len(v) > len(zone) {
+			zone = v
+		}
+	}
+	if zone == "" {
+		return Zone{}, errTestUnsupported
+	}
+	return Zone{ID: p.name + "-" + zone, Name: zone}, nil
+}
+
+func (p *testZoneProvider) ListRecords(ctx context.Context, zone Zone, filter RecordFilter) ([]Record, error) {
+	records := []Record{}
+	for _, v := range p.records[zone.Name] {
+		if filter.Matches(v) {
+			records = append(records, v)
+		}
+	}
+	return records, nil
+}
+
+func (p *testZoneProvider) GetRecord(ctx context.Context, zone Zone, id string) (Record, error) {
+	return Record{}, errTestUnsupported
+}
+
+func (p *testZoneProvider) CreateRecord(ctx context.Context, zone Zone, record Record) (Record, error) {
+	return Record{}, errTestUnsupported
+}
+
+func (p *testZoneProvider) UpdateRecord(ctx context.Context, zone Zone, record Record) (Record, error) {
+	return Record{}, errTestUnsupported
+}
+
+func (p *testZoneProvider) DeleteRecord(ctx context.Context, zone Zone, id string) error {
+	return errTestUnsupported
+}
+
+func (p *testZoneProvider) CreateTXTRecord(ctx context.Context, zone Zone, fqdn string, value string, ttl int) (Record, error) {
+	return Record{}, errTestUnsupported
+}
+
+func (p *testZoneProvider) DeleteTXTRecord(ctx context.Context, zone Zone, fqdn string, value string) error {
+	return errTestUnsupported
+}
+
+// listingZoneProvider lists every zone it holds records for, with the given nameservers.
+type listingZoneProvider struct {
+	*testZoneProvider
+	nameServers []string
+}
+
+func (p *listingZoneProvider) ListZones(ctx context.Context) ([]ZoneInfo, error) {
+	zones := []ZoneInfo{}
+	for name := range p.records {
+		zone, _ := p.ZoneForName(ctx, name)
+		zones = append(zones, ZoneInfo{Zone: zone, Status: "active", NameServers: p.nameServers})
+	}
+	return zones, nil
+}
+
+// pagedZoneProvider pages listings and counts the pages requested.
+type pagedZoneProvider struct {
+	*testZoneProvider
+	requests int
+}
+
+func (p *pagedZoneProvider) ListRecordsPage(ctx context.Context, zone Zone, filter RecordFilter, page int, perPage int) ([]Record, PageInfo, error) {
+	p.requests++
+	return ListPage(ctx, p.testZoneProvider, zone, filter, page, perPage)
+}
+
+// describingZoneProvider reports nameservers and record counts per zone itself.
+type describingZoneProvider struct {
+	*testZoneProvider
+}
+
+func (p *describingZoneProvider) DescribeZone(ctx context.Context, info *ZoneInfo) error {
+	info.NameServers = []string{"ns1." + info.Name}
+	info.RecordCount = 42
+	return nil
+}
+
+func testRecords(n int) []Record {
+	records := make([]Record, n)
+	for i := range records {
+		records[i] = Record{Name: "host.example.com", Type: "A", Content: "192.0.2.1"}
+	}
+	return records
+}
+
+func zoneNames(zones []ZoneInfo) string {
+	names := []string{}
+	for _, v := range zones {
+		names = append(names, v.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestListZones(t *testing.T) {
+	ctx := context.Background()
+	plain := &testZoneProvider{name: "plain", records: map[string][]Record{"lab.example.com": nil}}
+	if _, err := ListZones(ctx, plain); err == nil {
+		t.Fatal("expected an error for a provider that cannot list zones")
+	}
+
+	lister := &listingZoneProvider{testZoneProvider: &testZoneProvider{name: "default", records: map[string][]Record{"example.org": nil, "example.com": nil, "lab.example.com": nil}}, nameServers: []string{"ns1.example.net"}}
+	zones, err := ListZones(ctx, lister)
+	if err != nil || zoneNames(zones) != "example.com example.org lab.example.com" {
+		t.Fatalf("expected the zones sorted by name, got %q, %v", zoneNames(zones), err)
+	}
+
+	other := &listingZoneProvider{testZoneProvider: &testZoneProvider{name: "other", records: map[string][]Record{"example.net": nil, "example.org": nil}}}
+	router := &Router{Default: lister, Routes: map[string]Provider{"lab.example.com": plain, "example.net": other}}
+	zones, err = ListZones(ctx, router)
+	if err != nil {
+		t.Fatalf("ListZones: %v", err)
+	}
+	if zoneNames(zones) != "example.com example.net example.org lab.example.com" {
+		t.Fatalf("expected each zone once from the provider it is routed to, got %q", zoneNames(zones))
+	}
+	for _, v := range zones {
+		want := map[string]string{"example.com": "default", "example.net": "other", "example.org": "default", "lab.example.com": "plain"}[v.Name]
+		if !strings.HasPrefix(v.ID, want+"-") {
+			t.Fatalf("expected %s from the %s provider, got id %s", v.Name, want, v.ID)
+		}
+	}
+}
+
+func TestDescribeZone(t *testing.T) {
+	ctx := context.Background()
+	records := map[string][]Record{"example.com": testRecords(12)}
+	paged := &pagedZoneProvider{testZoneProvider: &testZoneProvider{name: "paged", records: records}}
+	tests := []struct {
+		name        string
+		provider    Provider
+		count       int
+		nameServers []string
+	}{
+		{name: "counted from the listing", provider: &testZoneProvider{name: "plain", records: records}, count: 12},
+		{name: "total of a single page", provider: paged, count: 12},
+		{name: "described by the provider", provider: &describingZoneProvider{testZoneProvider: &testZoneProvider{name: "describing", records: records}}, count: 42, nameServers: []string{"ns1.example.com"}},
+		{name: "routed", provider: &Router{Routes: map[string]Provider{"example.com": &testZoneProvider{name: "plain", records: records}}}, count: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &ZoneInfo{Zone: Zone{ID: "zone1", Name: "example.com"}}
+			if err := DescribeZone(ctx, tt.provider, info); err != nil {
+				t.Fatalf("DescribeZone: %v", err)
+			}
+			if info.RecordCount != tt.count || !slices.Equal(info.NameServers, tt.nameServers) {
+				t.Fatalf("expected %d records and nameservers %q, got %d and %q", tt.count, tt.nameServers, info.RecordCount, info.NameServers)
+			}
+		})
+	}
+	if paged.requests != 1 {
+		t.Fatalf("expected the paged count to take one request, got %d", paged.requests)
+	}
+}
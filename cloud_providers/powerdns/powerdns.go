@@ -0,0 +1,560 @@
+package powerdns
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+)
+
+const ProviderName = "powerdns"
+
+type Config struct {
+	ApiUrl   string        `json:"apiUrl"`
+	ApiKey   string        `json:"-"`
+	ServerID string        `json:"serverId"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+// Provider implements dnsprovider.Provider with the PowerDNS Authoritative HTTP API. Records
+// are changed by replacing their whole RRset with a PATCH, as PowerDNS has no per record calls.
+type Provider struct {
+	config    Config
+	client    *http.Client
+	mu        sync.Mutex
+	zones     []pdnsZone
+	zonesRead time.Time
+}
+
+// zoneCacheTTL bounds how long ZoneForName trusts the zone list, so long running commands
+// such as ddns see zones added or removed on the server.
+const zoneCacheTTL = 5 * time.Minute
+
+type pdnsZone struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Kind   string  `json:"kind,omitempty"`
+	RRsets []rrset `json:"rrsets,omitempty"`
+}
+
+type rrset struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	TTL        int           `json:"ttl,omitempty"`
+	ChangeType string        `json:"changetype,omitempty"`
+	Records    []rrsetRecord `json:"records"`
+	Comments   []rrsetNote   `json:"comments,omitempty"`
+}
+
+type rrsetRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type rrsetNote struct {
+	Content    string `json:"content"`
+	Account    string `json:"account"`
+	ModifiedAt int64  `json:"modified_at,omitempty"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// ConfigFromEnv reads PDNS_API_URL, PDNS_API_KEY and PDNS_SERVER_ID.
+func ConfigFromEnv() Config {
+	return Config{
+		ApiUrl:   os.Getenv("PDNS_API_URL"),
+		ApiKey:   os.Getenv("PDNS_API_KEY"),
+		ServerID: os.Getenv("PDNS_SERVER_ID"),
+	}
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.ApiUrl == "" || config.ApiKey == "" {
+		return nil, fmt.Errorf("powerdns provider requires an api url and api key")
+	}
+	config.ApiUrl = strings.TrimSuffix(config.ApiUrl, "/")
+	if config.ServerID == "" {
+		config.ServerID = "localhost"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &Provider{config: config, client: &http.Client{Timeout: config.Timeout}}, nil
+}
+
+func NewProviderFromEnv() (*Provider, error) {
+	return NewProvider(ConfigFromEnv())
+}
+
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+func (p *Provider) serverPath(parts ...string) string {
+	path := p.config.ApiUrl + "/api/v1/servers/" + url.PathEscape(p.config.ServerID)
+	for _, part := range parts {
+		path += "/" + url.PathEscape(part)
+	}
+	return path
+}
+
+// do sends body as json and decodes the response into out when it is not nil.
+func (p *Provider) do(ctx context.Context, method string, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", p.config.ApiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("powerdns %s %s: %w", method, path, dnsprovider.ErrRateLimited)
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := apiError{}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("powerdns %s %s returned %d: %s", method, path, resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("powerdns %s %s returned %d", method, path, resp.StatusCode)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// ZoneForName returns the longest zone on the server containing name. The zone list is cached
+// for zoneCacheTTL and fetched again early when no cached zone contains name.
+func (p *Provider) ZoneForName(ctx context.Context, name string) (dnsprovider.Zone, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name = dnsprovider.TrimDot(name)
+	fresh := false
+	if p.zones == nil || time.Since(p.zonesRead) > zoneCacheTTL {
+		err := p.refreshZones(ctx)
+		if err != nil {
+			return dnsprovider.Zone{}, err
+		}
+		fresh = true
+	}
+	match := p.cachedZoneFor(name)
+	if match.ID == "" && !fresh {
+		err := p.refreshZones(ctx)
+		if err != nil {
+			return dnsprovider.Zone{}, err
+		}
+		match = p.cachedZoneFor(name)
+	}
+	if match.ID == "" {
+		return match, fmt.Errorf("no powerdns zone on server %s contains %s", p.config.ServerID, name)
+	}
+	return match, nil
+}
+
+// refreshZones lists the zones on the server into the cache. p.mu must be held.
+func (p *Provider) refreshZones(ctx context.Context) error {
+	zones := []pdnsZone{}
+	err := p.do(ctx, http.MethodGet, p.serverPath("zones"), nil, &zones)
+	if err != nil {
+		slog.Error("error listing powerdns zones", slog.String("error", err.Error()))
+		return err
+	}
+	p.zones, p.zonesRead = zones, time.Now()
+	return nil
+}
+
+func (p *Provider) cachedZoneFor(name string) dnsprovider.Zone {
+	match := dnsprovider.Zone{}
+	for _, zone := range p.zones {
+		zoneName := dnsprovider.TrimDot(zone.Name)
+		if dnsprovider.InZone(name, zoneName) && len(zoneName) > len(match.Name) {
+			match = dnsprovider.Zone{ID: zone.ID, Name: zoneName}
+		}
+	}
+	return match
+}
+
+// ListZones returns every zone on the server in one request, refreshing the zone cache used by
+// ZoneForName. The zone kind is reported as the plan, nameservers are left to DescribeZone.
+func (p *Provider) ListZones(ctx context.Context) ([]dnsprovider.ZoneInfo, error) {
+	p.mu.Lock()
+	err := p.refreshZones(ctx)
+	zones := p.zones
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]dnsprovider.ZoneInfo, 0, len(zones))
+	for _, zone := range zones {
+		infos = append(infos, dnsprovider.ZoneInfo{
+			Zone:        dnsprovider.Zone{ID: zone.ID, Name: dnsprovider.TrimDot(zone.Name)},
+			Status:      "active",
+			Plan:        zone.Kind,
+			NameServers: []string{},
+		})
+	}
+	return infos, nil
+}
+
+// DescribeZone reads the apex NS records and the record count, without SOA, from one fetch of
+// the zone.
+func (p *Provider) DescribeZone(ctx context.Context, info *dnsprovider.ZoneInfo) error {
+	pz, err := p.getZone(ctx, info.Zone)
+	if err != nil {
+		return err
+	}
+	info.NameServers, info.RecordCount = []string{}, 0
+	for _, set := range pz.RRsets {
+		if set.Type == "SOA" {
+			continue
+		}
+		info.RecordCount += len(set.Records)
+		if set.Type != "NS" || dnsprovider.TrimDot(set.Name) != info.Name {
+			continue
+		}
+		for _, rec := range set.Records {
+			info.NameServers = append(info.NameServers, dnsprovider.TrimDot(rec.Content))
+		}
+	}
+	return nil
+}
+
+func (p *Provider) getZone(ctx context.Context, zone dnsprovider.Zone) (pdnsZone, error) {
+	pz := pdnsZone{}
+	err := p.do(ctx, http.MethodGet, p.serverPath("zones", zone.ID), nil, &pz)
+	if err != nil {
+		slog.Error("error retrieving powerdns zone", slog.String("zone", zone.Name), slog.String("error", err.Error()))
+	}
+	return pz, err
+}
+
+func (p *Provider) findRRset(ctx context.Context, zone dnsprovider.Zone, name string, recordType string) (*rrset, error) {
+	pz, err := p.getZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range pz.RRsets {
+		if dnsprovider.TrimDot(set.Name) == name && strings.EqualFold(set.Type, recordType) {
+			return &set, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Provider) patch(ctx context.Context, zone dnsprovider.Zone, sets ...rrset) error {
+	body := map[string][]rrset{"rrsets": sets}
+	err := p.do(ctx, http.MethodPatch, p.serverPath("zones", zone.ID), body, nil)
+	if err != nil {
+		slog.Error("error patching powerdns rrsets", slog.String("zone", zone.Name), slog.String("error", err.Error()))
+	}
+	return err
+}
+
+// ListRecords returns every record of every RRset except SOA, filtered locally.
+func (p *Provider) ListRecords(ctx context.Context, zone dnsprovider.Zone, filter dnsprovider.RecordFilter) ([]dnsprovider.Record, error) {
+	pz, err := p.getZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	records := []dnsprovider.Record{}
+	for _, set := range pz.RRsets {
+		if set.Type == "SOA" {
+			continue
+		}
+		for _, rec := range set.Records {
+			record := recordFromRRset(zone, set, rec)
+			if filter.Matches(record) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+func (p *Provider) GetRecord(ctx context.Context, zone dnsprovider.Zone, id string) (dnsprovider.Record, error) {
+	name, recordType, content, err := parseRecordID(id)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	set, err := p.findRRset(ctx, zone, name, recordType)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	if set != nil {
+		for _, rec := range set.Records {
+			if rec.Content == content {
+				return recordFromRRset(zone, *set, rec), nil
+			}
+		}
+	}
+	return dnsprovider.Record{}, fmt.Errorf("%w: %s %s %s", dnsprovider.ErrRecordNotFound, name, recordType, content)
+}
+
+func (p *Provider) CreateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	name := dnsprovider.QualifyName(record.Name, zone.Name)
+	recordType := strings.ToUpper(record.Type)
+	content := toContent(recordType, record.Content, record.Priority)
+
+	set, err := p.findRRset(ctx, zone, name, recordType)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	if set == nil {
+		set = &rrset{Name: name + ".", Type: recordType, TTL: 300}
+	}
+	if record.TTL > 1 {
+		set.TTL = record.TTL
+	}
+	if record.CommentText() != "" {
+		set.Comments = append(set.Comments, rrsetNote{Content: record.CommentText()})
+	}
+	if !slices.ContainsFunc(set.Records, func(r rrsetRecord) bool { return r.Content == content }) {
+		set.Records = append(set.Records, rrsetRecord{Content: content})
+	}
+	set.ChangeType = "REPLACE"
+
+	err = p.patch(ctx, zone, *set)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	return recordFromRRset(zone, *set, rrsetRecord{Content: content}), nil
+}
+
+// UpdateRecord replaces the record addressed by record.ID. When the name or type changes the
+// old and new RRsets are sent in the same PATCH.
+func (p *Provider) UpdateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	existing, err := p.GetRecord(ctx, zone, record.ID)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	_, _, oldContent, _ := parseRecordID(record.ID)
+
+	merged := existing
+	if record.Name != "" {
+		merged.Name = dnsprovider.QualifyName(record.Name, zone.Name)
+	}
+	if record.Type != "" {
+		merged.Type = strings.ToUpper(record.Type)
+	}
+	if record.Content != "" {
+		merged.Content = record.Content
+	}
+	if record.TTL > 1 {
+		merged.TTL = record.TTL
+	}
+	if record.Priority != nil {
+		merged.Priority = record.Priority
+	}
+	if record.Comment != nil {
+		merged.Comment = record.Comment
+	}
+	newContent := toContent(merged.Type, merged.Content, merged.Priority)
+
+	oldSet, err := p.findRRset(ctx, zone, existing.Name, existing.Type)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	oldSet.Records = slices.DeleteFunc(oldSet.Records, func(r rrsetRecord) bool { return r.Content == oldContent })
+
+	newSet := oldSet
+	if merged.Name != existing.Name || merged.Type != existing.Type {
+		newSet, err = p.findRRset(ctx, zone, merged.Name, merged.Type)
+		if err != nil {
+			return dnsprovider.Record{}, err
+		}
+		if newSet == nil {
+			newSet = &rrset{Name: merged.Name + ".", Type: merged.Type}
+		}
+	}
+	newSet.Records = append(newSet.Records, rrsetRecord{Content: newContent})
+	newSet.TTL = merged.TTL
+	comment := merged.CommentText()
+	switch {
+	case record.Comment != nil && comment == "":
+		// Comments belong to the whole RRset in PowerDNS, so clearing one clears them all.
+		newSet.Comments = []rrsetNote{}
+	case comment != "" && !slices.ContainsFunc(newSet.Comments, func(n rrsetNote) bool { return n.Content == comment }):
+		newSet.Comments = append(newSet.Comments, rrsetNote{Content: comment})
+	}
+
+	sets := []rrset{}
+	if newSet != oldSet {
+		sets = append(sets, rrsetChange(*oldSet))
+	}
+	sets = append(sets, rrsetChange(*newSet))
+	err = p.patch(ctx, zone, sets...)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	return recordFromRRset(zone, *newSet, rrsetRecord{Content: newContent}), nil
+}
+
+func (p *Provider) DeleteRecord(ctx context.Context, zone dnsprovider.Zone, id string) error {
+	name, recordType, content, err := parseRecordID(id)
+	if err != nil {
+		return err
+	}
+	set, err := p.findRRset(ctx, zone, name, recordType)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		return fmt.Errorf("%w: %s %s %s", dnsprovider.ErrRecordNotFound, name, recordType, content)
+	}
+	set.Records = slices.DeleteFunc(set.Records, func(r rrsetRecord) bool { return r.Content == content })
+
+	err = p.patch(ctx, zone, rrsetChange(*set))
+	if err != nil {
+		return err
+	}
+	slog.Info("Deleted DNS Record", slog.String("zone", zone.Name), slog.String("name", name), slog.String("type", recordType), slog.String("content", content))
+	return nil
+}
+
+func (p *Provider) CreateTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string, ttl int) (dnsprovider.Record, error) {
+	return p.CreateRecord(ctx, zone, dnsprovider.Record{Name: dnsprovider.TrimDot(fqdn), Type: "TXT", Content: value, TTL: ttl})
+}
+
+func (p *Provider) DeleteTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string) error {
+	return p.DeleteRecord(ctx, zone, recordID(dnsprovider.TrimDot(fqdn), "TXT", toContent("TXT", value, nil)))
+}
+
+// rrsetChange marks set for replacement, or deletion once its last record is gone.
+func rrsetChange(set rrset) rrset {
+	if len(set.Records) == 0 {
+		return rrset{Name: set.Name, Type: set.Type, ChangeType: "DELETE"}
+	}
+	set.ChangeType = "REPLACE"
+	return set
+}
+
+// recordID encodes the owner name, type and PowerDNS content, which together identify a
+// record within its RRset.
+func recordID(name string, recordType string, content string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(name + "\x00" + recordType + "\x00" + content))
+}
+
+func parseRecordID(id string) (name string, recordType string, content string, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid powerdns record id %q: %w", id, err)
+	}
+	parts := strings.SplitN(string(data), "\x00", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid powerdns record id %q", id)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+func recordFromRRset(zone dnsprovider.Zone, set rrset, rec rrsetRecord) dnsprovider.Record {
+	name := dnsprovider.TrimDot(set.Name)
+	content, priority := fromContent(set.Type, rec.Content)
+	record := dnsprovider.Record{
+		ID:       recordID(name, set.Type, rec.Content),
+		ZoneID:   zone.ID,
+		ZoneName: zone.Name,
+		Name:     name,
+		Type:     set.Type,
+		Content:  content,
+		TTL:      set.TTL,
+		Priority: priority,
+	}
+	if len(set.Comments) > 0 {
+		last := set.Comments[len(set.Comments)-1]
+		record.Comment = &last.Content
+		if last.ModifiedAt > 0 {
+			record.ModifiedOn = time.Unix(last.ModifiedAt, 0)
+		}
+	}
+	return record
+}
+
+// toContent renders record content the way PowerDNS stores it: quoted TXT, fully qualified
+// targets and the priority in front of MX and SRV data.
+func toContent(recordType string, content string, priority *uint16) string {
+	switch recordType {
+	case "TXT":
+		if strings.HasPrefix(content, "\"") {
+			return content
+		}
+		return "\"" + strings.ReplaceAll(strings.ReplaceAll(content, "\\", "\\\\"), "\"", "\\\"") + "\""
+	case "CNAME", "NS", "PTR":
+		return fqdn(content)
+	case "MX", "SRV":
+		var pr uint16
+		if priority != nil {
+			pr = *priority
+		}
+		fields := strings.Fields(content)
+		if len(fields) > 0 {
+			fields[len(fields)-1] = fqdn(fields[len(fields)-1])
+		}
+		return fmt.Sprintf("%d %s", pr, strings.Join(fields, " "))
+	}
+	return content
+}
+
+func fromContent(recordType string, content string) (string, *uint16) {
+	switch recordType {
+	case "TXT":
+		unquoted := strings.ReplaceAll(strings.Trim(content, "\""), "\" \"", "")
+		return strings.ReplaceAll(strings.ReplaceAll(unquoted, "\\\"", "\""), "\\\\", "\\"), nil
+	case "CNAME", "NS", "PTR":
+		return strings.TrimSuffix(content, "."), nil
+	case "MX", "SRV":
+		first, rest, found := strings.Cut(content, " ")
+		pr, err := strconv.ParseUint(first, 10, 16)
+		if !found || err != nil {
+			return content, nil
+		}
+		priority := uint16(pr)
+		return strings.TrimSuffix(rest, "."), &priority
+	}
+	return content, nil
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,256 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+)
+
+// testServer is a PowerDNS API holding zones in memory. It counts requests by method and path
+// and answers 429 while rateLimited is set.
+type testServer struct {
+	mu          sync.Mutex
+	zones       map[string]*pdnsZone
+	requests    map[string]int
+	rateLimited bool
+}
+
+func newTestServer(t *testing.T, zones ...pdnsZone) (*testServer, *Provider) {
+	t.Helper()
+	s := &testServer{zones: make(map[string]*pdnsZone), requests: make(map[string]int)}
+	for _, zone := range zones {
+		s.addZone(zone)
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	p, err := NewProvider(Config{ApiUrl: srv.URL + "/", ApiKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, p
+}
+
+func (s *testServer) addZone(zone pdnsZone) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zones[zone.ID] = &zone
+}
+
+func (s *testServer) count(method string, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method+" "+path]
+}
+
+func (s *testServer) rrset(zoneID string, name string, recordType string) *rrset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, set := range s.zones[zoneID].RRsets {
+		if set.Name == name && set.Type == recordType {
+			return &set
+		}
+	}
+	return nil
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[r.Method+" "+r.URL.Path]++
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if s.rateLimited {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	zoneID, _ := strings.CutPrefix(r.URL.Path, "/api/v1/servers/localhost/zones")
+	zoneID = strings.TrimPrefix(zoneID, "/")
+	if zoneID == "" {
+		list := []pdnsZone{}
+		for _, zone := range s.zones {
+			list = append(list, pdnsZone{ID: zone.ID, Name: zone.Name, Kind: zone.Kind})
+		}
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+	zone, ok := s.zones[zoneID]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(apiError{Error: "Could not find domain '" + zoneID + "'"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(zone)
+	case http.MethodPatch:
+		body := map[string][]rrset{}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, change := range body["rrsets"] {
+			zone.RRsets = slices.DeleteFunc(zone.RRsets, func(set rrset) bool { return set.Name == change.Name && set.Type == change.Type })
+			if change.ChangeType == "REPLACE" {
+				change.ChangeType = ""
+				zone.RRsets = append(zone.RRsets, change)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func exampleZone() pdnsZone {
+	return pdnsZone{ID: "example.com.", Name: "example.com.", Kind: "Native", RRsets: []rrset{
+		{Name: "example.com.", Type: "SOA", TTL: 3600, Records: []rrsetRecord{{Content: "ns1.example.com. hostmaster.example.com. 1 10800 3600 604800 3600"}}},
+		{Name: "example.com.", Type: "NS", TTL: 3600, Records: []rrsetRecord{{Content: "ns1.example.com."}, {Content: "ns2.example.com."}}},
+		{Name: "example.com.", Type: "MX", TTL: 3600, Records: []rrsetRecord{{Content: "10 mail.example.com."}}},
+		{Name: "www.example.com.", Type: "A", TTL: 300, Records: []rrsetRecord{{Content: "192.0.2.1"}, {Content: "192.0.2.2"}}, Comments: []rrsetNote{{Content: "web servers"}}},
+	}}
+}
+
+func TestListZonesAndDescribeZone(t *testing.T) {
+	server, p := newTestServer(t, exampleZone(), pdnsZone{ID: "lab.example.net.", Name: "lab.example.net.", Kind: "Master"})
+	ctx := context.Background()
+
+	zones, err := dnsprovider.ListZones(ctx, p)
+	if err != nil {
+		t.Fatalf("ListZones: %v", err)
+	}
+	if len(zones) != 2 || zones[0].Name != "example.com" || zones[0].Plan != "Native" || zones[1].Name != "lab.example.net" {
+		t.Fatalf("unexpected zones %+v", zones)
+	}
+	if n := server.count(http.MethodGet, "/api/v1/servers/localhost/zones/example.com."); n != 0 {
+		t.Fatalf("expected ListZones to make no per zone requests, got %d", n)
+	}
+
+	if err := dnsprovider.DescribeZone(ctx, p, &zones[0]); err != nil {
+		t.Fatalf("DescribeZone: %v", err)
+	}
+	if zones[0].RecordCount != 5 || !slices.Equal(zones[0].NameServers, []string{"ns1.example.com", "ns2.example.com"}) {
+		t.Fatalf("expected 5 records and the apex nameservers, got %+v", zones[0])
+	}
+	if n := server.count(http.MethodGet, "/api/v1/servers/localhost/zones/example.com."); n != 1 {
+		t.Fatalf("expected DescribeZone to fetch the zone once, got %d", n)
+	}
+}
+
+func TestZoneForNameRefreshesOnMiss(t *testing.T) {
+	server, p := newTestServer(t, exampleZone())
+	ctx := context.Background()
+
+	zone, err := p.ZoneForName(ctx, "www.example.com.")
+	if err != nil || zone.ID != "example.com." || zone.Name != "example.com" {
+		t.Fatalf("unexpected zone %+v, %v", zone, err)
+	}
+	p.ZoneForName(ctx, "mail.example.com")
+	if n := server.count(http.MethodGet, "/api/v1/servers/localhost/zones"); n != 1 {
+		t.Fatalf("expected the zone list to be cached, got %d requests", n)
+	}
+
+	server.addZone(pdnsZone{ID: "example.net.", Name: "example.net."})
+	zone, err = p.ZoneForName(ctx, "www.example.net")
+	if err != nil || zone.Name != "example.net" {
+		t.Fatalf("expected a zone added after the list was cached to be found, got %+v, %v", zone, err)
+	}
+
+	server.addZone(pdnsZone{ID: "sub.example.com.", Name: "sub.example.com."})
+	p.zonesRead = time.Now().Add(-zoneCacheTTL - time.Second)
+	zone, err = p.ZoneForName(ctx, "host.sub.example.com")
+	if err != nil || zone.Name != "sub.example.com" {
+		t.Fatalf("expected the longest zone once the cache expired, got %+v, %v", zone, err)
+	}
+	if _, err := p.ZoneForName(ctx, "example.org"); err == nil {
+		t.Fatal("expected an error for a name outside every zone")
+	}
+}
+
+func TestRecordLifecycle(t *testing.T) {
+	server, p := newTestServer(t, exampleZone())
+	ctx := context.Background()
+	zone := dnsprovider.Zone{ID: "example.com.", Name: "example.com"}
+
+	records, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{Name: "www.example.com", Type: "A"})
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expected both records of the A RRset, got %+v, %v", records, err)
+	}
+	if records[0].CommentText() != "web servers" {
+		t.Fatalf("expected the RRset comment on the record, got %q", records[0].CommentText())
+	}
+	mx, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{Type: "MX"})
+	if err != nil || len(mx) != 1 || mx[0].Content != "mail.example.com" || mx[0].Priority == nil || *mx[0].Priority != 10 {
+		t.Fatalf("unexpected MX records %+v, %v", mx, err)
+	}
+
+	created, err := p.CreateRecord(ctx, zone, dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.3", TTL: 600})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	set := server.rrset("example.com.", "www.example.com.", "A")
+	if set == nil || len(set.Records) != 3 || set.TTL != 600 {
+		t.Fatalf("expected the new value added to the RRset, got %+v", set)
+	}
+	got, err := p.GetRecord(ctx, zone, created.ID)
+	if err != nil || got.Content != "192.0.2.3" {
+		t.Fatalf("GetRecord by id returned %+v, %v", got, err)
+	}
+
+	empty := ""
+	updated, err := p.UpdateRecord(ctx, zone, dnsprovider.Record{ID: created.ID, Content: "192.0.2.4", Comment: &empty})
+	if err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	set = server.rrset("example.com.", "www.example.com.", "A")
+	contents := []string{}
+	for _, v := range set.Records {
+		contents = append(contents, v.Content)
+	}
+	if !slices.Equal(contents, []string{"192.0.2.1", "192.0.2.2", "192.0.2.4"}) || len(set.Comments) != 0 {
+		t.Fatalf("expected the value replaced and the comment cleared, got %v %v", contents, set.Comments)
+	}
+
+	txt, err := p.CreateTXTRecord(ctx, zone, "_acme-challenge.example.com.", "token", 120)
+	if err != nil || txt.Content != "token" {
+		t.Fatalf("CreateTXTRecord returned %+v, %v", txt, err)
+	}
+	if set := server.rrset("example.com.", "_acme-challenge.example.com.", "TXT"); set == nil || set.Records[0].Content != `"token"` {
+		t.Fatalf("expected the TXT content quoted, got %+v", set)
+	}
+	if err := p.DeleteTXTRecord(ctx, zone, "_acme-challenge.example.com.", "token"); err != nil {
+		t.Fatalf("DeleteTXTRecord: %v", err)
+	}
+	if set := server.rrset("example.com.", "_acme-challenge.example.com.", "TXT"); set != nil {
+		t.Fatalf("expected the emptied RRset deleted, got %+v", set)
+	}
+
+	if err := p.DeleteRecord(ctx, zone, updated.ID); err != nil {
+		t.Fatalf("DeleteRecord: %v", err)
+	}
+	if set := server.rrset("example.com.", "www.example.com.", "A"); set == nil || len(set.Records) != 2 {
+		t.Fatalf("expected only the deleted value removed, got %+v", set)
+	}
+	if _, err := p.GetRecord(ctx, zone, updated.ID); !errors.Is(err, dnsprovider.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	server, p := newTestServer(t, exampleZone())
+	server.mu.Lock()
+	server.rateLimited = true
+	server.mu.Unlock()
+
+	_, err := p.CreateRecord(context.Background(), dnsprovider.Zone{ID: "example.com.", Name: "example.com"}, dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.3"})
+	if !errors.Is(err, dnsprovider.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
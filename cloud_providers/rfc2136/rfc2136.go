@@ -0,0 +1,365 @@
+package rfc2136
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/miekg/dns"
+)
+
+const ProviderName = "rfc2136"
+
+// Config describes the primary nameserver accepting dynamic updates and the TSIG key used to
+// sign updates and zone transfers.
+type Config struct {
+	Nameserver    string        `json:"nameserver"`
+	TsigKey       string        `json:"tsigKey"`
+	TsigSecret    string        `json:"-"`
+	TsigAlgorithm string        `json:"tsigAlgorithm"`
+	Zone          string        `json:"zone"`
+	Timeout       time.Duration `json:"timeout"`
+}
+
+// Provider implements dnsprovider.Provider with RFC 2136 dynamic updates for changes and AXFR
+// for listing, so BIND, Knot or any server allowing both for the TSIG key can be managed.
+type Provider struct {
+	config Config
+}
+
+// ConfigFromEnv reads RFC2136_NAMESERVER, RFC2136_TSIG_KEY, RFC2136_TSIG_SECRET,
+// RFC2136_TSIG_ALGORITHM and RFC2136_ZONE.
+func ConfigFromEnv() Config {
+	return Config{
+		Nameserver:    os.Getenv("RFC2136_NAMESERVER"),
+		TsigKey:       os.Getenv("RFC2136_TSIG_KEY"),
+		TsigSecret:    os.Getenv("RFC2136_TSIG_SECRET"),
+		TsigAlgorithm: os.Getenv("RFC2136_TSIG_ALGORITHM"),
+		Zone:          os.Getenv("RFC2136_ZONE"),
+	}
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Nameserver == "" {
+		return nil, fmt.Errorf("rfc2136 provider requires a nameserver")
+	}
+	if _, _, err := net.SplitHostPort(config.Nameserver); err != nil {
+		config.Nameserver = net.JoinHostPort(config.Nameserver, "53")
+	}
+	if (config.TsigKey == "") != (config.TsigSecret == "") {
+		return nil, fmt.Errorf("rfc2136 provider requires both a TSIG key name and secret, or neither")
+	}
+	if config.TsigKey != "" {
+		config.TsigKey = dns.Fqdn(strings.ToLower(config.TsigKey))
+	}
+	if config.TsigAlgorithm == "" {
+		config.TsigAlgorithm = dns.HmacSHA256
+	}
+	config.TsigAlgorithm = dns.Fqdn(strings.ToLower(config.TsigAlgorithm))
+	if config.Zone != "" {
+		config.Zone = dnsprovider.TrimDot(config.Zone)
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &Provider{config: config}, nil
+}
+
+func NewProviderFromEnv() (*Provider, error) {
+	return NewProvider(ConfigFromEnv())
+}
+
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// ZoneForName uses the configured zone when it contains name, otherwise asks the nameserver
+// for the SOA owning name.
+func (p *Provider) ZoneForName(ctx context.Context, name string) (dnsprovider.Zone, error) {
+	name = dnsprovider.TrimDot(name)
+	if p.config.Zone != "" && dnsprovider.InZone(name, p.config.Zone) {
+		return dnsprovider.Zone{ID: p.config.Zone, Name: p.config.Zone}, nil
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeSOA)
+	resp, err := p.exchange(ctx, msg)
+	if err != nil {
+		return dnsprovider.Zone{}, err
+	}
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			zone := dnsprovider.TrimDot(soa.Hdr.Name)
+			return dnsprovider.Zone{ID: zone, Name: zone}, nil
+		}
+	}
+	return dnsprovider.Zone{}, fmt.Errorf("nameserver %s is not authoritative for %s", p.config.Nameserver, name)
+}
+
+// ListRecords transfers the zone and filters it locally. SOA records are left out.
+func (p *Provider) ListRecords(ctx context.Context, zone dnsprovider.Zone, filter dnsprovider.RecordFilter) ([]dnsprovider.Record, error) {
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone.Name))
+	transfer := &dns.Transfer{DialTimeout: p.config.Timeout, ReadTimeout: p.config.Timeout}
+	if p.config.TsigKey != "" {
+		transfer.TsigSecret = map[string]string{p.config.TsigKey: p.config.TsigSecret}
+		msg.SetTsig(p.config.TsigKey, p.config.TsigAlgorithm, 300, time.Now().Unix())
+	}
+
+	envelopes, err := transfer.In(msg, p.config.Nameserver)
+	if err != nil {
+		slog.Error("error starting zone transfer", slog.String("zone", zone.Name), slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	records := []dnsprovider.Record{}
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			slog.Error("error during zone transfer", slog.String("zone", zone.Name), slog.String("error", envelope.Error.Error()))
+			return nil, envelope.Error
+		}
+		for _, rr := range envelope.RR {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				continue
+			}
+			record := recordFromRR(zone, rr)
+			if filter.Matches(record) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+func (p *Provider) GetRecord(ctx context.Context, zone dnsprovider.Zone, id string) (dnsprovider.Record, error) {
+	rr, err := rrFromID(id)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	want := recordFromRR(zone, rr)
+	records, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{Name: want.Name, Type: want.Type})
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	for _, record := range records {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return dnsprovider.Record{}, fmt.Errorf("%w: %s %s %s", dnsprovider.ErrRecordNotFound, want.Name, want.Type, want.Content)
+}
+
+func (p *Provider) CreateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	rr, err := rrFromRecord(zone, record)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(zone.Name))
+	msg.Insert([]dns.RR{rr})
+	_, err = p.exchange(ctx, msg)
+	if err != nil {
+		slog.Error("error adding record with dynamic update", slog.String("record", rr.String()), slog.String("error", err.Error()))
+		return dnsprovider.Record{}, err
+	}
+	return recordFromRR(zone, rr), nil
+}
+
+// UpdateRecord replaces the record addressed by record.ID in a single update message, so the
+// old and new values are never both missing. Fields left empty keep the live record's values,
+// the id carries no TTL so the record is read back from the zone first.
+func (p *Provider) UpdateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	oldRR, err := rrFromID(record.ID)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+	merged, err := p.GetRecord(ctx, zone, record.ID)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+
+	if record.Name != "" {
+		merged.Name = record.Name
+	}
+	if record.Type != "" {
+		merged.Type = record.Type
+	}
+	if record.Content != "" {
+		merged.Content = record.Content
+	}
+	if record.TTL != 0 {
+		merged.TTL = record.TTL
+	}
+	if record.Priority != nil {
+		merged.Priority = record.Priority
+	}
+	newRR, err := rrFromRecord(zone, merged)
+	if err != nil {
+		return dnsprovider.Record{}, err
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(zone.Name))
+	msg.Remove([]dns.RR{oldRR})
+	msg.Insert([]dns.RR{newRR})
+	_, err = p.exchange(ctx, msg)
+	if err != nil {
+		slog.Error("error replacing record with dynamic update", slog.String("record", newRR.String()), slog.String("error", err.Error()))
+		return dnsprovider.Record{}, err
+	}
+	return recordFromRR(zone, newRR), nil
+}
+
+func (p *Provider) DeleteRecord(ctx context.Context, zone dnsprovider.Zone, id string) error {
+	rr, err := rrFromID(id)
+	if err != nil {
+		return err
+	}
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(zone.Name))
+	msg.Remove([]dns.RR{rr})
+	_, err = p.exchange(ctx, msg)
+	if err != nil {
+		slog.Error("error removing record with dynamic update", slog.String("record", rr.String()), slog.String("error", err.Error()))
+		return err
+	}
+	slog.Info("Deleted DNS Record", slog.String("zone", zone.Name), slog.String("record", rr.String()))
+	return nil
+}
+
+func (p *Provider) CreateTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string, ttl int) (dnsprovider.Record, error) {
+	return p.CreateRecord(ctx, zone, dnsprovider.Record{Name: dnsprovider.TrimDot(fqdn), Type: "TXT", Content: value, TTL: ttl})
+}
+
+func (p *Provider) DeleteTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string) error {
+	rr, err := rrFromRecord(zone, dnsprovider.Record{Name: dnsprovider.TrimDot(fqdn), Type: "TXT", Content: value})
+	if err != nil {
+		return err
+	}
+	return p.DeleteRecord(ctx, zone, recordID(rr))
+}
+
+// exchange sends msg to the configured nameserver, signing it when a TSIG key is set, and
+// turns any rcode other than NOERROR into an error.
+func (p *Provider) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Timeout: p.config.Timeout}
+	if p.config.TsigKey != "" {
+		client.TsigSecret = map[string]string{p.config.TsigKey: p.config.TsigSecret}
+		msg.SetTsig(p.config.TsigKey, p.config.TsigAlgorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, msg, p.config.Nameserver)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && !(resp.Rcode == dns.RcodeNameError && msg.Opcode == dns.OpcodeQuery) {
+		return resp, fmt.Errorf("nameserver %s returned %s", p.config.Nameserver, dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// recordID encodes the record's owner, class, type and rdata. RFC 2136 has no record ids,
+// the rdata is what identifies a record within its RRset.
+func recordID(rr dns.RR) string {
+	key := dns.Copy(rr)
+	key.Header().Ttl = 0
+	return base64.RawURLEncoding.EncodeToString([]byte(key.String()))
+}
+
+func rrFromID(id string) (dns.RR, error) {
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rfc2136 record id %q: %w", id, err)
+	}
+	rr, err := dns.NewRR(string(data))
+	if err != nil || rr == nil {
+		return nil, fmt.Errorf("invalid rfc2136 record id %q: %v", id, err)
+	}
+	return rr, nil
+}
+
+func recordFromRR(zone dnsprovider.Zone, rr dns.RR) dnsprovider.Record {
+	hdr := rr.Header()
+	record := dnsprovider.Record{
+		ID:       recordID(rr),
+		ZoneID:   zone.ID,
+		ZoneName: zone.Name,
+		Name:     dnsprovider.TrimDot(hdr.Name),
+		Type:     dns.TypeToString[hdr.Rrtype],
+		TTL:      int(hdr.Ttl),
+	}
+
+	switch v := rr.(type) {
+	case *dns.TXT:
+		record.Content = strings.Join(v.Txt, "")
+	case *dns.CNAME:
+		record.Content = strings.TrimSuffix(v.Target, ".")
+	case *dns.NS:
+		record.Content = strings.TrimSuffix(v.Ns, ".")
+	case *dns.MX:
+		priority := v.Preference
+		record.Priority = &priority
+		record.Content = strings.TrimSuffix(v.Mx, ".")
+	case *dns.SRV:
+		priority := v.Priority
+		record.Priority = &priority
+		record.Content = fmt.Sprintf("%d %d %s", v.Weight, v.Port, strings.TrimSuffix(v.Target, "."))
+	default:
+		record.Content = strings.TrimSpace(strings.TrimPrefix(rr.String(), hdr.String()))
+	}
+	return record
+}
+
+// rrFromRecord builds the RR for record, treating names outside the zone as relative to it.
+func rrFromRecord(zone dnsprovider.Zone, record dnsprovider.Record) (dns.RR, error) {
+	name := dnsprovider.QualifyName(record.Name, zone.Name)
+
+	ttl := record.TTL
+	if ttl <= 1 {
+		ttl = 300
+	}
+	recordType := strings.ToUpper(record.Type)
+
+	if recordType == "TXT" {
+		hdr := dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: uint32(ttl)}
+		return &dns.TXT{Hdr: hdr, Txt: splitTxt(strings.Trim(record.Content, "\""))}, nil
+	}
+
+	rdata := record.Content
+	switch recordType {
+	case "MX", "SRV":
+		var priority uint16
+		if record.Priority != nil {
+			priority = *record.Priority
+		}
+		rdata = fmt.Sprintf("%d %s", priority, record.Content)
+	}
+	if recordType == "CNAME" || recordType == "MX" || recordType == "NS" || recordType == "SRV" {
+		rdata = dns.Fqdn(rdata)
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(name), ttl, recordType, rdata))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s record %s: %w", recordType, name, err)
+	}
+	if rr == nil {
+		return nil, fmt.Errorf("empty %s record %s", recordType, name)
+	}
+	return rr, nil
+}
+
+// splitTxt splits value into the 255 byte character strings a TXT record is made of.
+func splitTxt(value string) []string {
+	chunks := []string{}
+	for len(value) > 255 {
+		chunks = append(chunks, value[:255])
+		value = value[255:]
+	}
+	return append(chunks, value)
+}
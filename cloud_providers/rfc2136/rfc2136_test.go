@@ -0,0 +1,262 @@
+package rfc2136
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/miekg/dns"
+)
+
+const (
+	testTsigKey    = "update-key."
+	testTsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LTEyMzQ="
+)
+
+// testZoneServer is an authoritative server for one zone that answers SOA queries, serves AXFR
+// and applies dynamic updates, requiring requests signed with the test TSIG key.
+type testZoneServer struct {
+	mu      sync.Mutex
+	soa     *dns.SOA
+	records []dns.RR
+	updates []*dns.Msg
+}
+
+// state returns copies of the zone's records and the update messages received so far.
+func (s *testZoneServer) state() ([]dns.RR, []*dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]dns.RR{}, s.records...), append([]*dns.Msg{}, s.updates...)
+}
+
+func rrKey(rr dns.RR) string {
+	key := dns.Copy(rr)
+	key.Header().Ttl = 0
+	key.Header().Class = dns.ClassINET
+	return strings.ToLower(key.String())
+}
+
+func (s *testZoneServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	tsig := r.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		msg.SetRcode(r, dns.RcodeNotAuth)
+		w.WriteMsg(msg)
+		return
+	}
+	defer func() {
+		msg.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+		w.WriteMsg(msg)
+	}()
+
+	if r.Opcode == dns.OpcodeUpdate {
+		s.updates = append(s.updates, r)
+		for _, rr := range r.Ns {
+			switch rr.Header().Class {
+			case dns.ClassNONE:
+				key := rrKey(rr)
+				for i, v := range s.records {
+					if rrKey(v) == key {
+						s.records = append(s.records[:i], s.records[i+1:]...)
+						break
+					}
+				}
+			case dns.ClassINET:
+				s.records = append(s.records, rr)
+			}
+		}
+		return
+	}
+
+	q := r.Question[0]
+	switch {
+	case q.Qtype == dns.TypeAXFR:
+		msg.Answer = append(append([]dns.RR{s.soa}, s.records...), s.soa)
+	case q.Qtype == dns.TypeSOA && dns.IsSubDomain(s.soa.Hdr.Name, q.Name):
+		msg.Ns = []dns.RR{s.soa}
+	default:
+		msg.SetRcode(r, dns.RcodeRefused)
+	}
+}
+
+func startTestZoneServer(t *testing.T, zone string, records ...string) (*testZoneServer, string) {
+	t.Helper()
+	soa, _ := dns.NewRR(dns.Fqdn(zone) + " 3600 IN SOA ns1." + dns.Fqdn(zone) + " hostmaster." + dns.Fqdn(zone) + " 1 3600 600 86400 300")
+	s := &testZoneServer{soa: soa.(*dns.SOA)}
+	for _, v := range records {
+		rr, err := dns.NewRR(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.records = append(s.records, rr)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := map[string]string{testTsigKey: testTsigSecret}
+	// The default accept func refuses UPDATE messages.
+	accept := func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept }
+	for _, server := range []*dns.Server{{PacketConn: conn, Handler: s, TsigSecret: secrets, MsgAcceptFunc: accept}, {Listener: ln, Handler: s, TsigSecret: secrets, MsgAcceptFunc: accept}} {
+		go server.ActivateAndServe()
+		t.Cleanup(func() { server.Shutdown() })
+	}
+	return s, conn.LocalAddr().String()
+}
+
+func newTestProvider(t *testing.T, nameserver string, secret string) *Provider {
+	t.Helper()
+	p, err := NewProvider(Config{Nameserver: nameserver, TsigKey: testTsigKey, TsigSecret: secret, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProviderListsZoneWithAxfr(t *testing.T) {
+	_, addr := startTestZoneServer(t, "example.com",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"example.com. 3600 IN MX 10 mail.example.com.",
+		`example.com. 300 IN TXT "v=spf1 " "-all"`,
+	)
+	p := newTestProvider(t, addr, testTsigSecret)
+	ctx := context.Background()
+
+	zone, err := p.ZoneForName(ctx, "www.example.com")
+	if err != nil || zone.Name != "example.com" {
+		t.Fatalf("expected zone example.com from the SOA, got %+v, %v", zone, err)
+	}
+	records, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{})
+	if err != nil {
+		t.Fatalf("ListRecords: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records without the SOA, got %+v", records)
+	}
+	byType := map[string]dnsprovider.Record{}
+	for _, v := range records {
+		byType[v.Type] = v
+	}
+	if mx := byType["MX"]; mx.Content != "mail.example.com" || mx.Priority == nil || *mx.Priority != 10 {
+		t.Fatalf("unexpected MX record %+v", mx)
+	}
+	if txt := byType["TXT"]; txt.Content != "v=spf1 -all" {
+		t.Fatalf("expected the TXT strings joined, got %q", txt.Content)
+	}
+
+	a := byType["A"]
+	rr, err := rrFromID(a.ID)
+	if err != nil || rr.String() != "www.example.com.\t0\tIN\tA\t192.0.2.1" {
+		t.Fatalf("expected the id to decode to the record without its ttl, got %v, %v", rr, err)
+	}
+	got, err := p.GetRecord(ctx, zone, a.ID)
+	if err != nil || got.Content != "192.0.2.1" || got.TTL != 300 {
+		t.Fatalf("GetRecord by id returned %+v, %v", got, err)
+	}
+
+	filtered, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{Type: "mx"})
+	if err != nil || len(filtered) != 1 {
+		t.Fatalf("expected the filter to keep only the MX record, got %+v, %v", filtered, err)
+	}
+}
+
+func TestProviderDynamicUpdates(t *testing.T) {
+	server, addr := startTestZoneServer(t, "example.com", "www.example.com. 3600 IN A 192.0.2.1")
+	p := newTestProvider(t, addr, testTsigSecret)
+	ctx := context.Background()
+	zone := dnsprovider.Zone{ID: "example.com", Name: "example.com"}
+
+	long := strings.Repeat("a", 300)
+	created, err := p.CreateRecord(ctx, zone, dnsprovider.Record{Name: "txt", Type: "TXT", Content: long, TTL: 120})
+	if err != nil {
+		t.Fatalf("CreateRecord: %v", err)
+	}
+	if created.Name != "txt.example.com" || created.Content != long {
+		t.Fatalf("unexpected created record %+v", created)
+	}
+	zoneRecords, _ := server.state()
+	if txt := zoneRecords[len(zoneRecords)-1].(*dns.TXT); len(txt.Txt) != 2 || len(txt.Txt[0]) != 255 {
+		t.Fatalf("expected the TXT value split into 255 byte strings, got %d strings", len(txt.Txt))
+	}
+
+	records, err := p.ListRecords(ctx, zone, dnsprovider.RecordFilter{Name: "www.example.com", Type: "A"})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected the A record, got %+v, %v", records, err)
+	}
+	updated, err := p.UpdateRecord(ctx, zone, dnsprovider.Record{ID: records[0].ID, Content: "192.0.2.2"})
+	if err != nil {
+		t.Fatalf("UpdateRecord: %v", err)
+	}
+	if updated.Content != "192.0.2.2" || updated.TTL != 3600 || updated.ID == records[0].ID {
+		t.Fatalf("expected the content changed with a new id and the ttl kept, got %+v", updated)
+	}
+	if zoneRecords, _ := server.state(); zoneRecords[len(zoneRecords)-1].Header().Ttl != 3600 {
+		t.Fatalf("expected the zone to keep the 3600 ttl, got %v", zoneRecords)
+	}
+	_, updates := server.state()
+	last := updates[len(updates)-1]
+	if len(last.Ns) != 2 || last.Ns[0].Header().Class != dns.ClassNONE || last.Ns[1].Header().Class != dns.ClassINET {
+		t.Fatalf("expected the update to remove and insert in one message, got %v", last.Ns)
+	}
+
+	if err := p.DeleteRecord(ctx, zone, updated.ID); err != nil {
+		t.Fatalf("DeleteRecord: %v", err)
+	}
+	if err := p.DeleteTXTRecord(ctx, zone, "txt.example.com.", long); err != nil {
+		t.Fatalf("DeleteTXTRecord: %v", err)
+	}
+	if zoneRecords, _ := server.state(); len(zoneRecords) != 0 {
+		t.Fatalf("expected every record removed, got %v", zoneRecords)
+	}
+	if _, err := p.GetRecord(ctx, zone, updated.ID); err == nil {
+		t.Fatal("expected the deleted record to be gone")
+	}
+}
+
+func TestProviderRejectedUpdate(t *testing.T) {
+	_, addr := startTestZoneServer(t, "example.com")
+	p := newTestProvider(t, addr, "d3JvbmctZ3Jvbmctd3Jvbmctd3Jvbmctd3Jvbmc=")
+	_, err := p.CreateRecord(context.Background(), dnsprovider.Zone{ID: "example.com", Name: "example.com"}, dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.1"})
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("expected an update signed with the wrong TSIG secret to be refused with NOTAUTH, got %v", err)
+	}
+}
+
+func TestRecordIDRoundTrip(t *testing.T) {
+	zone := dnsprovider.Zone{ID: "example.com", Name: "example.com"}
+	priority := uint16(5)
+	for _, record := range []dnsprovider.Record{
+		{Name: "@", Type: "MX", Content: "mail.example.com", Priority: &priority, TTL: 300},
+		{Name: "_sip._tcp", Type: "SRV", Content: "10 5060 sip.example.com", Priority: &priority, TTL: 300},
+		{Name: "alias", Type: "CNAME", Content: "www.example.com", TTL: 300},
+		{Name: "txt", Type: "TXT", Content: `"quoted value"`, TTL: 300},
+	} {
+		rr, err := rrFromRecord(zone, record)
+		if err != nil {
+			t.Fatalf("rrFromRecord %s: %v", record.Type, err)
+		}
+		decoded, err := rrFromID(recordID(rr))
+		if err != nil {
+			t.Fatalf("rrFromID %s: %v", record.Type, err)
+		}
+		got := recordFromRR(zone, decoded)
+		if got.Content != strings.Trim(record.Content, `"`) || got.Type != record.Type {
+			t.Fatalf("round trip of %+v gave %+v", record, got)
+		}
+		if record.Priority != nil && (got.Priority == nil || *got.Priority != *record.Priority) {
+			t.Fatalf("round trip of %s lost the priority", record.Type)
+		}
+	}
+}
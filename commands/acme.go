@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/cf_acme"
+	"github.com/urfave/cli/v3"
+)
+
+func AcmeBaseCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "acme",
+		EnableShellCompletion: true,
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Flags:                 acmeAccountFlags(),
+		Commands: []*cli.Command{
+			acmeAccountCommand(),
+			acmeRevokeCommand(),
+		},
+	}
+	return cmd
+}
+
+func acmeAccountFlags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "acme-url",
+			Value:   "https://acme-v02.api.letsencrypt.org/directory",
+			Usage:   "ACME directory url the account is registered with.",
+			Sources: cli.EnvVars("LE_ACME_URL"),
+		},
+		&cli.StringFlag{
+			Name:    "acme-email",
+			Usage:   "Email the ACME account is registered with.",
+			Sources: cli.EnvVars("LE_EMAIL"),
+		},
+		&cli.StringFlag{
+			Name:    "account-dir",
+			Value:   cf_acme.DefaultAccountStoreDir(),
+			Usage:   "Directory where ACME account keys and registrations are stored.",
+			Sources: cli.EnvVars("ACME_ACCOUNT_DIR"),
+		},
+	}
+	return flags
+}
+
+func acmeAccountRequestFromCmd(cmd *cli.Command) *cf_acme.AcmeAccountRequest {
+	return &cf_acme.AcmeAccountRequest{
+		AcmeEmail:  cmd.String("acme-email"),
+		AcmeUrl:    cmd.String("acme-url"),
+		AccountDir: cmd.String("account-dir"),
+	}
+}
+
+func acmeAccountCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "account",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "acme",
+		EnableShellCompletion: true,
+		Commands: []*cli.Command{
+			{
+				Name:     "show",
+				Aliases:  []string{"get", "cat"},
+				Category: "acme",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "query",
+						Usage: "Refresh the registration from the CA before printing.",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+					_, err = acmeAccountRequestFromCmd(cmd).CliShow(cmd.Bool("query"))
+					if err != nil {
+						logger.Errorf("error showing acme account err: %s", err.Error())
+					}
+					return err
+				},
+			},
+			{
+				Name:     "deactivate",
+				Category: "acme",
+				Usage:    "Permanently deactivate the ACME account and remove it from the account store.",
+				Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+					err = acmeAccountRequestFromCmd(cmd).CliDeactivate()
+					if err != nil {
+						logger.Errorf("error deactivating acme account err: %s", err.Error())
+					}
+					return err
+				},
+			},
+			{
+				Name:     "rotate-key",
+				Aliases:  []string{"rollover"},
+				Category: "acme",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "account-key-type",
+						Value: "EC256",
+						Usage: "Key type for the new account key: EC256, EC384, RSA2048, RSA3072, RSA4096, RSA8192",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+					keyType, err := cf_acme.ParseKeyType(cmd.String("account-key-type"))
+					if err != nil {
+						return err
+					}
+					_, err = acmeAccountRequestFromCmd(cmd).CliRotateKey(keyType)
+					if err != nil {
+						logger.Errorf("error rotating acme account key err: %s", err.Error())
+					}
+					return err
+				},
+			},
+		},
+	}
+	return cmd
+}
+
+func acmeRevokeCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "revoke",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "acme",
+		EnableShellCompletion: true,
+		Usage:                 "Revoke a certificate issued by acme-renew.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "cert-source",
+				Value: cf_acme.ExistingCertSourceZip,
+				Usage: "Where to read the certificate from: zip, pem, pem-dir or s3",
+			},
+			&cli.StringFlag{
+				Name:     "cert",
+				Aliases:  []string{"cert-path"},
+				Required: true,
+				Usage:    "Zip file, PEM file, PEM directory or S3 object name holding the certificate.",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "PEM private key for --sign-with-cert-key when it is not stored with the certificate.",
+			},
+			&cli.StringFlag{
+				Name:  "reason",
+				Value: "unspecified",
+				Usage: "RFC 5280 revocation reason name or code, e.g. keyCompromise or 1",
+			},
+			&cli.BoolFlag{
+				Name:  "sign-with-cert-key",
+				Usage: "Sign the revocation with the certificate's private key instead of the stored account key.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			reason, err := cf_acme.ParseRevocationReason(cmd.String("reason"))
+			if err != nil {
+				logger.Error(err.Error())
+				return err
+			}
+			revokeRequest := &cf_acme.RevokeCertificateRequest{
+				EnvFile:         cmd.String("env-file"),
+				AcmeEmail:       cmd.String("acme-email"),
+				AcmeUrl:         cmd.String("acme-url"),
+				AccountDir:      cmd.String("account-dir"),
+				CertSource:      cmd.String("cert-source"),
+				CertPath:        cmd.String("cert"),
+				KeyPath:         cmd.String("key"),
+				Reason:          reason,
+				SignWithCertKey: cmd.Bool("sign-with-cert-key"),
+			}
+			_, err = revokeRequest.CliRevoke()
+			if err != nil {
+				logger.Errorf("error revoking certificate err: %s cert: %s", err.Error(), cmd.String("cert"))
+			}
+			return err
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRotateKeyRejectsUnknownKeyType(t *testing.T) {
+	requests := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	args := []string{"acme", "--acme-url", srv.URL + "/directory", "--acme-email", "admin@example.com", "--account-dir", t.TempDir(), "account", "rotate-key", "--account-key-type", "EC521"}
+	err := AcmeBaseCommand().Run(context.Background(), args)
+	if err == nil || !strings.Contains(err.Error(), "unsupported key type") {
+		t.Fatalf("expected the key type refused, got %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("expected no request to the CA, got %d", requests.Load())
+	}
+}
@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/database/infracli_db"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// RecordAuditEntry is one row of the audit log with the record as it was before and after the change.
+type RecordAuditEntry struct {
+	ID         int64                 `json:"id"`
+	Created    string                `json:"created"`
+	User       string                `json:"user"`
+	ZoneID     string                `json:"zoneId"`
+	ZoneName   string                `json:"zoneName"`
+	RecordID   string                `json:"recordId"`
+	RecordName string                `json:"recordName"`
+	Action     string                `json:"action"`
+	Before     *cloudflare.DNSRecord `json:"before,omitempty"`
+	After      *cloudflare.DNSRecord `json:"after,omitempty"`
+}
+
+// auditDb is shared by every command utils copy so parallel bulk workers write through one
+// connection instead of contending for the sqlite lock.
+var (
+	auditDb      *sql.DB
+	auditDbMu    sync.Mutex
+	auditOffOnce sync.Once
+)
+
+// auditEnabled reports whether mutations are written to the audit log, which needs SQLITE_DB_PATH.
+func auditEnabled() bool {
+	return os.Getenv("SQLITE_DB_PATH") != ""
+}
+
+func openAuditDb() (*sql.DB, error) {
+	auditDbMu.Lock()
+	defer auditDbMu.Unlock()
+	if auditDb != nil {
+		return auditDb, nil
+	}
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_DB_PATH"))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	auditDb = db
+	return auditDb, nil
+}
+
+// checkAuditLog is run before every change so a change that cannot be recorded is not made.
+// Without SQLITE_DB_PATH changes go unaudited with a warning once per run, an audit log that
+// cannot be opened or lacks its table refuses the change unless AllowUnaudited is set.
+func (cfcmd *CloudflareCommandUtils) checkAuditLog() error {
+	if !auditEnabled() {
+		auditOffOnce.Do(func() {
+			logger.Warning("SQLITE_DB_PATH is not set, record changes are not written to the audit log")
+		})
+		return nil
+	}
+	db, err := openAuditDb()
+	if err == nil {
+		_, err = db.ExecContext(context.Background(), "SELECT 1 FROM record_audit_log LIMIT 0")
+	}
+	if err == nil {
+		return nil
+	}
+	if cfcmd.AllowUnaudited {
+		logger.Warning(fmt.Sprintf("audit log is not usable, making the change without it: %s", err.Error()))
+		return nil
+	}
+	return fmt.Errorf("audit log in %s is not usable, refusing the change, pass --allow-unaudited to make it anyway: %w", os.Getenv("SQLITE_DB_PATH"), err)
+}
+
+func osUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func auditJson(record *cloudflare.DNSRecord) sql.NullString {
+	if record == nil {
+		return sql.NullString{}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// auditBefore fetches the record with GetDnsRecord ahead of a change. Errors are logged and leave
+// cfcmd.Error untouched so the change itself still runs.
+func (cfcmd *CloudflareCommandUtils) auditBefore(recordId string) *cloudflare.DNSRecord {
+	if !auditEnabled() {
+		return nil
+	}
+	cmdErr := cfcmd.Error
+	record := cfcmd.GetDnsRecord(recordId)
+	err := cfcmd.Error
+	cfcmd.Error = cmdErr
+	if err != nil {
+		logger.Warning(fmt.Sprintf("error fetching record %s for the audit log: %s", recordId, err.Error()))
+		return nil
+	}
+	return &record
+}
+
+// auditRecordChange writes a row to the audit log. The change has already been made when it
+// runs, so a failure sets cfcmd.Error to report it unless AllowUnaudited is set.
+func (cfcmd *CloudflareCommandUtils) auditRecordChange(action string, recordId string, name string, before *cloudflare.DNSRecord, after *cloudflare.DNSRecord) {
+	if !auditEnabled() {
+		return
+	}
+	if name != "" {
+		name = dnsprovider.QualifyName(name, cfcmd.ZoneName)
+	}
+	db, err := openAuditDb()
+	if err == nil {
+		params := infracli_db.CreateRecordAuditLogParams{
+			OsUser:     osUserName(),
+			ZoneUid:    cfcmd.ZomeId,
+			ZoneName:   cfcmd.ZoneName,
+			RecordUid:  recordId,
+			RecordName: name,
+			Action:     action,
+			BeforeJson: auditJson(before),
+			AfterJson:  auditJson(after),
+		}
+		err = infracli_db.New(db).CreateRecordAuditLog(context.Background(), params)
+	}
+	if err == nil {
+		return
+	}
+	if cfcmd.AllowUnaudited {
+		logger.Warning(fmt.Sprintf("error writing %s of %s to the audit log: %s", action, name, err.Error()))
+		return
+	}
+	cfcmd.Error = fmt.Errorf("%s of %s was made but could not be written to the audit log: %w", action, name, err)
+	logger.Error(cfcmd.Error.Error())
+}
+
+// RecordHistory returns the newest limit audit log entries of the zone, only those of the record
+// name when it is set.
+func (cfcmd *CloudflareCommandUtils) RecordHistory(name string, limit int) []RecordAuditEntry {
+	entries := []RecordAuditEntry{}
+	db, err := openAuditDb()
+	if err != nil {
+		cfcmd.Error = err
+		return entries
+	}
+	queries := infracli_db.New(db)
+	var rows []infracli_db.RecordAuditLog
+	if name != "" {
+		params := infracli_db.GetRecordAuditLogByRecordNameParams{ZoneUid: cfcmd.ZomeId, RecordName: dnsprovider.QualifyName(name, cfcmd.ZoneName), Limit: int64(limit)}
+		rows, cfcmd.Error = queries.GetRecordAuditLogByRecordName(context.Background(), params)
+	} else {
+		params := infracli_db.GetRecordAuditLogByZoneUidParams{ZoneUid: cfcmd.ZomeId, Limit: int64(limit)}
+		rows, cfcmd.Error = queries.GetRecordAuditLogByZoneUid(context.Background(), params)
+	}
+	for _, v := range rows {
+		entry := RecordAuditEntry{ID: v.ID, Created: v.Created, User: v.OsUser, ZoneID: v.ZoneUid, ZoneName: v.ZoneName, RecordID: v.RecordUid, RecordName: v.RecordName, Action: v.Action}
+		entry.Before = auditRecordFromJson(v.ID, "before", v.BeforeJson)
+		entry.After = auditRecordFromJson(v.ID, "after", v.AfterJson)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// auditRecordFromJson decodes a before or after column. A row that cannot be decoded is logged
+// and shown without the record rather than hiding the rest of the history.
+func auditRecordFromJson(id int64, column string, data sql.NullString) *cloudflare.DNSRecord {
+	if !data.Valid {
+		return nil
+	}
+	record := &cloudflare.DNSRecord{}
+	if err := json.Unmarshal([]byte(data.String), record); err != nil {
+		logger.Warning(fmt.Sprintf("error decoding the %s record of audit log entry %d: %s", column, id, err.Error()))
+		return nil
+	}
+	return record
+}
+
+func PrintRecordHistory(entries []RecordAuditEntry) error {
+	var colorInt int32 = 97
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dmTime\tUser\tAction\tName\tType\tRecordID\tDetails\x1b[0m\n", colorInt)
+	fmt.Fprintf(tw, "\x1b[1;%dm----\t----\t------\t----\t----\t--------\t-------\x1b[0m\n", colorInt)
+	for _, v := range entries {
+		recordType, details := "", ""
+		switch {
+		case v.Action == AuditActionUpdate && v.Before != nil && v.After != nil:
+			colorInt = 93
+			changes := storedRecordDiff(*v.Before, *v.After)
+			if v.Before.Name != v.After.Name {
+				changes = append([]string{fmt.Sprintf("name %s -> %s", v.Before.Name, v.After.Name)}, changes...)
+			}
+			recordType, details = v.After.Type, strings.Join(changes, ", ")
+		case v.Action == AuditActionCreate && v.After != nil:
+			colorInt = 92
+			recordType, details = v.After.Type, fmt.Sprintf("content %s ttl %d", v.After.Content, v.After.TTL)
+		case v.Action == AuditActionDelete && v.Before != nil:
+			colorInt = 91
+			recordType, details = v.Before.Type, fmt.Sprintf("content %s ttl %d", v.Before.Content, v.Before.TTL)
+		default:
+			colorInt = 97
+		}
+		fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%s\t%s\x1b[0m\n", colorInt, v.Created, v.User, v.Action, v.RecordName, recordType, v.RecordID, details)
+	}
+	return tw.Flush()
+}
+
+func historyCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "history",
+		Version:               versionNumber,
+		Aliases:               []string{"audit-log"},
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Show the audit log of record changes made in the zone, only those of --record-name when set.",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "limit",
+				Value: 50,
+				Usage: "Number of entries to show, newest first.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			cfcmd := dnsCommandFromCli(cmd)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			if !auditEnabled() {
+				err = fmt.Errorf("SQLITE_DB_PATH is not set, there is no audit log to show")
+				logger.Error(err.Error())
+				return err
+			}
+			entries := cfcmd.RecordHistory(cmd.String("record-name"), int(cmd.Int("limit")))
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(entries)
+				return cfcmd.Error
+			}
+			if len(entries) == 0 {
+				logger.Info(fmt.Sprintf("no changes recorded in %s", cfcmd.ZoneName))
+				return nil
+			}
+			return PrintRecordHistory(entries)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// useTestAuditDb points the audit log at db for the rest of the test.
+func useTestAuditDb(t *testing.T, db *sql.DB) {
+	t.Helper()
+	t.Setenv("SQLITE_DB_PATH", filepath.Join(t.TempDir(), "audit.db"))
+	auditDbMu.Lock()
+	auditDb = db
+	auditDbMu.Unlock()
+	t.Cleanup(func() {
+		auditDbMu.Lock()
+		auditDb = nil
+		auditDbMu.Unlock()
+	})
+}
+
+func TestAuditRecordChanges(t *testing.T) {
+	useTestAuditDb(t, newTestDb(t))
+	cfcmd := newTestCommand(newTestProvider("example.com", dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}))
+
+	created := cfcmd.CreateOrUpdateDNSRecord(cloudflare.CreateDNSRecordParams{Name: "api", Type: "A", Content: "192.0.2.2", TTL: 300})
+	cfcmd.CreateOrUpdateDNSRecord(cloudflare.UpdateDNSRecordParams{ID: created.ID, Content: "192.0.2.3"})
+	cfcmd.DeleteCloudflareRecord(created.ID)
+	cfcmd.CreateOrUpdateDNSRecord(cloudflare.UpdateDNSRecordParams{ID: "rec1", TTL: 600})
+	if cfcmd.Error != nil {
+		t.Fatalf("changes failed: %v", cfcmd.Error)
+	}
+
+	entries := cfcmd.RecordHistory("api", 10)
+	if cfcmd.Error != nil {
+		t.Fatalf("RecordHistory: %v", cfcmd.Error)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected the three changes of api, got %+v", entries)
+	}
+	del, update, create := entries[0], entries[1], entries[2]
+	if create.Action != AuditActionCreate || create.Before != nil || create.After == nil || create.After.Content != "192.0.2.2" {
+		t.Fatalf("unexpected create entry %+v", create)
+	}
+	if update.Action != AuditActionUpdate || update.Before == nil || update.Before.Content != "192.0.2.2" || update.After == nil || update.After.Content != "192.0.2.3" {
+		t.Fatalf("unexpected update entry %+v", update)
+	}
+	if del.Action != AuditActionDelete || del.Before == nil || del.Before.Content != "192.0.2.3" || del.After != nil {
+		t.Fatalf("unexpected delete entry %+v", del)
+	}
+	for _, v := range entries {
+		if v.RecordName != "api.example.com" || v.RecordID != created.ID || v.ZoneID != "zone1" {
+			t.Fatalf("expected entries of %s in zone1, got %+v", created.ID, v)
+		}
+	}
+
+	if entries := cfcmd.RecordHistory("", 10); len(entries) != 4 || entries[0].RecordName != "www.example.com" {
+		t.Fatalf("expected every change of the zone newest first, got %+v", entries)
+	}
+	if entries := cfcmd.RecordHistory("www.example.com", 10); len(entries) != 1 || entries[0].Before.TTL != 300 || entries[0].After.TTL != 600 {
+		t.Fatalf("expected the www ttl change, got %+v", entries)
+	}
+}
+
+func TestAuditRefusesUnrecordedChanges(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "empty.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	useTestAuditDb(t, db)
+	p := newTestProvider("example.com")
+	cfcmd := newTestCommand(p)
+
+	cfcmd.CreateOrUpdateDNSRecord(cloudflare.CreateDNSRecordParams{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300})
+	if cfcmd.Error == nil || !strings.Contains(cfcmd.Error.Error(), "--allow-unaudited") || len(p.records) != 0 {
+		t.Fatalf("expected the create refused without the audit log table, got %v with %d records", cfcmd.Error, len(p.records))
+	}
+
+	cfcmd.Error = nil
+	cfcmd.AllowUnaudited = true
+	cfcmd.CreateOrUpdateDNSRecord(cloudflare.CreateDNSRecordParams{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300})
+	if cfcmd.Error != nil || len(p.records) != 1 {
+		t.Fatalf("expected --allow-unaudited to make the change, got %v with %d records", cfcmd.Error, len(p.records))
+	}
+}
+
+func TestRecordHistoryKeepsUndecodableEntries(t *testing.T) {
+	db := newTestDb(t)
+	useTestAuditDb(t, db)
+	_, err := db.Exec(`INSERT INTO record_audit_log (os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json)
+		VALUES ('test', 'zone1', 'example.com', 'rec1', 'www.example.com', 'update', '{not json', '{"content":"192.0.2.2"}')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfcmd := newTestCommand(newTestProvider("example.com"))
+	entries := cfcmd.RecordHistory("www", 10)
+	if cfcmd.Error != nil || len(entries) != 1 || entries[0].Before != nil || entries[0].After.Content != "192.0.2.2" {
+		t.Fatalf("expected the entry without its undecodable before record, got %+v, %v", entries, cfcmd.Error)
+	}
+}
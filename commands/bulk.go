@@ -0,0 +1,404 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/cf_acme"
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/internal/pretty"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+	BulkUpsert = "upsert"
+)
+
+// BulkChange is one row of a bulk file. Update and delete use ID when set and otherwise select
+// the record by name and type, delete also by content.
+type BulkChange struct {
+	Line     int      `json:"line"`
+	Action   string   `json:"action"`
+	ID       string   `json:"id,omitempty"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type,omitempty"`
+	Content  string   `json:"content,omitempty"`
+	TTL      int      `json:"ttl,omitempty"`
+	Priority *uint16  `json:"priority,omitempty"`
+	Proxied  *bool    `json:"proxied,omitempty"`
+	Comment  string   `json:"comment,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+type BulkResult struct {
+	Change   BulkChange `json:"change"`
+	RecordID string     `json:"recordId,omitempty"`
+	Attempts int        `json:"attempts"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// LoadBulkChanges reads CSV with a header row, or JSON lines when the file ends in .json,
+// .jsonl or .ndjson or starts with {. CSV tags are separated by ;.
+func LoadBulkChanges(path string) ([]BulkChange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var changes []BulkChange
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".json" || ext == ".jsonl" || ext == ".ndjson" || strings.HasPrefix(strings.TrimSpace(string(data)), "{"):
+		changes, err = parseBulkJsonLines(strings.NewReader(string(data)))
+	default:
+		changes, err = parseBulkCsv(strings.NewReader(string(data)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	for i, v := range changes {
+		changes[i].Action = strings.ToLower(strings.TrimSpace(v.Action))
+		changes[i].Type = strings.ToUpper(strings.TrimSpace(v.Type))
+		switch changes[i].Action {
+		case BulkCreate, BulkUpdate, BulkDelete, BulkUpsert:
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q, use create, update, delete or upsert", v.Line, v.Action)
+		}
+		if v.ID == "" && v.Name == "" {
+			return nil, fmt.Errorf("line %d: needs an id or a name", v.Line)
+		}
+	}
+	return changes, nil
+}
+
+func parseBulkJsonLines(r io.Reader) ([]BulkChange, error) {
+	changes := []BulkChange{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		change := BulkChange{}
+		if err := json.Unmarshal([]byte(text), &change); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		change.Line = line
+		changes = append(changes, change)
+	}
+	return changes, scanner.Err()
+}
+
+func parseBulkCsv(r io.Reader) ([]BulkChange, error) {
+	changes := []BulkChange{}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	if _, ok := columns["action"]; !ok {
+		return nil, fmt.Errorf("csv header needs an action column")
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		change := BulkChange{Line: line, Action: field("action"), ID: field("id"), Name: field("name"), Type: field("type"), Content: field("content"), Comment: field("comment")}
+		if v := field("ttl"); v != "" {
+			change.TTL, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid ttl %q", line, v)
+			}
+		}
+		if v := field("priority"); v != "" {
+			priority, err := strconv.ParseUint(v, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid priority %q", line, v)
+			}
+			pr16 := uint16(priority)
+			change.Priority = &pr16
+		}
+		if v := field("proxied"); v != "" {
+			proxied, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid proxied %q", line, v)
+			}
+			change.Proxied = &proxied
+		}
+		for _, tag := range strings.Split(field("tags"), ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				change.Tags = append(change.Tags, tag)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// isRateLimited reports whether err asks the client to slow down and retry.
+func isRateLimited(err error) bool {
+	return errors.Is(err, dnsprovider.ErrRateLimited) || cf_acme.IsRateLimitError(err)
+}
+
+// applyBulkChange runs one row, returning the ID of the record it touched.
+func (cfcmd *CloudflareCommandUtils) applyBulkChange(change BulkChange) (string, error) {
+	cfcmd.Error = nil
+	name := ""
+	if change.Name != "" {
+		name = dnsprovider.QualifyName(change.Name, cfcmd.ZoneName)
+	}
+	create := cloudflare.CreateDNSRecordParams{Name: name, Type: change.Type, Content: change.Content, TTL: change.TTL, Priority: change.Priority, Proxied: change.Proxied, Comment: change.Comment, Tags: change.Tags}
+	update := cloudflare.UpdateDNSRecordParams{ID: change.ID, Content: change.Content, TTL: change.TTL, Priority: change.Priority, Proxied: change.Proxied, Tags: change.Tags}
+	if change.Comment != "" {
+		update.Comment = &change.Comment
+	}
+	if change.ID != "" {
+		update.Name, update.Type = name, change.Type
+	}
+
+	action := change.Action
+	if change.ID == "" && action != BulkCreate {
+		selectContent := ""
+		if action == BulkDelete {
+			selectContent = change.Content
+		}
+		records := cfcmd.FindDnsRecords(name, change.Type, selectContent, false)
+		switch {
+		case action == BulkUpsert && len(records) == 0:
+			action, cfcmd.Error = BulkCreate, nil
+		case action == BulkUpsert && len(records) > 1:
+			// Several records at the name are fine when one of them already has the content.
+			cfcmd.Error = fmt.Errorf("%d records match %s %s and none has content %s", len(records), name, change.Type, change.Content)
+			for _, v := range records {
+				if normalizeContent(v.Type, v.Content) == normalizeContent(change.Type, change.Content) {
+					records, cfcmd.Error = []cloudflare.DNSRecord{v}, nil
+					break
+				}
+			}
+		}
+		if cfcmd.Error != nil {
+			return "", cfcmd.Error
+		}
+		if action != BulkCreate {
+			update.ID = records[0].ID
+		}
+	}
+
+	switch action {
+	case BulkCreate:
+		record := cfcmd.CreateOrUpdateDNSRecord(create)
+		return record.ID, cfcmd.Error
+	case BulkUpdate, BulkUpsert:
+		record := cfcmd.CreateOrUpdateDNSRecord(update)
+		return record.ID, cfcmd.Error
+	case BulkDelete:
+		cfcmd.DeleteCloudflareRecord(update.ID)
+		return update.ID, cfcmd.Error
+	}
+	return "", fmt.Errorf("unknown action %q", action)
+}
+
+// bulkChangeGroups returns the indexes of changes grouped by name and type in file order. Rows
+// without a name can only be grouped by their ID.
+func bulkChangeGroups(changes []BulkChange, zoneName string) [][]int {
+	groups := [][]int{}
+	groupIndex := make(map[string]int)
+	for i, v := range changes {
+		key := "id|" + v.ID
+		if v.Name != "" {
+			key = rrsetKey(dnsprovider.QualifyName(v.Name, zoneName), v.Type)
+		}
+		g, ok := groupIndex[key]
+		if !ok {
+			g = len(groups)
+			groupIndex[key] = g
+			groups = append(groups, []int{})
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// RunBulkChanges applies changes with up to workers in parallel. Rows for the same name and type
+// run in file order on one worker so they do not race, whether they address the record by ID or
+// by name. Rate limited rows are retried up to retries times with a jittered backoff from one
+// second up to 30 seconds.
+func (cfcmd *CloudflareCommandUtils) RunBulkChanges(ctx context.Context, changes []BulkChange, workers int, retries int) []BulkResult {
+	results := make([]BulkResult, len(changes))
+	groups := bulkChangeGroups(changes, cfcmd.ZoneName)
+
+	jobs := make(chan []int)
+	wg := sync.WaitGroup{}
+	for range max(workers, 1) {
+		// Each worker gets its own copy since the command utils report errors through a field.
+		worker := &CloudflareCommandUtils{ZomeId: cfcmd.ZomeId, ZoneName: cfcmd.ZoneName, EnvFile: cfcmd.EnvFile, ApiClient: cfcmd.ApiClient, Provider: cfcmd.Provider, UseEnv: cfcmd.UseEnv, AllowUnaudited: cfcmd.AllowUnaudited}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range jobs {
+				for _, i := range group {
+					results[i] = worker.runBulkChange(ctx, changes[i], retries)
+				}
+			}
+		}()
+	}
+	for _, group := range groups {
+		jobs <- group
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (cfcmd *CloudflareCommandUtils) runBulkChange(ctx context.Context, change BulkChange, retries int) BulkResult {
+	result := BulkResult{Change: change}
+	backoff := time.Second
+	for {
+		result.Attempts++
+		id, err := cfcmd.applyBulkChange(change)
+		if err == nil {
+			result.RecordID = id
+			return result
+		}
+		if !isRateLimited(err) || result.Attempts > retries || ctx.Err() != nil {
+			result.Error = err.Error()
+			return result
+		}
+		wait := backoff + rand.N(backoff/2)
+		logger.Warning(fmt.Sprintf("line %d rate limited, retrying in %s", change.Line, wait.Round(time.Millisecond)))
+		select {
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+			return result
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func PrintBulkResults(results []BulkResult) {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Change.Line < results[j].Change.Line })
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\x1b[0m\n", 97, "Line", "Action", "Name", "Type", "Content", "Status", "Attempts", "Detail")
+	fmt.Fprintf(tw, "\x1b[1;%dm----\t------\t----\t----\t-------\t------\t--------\t------\x1b[0m\n", 97)
+	failed := 0
+	for _, v := range results {
+		var colorInt int32 = 92
+		status, detail := "ok", v.RecordID
+		if v.Error != "" {
+			colorInt, status, detail = 91, "failed", v.Error
+			failed++
+		}
+		name := v.Change.Name
+		if name == "" {
+			name = v.Change.ID
+		}
+		fmt.Fprintf(tw, "\x1b[1;%dm%d\t%s\t%s\t%s\t%s\t%s\t%d\t%s\x1b[0m\n", colorInt, v.Change.Line, v.Change.Action, name, v.Change.Type, v.Change.Content, status, v.Attempts, detail)
+	}
+	tw.Flush()
+	fmt.Println()
+
+	if failed > 0 {
+		pretty.PrintErrorf("%d of %d changes failed.", failed, len(results))
+		return
+	}
+	pretty.Printf("All %d changes applied.", len(results))
+}
+
+func bulkCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "bulk",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Apply create, update, delete and upsert rows from a CSV or JSON lines file.",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Required: true,
+				Usage:    "CSV with an action,id,name,type,content,ttl,priority,proxied,comment,tags header, or JSON lines with the same fields.",
+			},
+			&cli.IntFlag{
+				Name:  "workers",
+				Value: 4,
+				Usage: "Number of changes applied in parallel.",
+			},
+			&cli.IntFlag{
+				Name:  "retries",
+				Value: 5,
+				Usage: "Retries for a change after a rate limit response.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			changes, err := LoadBulkChanges(cmd.String("file"))
+			if err != nil {
+				logger.Error(err.Error())
+				return err
+			}
+			cfcmd := dnsCommandFromCli(cmd)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+
+			results := cfcmd.RunBulkChanges(ctx, changes, int(cmd.Int("workers")), int(cmd.Int("retries")))
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(results)
+			} else {
+				PrintBulkResults(results)
+			}
+			failed := 0
+			for _, v := range results {
+				if v.Error != "" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d bulk changes failed", failed, len(results))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/cf_acme"
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// newRateLimitedCloudflareCommand returns command utils for a Cloudflare API that answers the
+// first limited requests with 429. The client does not retry, so every 429 reaches the caller.
+func newRateLimitedCloudflareCommand(t *testing.T, limited int32) (*CloudflareCommandUtils, *atomic.Int32) {
+	t.Helper()
+	requests := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= limited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"success":false,"errors":[{"code":971,"message":"Please wait and consider throttling your request speed"}],"messages":[],"result":null}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"errors":[],"messages":[],"result":{"id":"rec1","name":"www.example.com","type":"A","content":"192.0.2.1","ttl":300}}`))
+	}))
+	t.Cleanup(srv.Close)
+	api, err := cloudflare.NewWithAPIToken("test-token", cloudflare.BaseURL(srv.URL), cloudflare.UsingRetryPolicy(0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfcmd := &CloudflareCommandUtils{ZomeId: "zone1", ZoneName: "example.com", ApiClient: api, Provider: cf_acme.NewCloudflareProvider(api)}
+	return cfcmd, requests
+}
+
+func TestIsRateLimited(t *testing.T) {
+	rateLimit := cloudflare.NewRatelimitError(&cloudflare.Error{StatusCode: http.StatusTooManyRequests})
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "provider error", err: fmt.Errorf("create: %w", dnsprovider.ErrRateLimited), want: true},
+		{name: "cloudflare rate limit error", err: fmt.Errorf("create: %w", &rateLimit), want: true},
+		{name: "cloudflare retries exhausted", err: errors.New("exceeded available rate limit retries"), want: true},
+		{name: "other error", err: errors.New("record already exists"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRateLimited(tt.err); got != tt.want {
+				t.Fatalf("expected %t for %v", tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunBulkChangeRetriesCloudflareRateLimit(t *testing.T) {
+	cfcmd, requests := newRateLimitedCloudflareCommand(t, 1)
+	change := BulkChange{Line: 2, Action: BulkCreate, Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}
+
+	result := cfcmd.runBulkChange(context.Background(), change, 2)
+	if result.Error != "" || result.RecordID != "rec1" {
+		t.Fatalf("expected the create to succeed after the 429, got %+v", result)
+	}
+	if result.Attempts != 2 || requests.Load() != 2 {
+		t.Fatalf("expected 2 attempts and requests, got %d and %d", result.Attempts, requests.Load())
+	}
+}
+
+func TestRunBulkChangeGivesUpAfterRetries(t *testing.T) {
+	cfcmd, requests := newRateLimitedCloudflareCommand(t, 100)
+	change := BulkChange{Line: 2, Action: BulkCreate, Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}
+
+	result := cfcmd.runBulkChange(context.Background(), change, 1)
+	if result.Error == "" || result.Attempts != 2 || requests.Load() != 2 {
+		t.Fatalf("expected the change to fail after one retry, got %+v with %d requests", result, requests.Load())
+	}
+}
+
+func TestBulkChangeGroups(t *testing.T) {
+	changes := []BulkChange{
+		{Line: 2, Action: BulkUpdate, ID: "rec1", Name: "www", Type: "A", Content: "192.0.2.2"},
+		{Line: 3, Action: BulkCreate, Name: "api", Type: "A", Content: "192.0.2.3"},
+		{Line: 4, Action: BulkDelete, Name: "www.example.com", Type: "A", Content: "192.0.2.2"},
+		{Line: 5, Action: BulkDelete, ID: "rec9"},
+		{Line: 6, Action: BulkUpdate, Name: "www", Type: "AAAA", Content: "2001:db8::2"},
+		{Line: 7, Action: BulkUpdate, ID: "rec9", TTL: 600},
+	}
+	got := fmt.Sprint(bulkChangeGroups(changes, "example.com"))
+	if want := "[[0 2] [1] [3 5] [4]]"; got != want {
+		t.Fatalf("expected groups %s, got %s", want, got)
+	}
+}
+
+func TestLoadBulkChanges(t *testing.T) {
+	csvFile := `# changes for the web tier
+Name, ACTION ,type,content,ttl,priority,proxied,tags,comment
+www,create,a,192.0.2.1,300,,true,env:prod; team:web ;,web front
+mail,Upsert,MX,mail.example.com,,10,,,
+`
+	jsonLines := `{"action":"delete","name":"old","type":"cname"}
+
+{"action":"update","id":"rec1","content":"192.0.2.9","tags":["a","b"]}
+`
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		want    []string
+		wantErr string
+	}{
+		{name: "csv header in any order and case", file: "changes.csv", data: csvFile, want: []string{
+			"3 create www A 192.0.2.1 ttl 300 proxied true tags [env:prod team:web] comment web front",
+			"4 upsert mail MX mail.example.com priority 10",
+		}},
+		{name: "json lines by extension", file: "changes.jsonl", data: jsonLines, want: []string{
+			"1 delete old CNAME",
+			"3 update rec1 192.0.2.9 tags [a b]",
+		}},
+		{name: "json lines by content", file: "changes.txt", data: jsonLines, want: []string{
+			"1 delete old CNAME",
+			"3 update rec1 192.0.2.9 tags [a b]",
+		}},
+		{name: "csv without an action column", file: "changes.csv", data: "name,type\nwww,A\n", wantErr: "action column"},
+		{name: "invalid ttl", file: "changes.csv", data: "action,name,ttl\ncreate,www,soon\n", wantErr: `line 2: invalid ttl "soon"`},
+		{name: "unknown action", file: "changes.csv", data: "action,name\nrename,www\n", wantErr: `line 2: unknown action "rename"`},
+		{name: "row without id or name", file: "changes.jsonl", data: `{"action":"delete","type":"A"}`, wantErr: "line 1: needs an id or a name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			changes, err := LoadBulkChanges(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadBulkChanges: %v", err)
+			}
+			got := []string{}
+			for _, v := range changes {
+				got = append(got, bulkChangeSummary(v))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected\n%s\ngot\n%s", strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+// bulkChangeSummary prints the line, action, id or name, type, content and the optional fields
+// that are set.
+func bulkChangeSummary(v BulkChange) string {
+	summary := strings.Join(strings.Fields(fmt.Sprintf("%d %s %s %s %s %s", v.Line, v.Action, v.ID, v.Name, v.Type, v.Content)), " ")
+	if v.TTL != 0 {
+		summary += fmt.Sprintf(" ttl %d", v.TTL)
+	}
+	if v.Priority != nil {
+		summary += fmt.Sprintf(" priority %d", *v.Priority)
+	}
+	if v.Proxied != nil {
+		summary += fmt.Sprintf(" proxied %t", *v.Proxied)
+	}
+	if len(v.Tags) > 0 {
+		summary += fmt.Sprintf(" tags %v", v.Tags)
+	}
+	if v.Comment != "" {
+		summary += " comment " + v.Comment
+	}
+	return summary
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/babbage88/go-acme-cli/cloud_providers/cf_acme"
 	"github.com/babbage88/go-acme-cli/internal/pretty"
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/joho/godotenv"
@@ -49,14 +50,14 @@ func GetCloudFlareZoneIdByDomainName(envfile string, domainName string) (string,
 		return "", err
 	}
 
-	zoneID, err := api.ZoneIDByName(domainName)
+	match, err := cf_acme.ZoneResolverForApi(api).Resolve(domainName)
 	if err != nil {
 		msg := fmt.Sprintf("Error retrieving ZoneId for Domain: %s error: %s", domainName, err.Error())
 		slog.Error(msg)
-		return zoneID, err
+		return match.ZoneID, err
 	}
 
-	return zoneID, err
+	return match.ZoneID, err
 }
 
 func UpdateCloudflareDnsRecord(envfile string, zoneId string, recordUpdateParams cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
@@ -93,13 +94,14 @@ func ListCloudflareDnsWithQueryParams(envfile string, domainName string, params
 		return records, result, err
 	}
 
-	zoneID, err := api.ZoneIDByName(domainName)
+	match, err := cf_acme.ZoneResolverForApi(api).Resolve(domainName)
+	zoneID := match.ZoneID
 	if err != nil {
 		slog.Debug("Error retrieving ZoneId for domain name", slog.String("DomainName", domainName))
 		return records, result, err
 	}
 
-	records, result, err = api.ListDNSRecords(context.Background(), cloudflare.ZoneIdentifier(zoneID), params)
+	records, result, err = cf_acme.ListAllCloudflareDnsRecords(context.Background(), api, zoneID, params)
 	if err != nil {
 		return records, result, err
 	}
@@ -113,13 +115,14 @@ func GetAllCloudflareDnsRecordByDomain(envfile string, domainName string) ([]clo
 		return records, err
 	}
 
-	zoneID, err := api.ZoneIDByName(domainName)
+	match, err := cf_acme.ZoneResolverForApi(api).Resolve(domainName)
+	zoneID := match.ZoneID
 	if err != nil {
 		slog.Debug("Error retrieving ZoneId for domain name", slog.String("DomainName", domainName))
 		return records, err
 	}
 
-	records, _, err = api.ListDNSRecords(context.Background(), cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{})
+	records, _, err = cf_acme.ListAllCloudflareDnsRecords(context.Background(), api, zoneID, cloudflare.ListDNSRecordsParams{})
 	if err != nil {
 		return records, err
 	}
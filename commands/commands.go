@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/babbage88/go-acme-cli/cloud_providers/cf_acme"
 	"github.com/babbage88/go-acme-cli/internal/bumper"
+	"github.com/babbage88/go-acme-cli/internal/pretty"
 	"github.com/cloudflare/cloudflare-go"
+	"github.com/joho/godotenv"
 	"github.com/urfave/cli/v3"
 )
 
@@ -40,6 +44,16 @@ func DnsBaseCommand() []*cli.Command {
 					Usage:   "ACME url where renewal requests are sent.",
 					Sources: cli.EnvVars("LE_ACME_URL"),
 				},
+				&cli.StringFlag{
+					Name:    "eab-kid",
+					Usage:   "External Account Binding key id, required by CAs such as ZeroSSL, Google Trust Services, Sectigo and step-ca.",
+					Sources: cli.EnvVars("LE_EAB_KID"),
+				},
+				&cli.StringFlag{
+					Name:    "eab-hmac",
+					Usage:   "Base64url encoded External Account Binding HMAC key.",
+					Sources: cli.EnvVars("LE_EAB_HMAC"),
+				},
 				&cli.StringFlag{
 					Name:    "zip-name",
 					Value:   "certs.zip",
@@ -72,10 +86,120 @@ func DnsBaseCommand() []*cli.Command {
 					Value: []string{"1.1.1.1", "1.0.0.1"},
 					Usage: "Token executing DNS canges through the Cloudflare API.",
 				},
+				&cli.StringFlag{
+					Name:    "dns-provider",
+					Value:   cf_acme.DnsProviderCloudflare,
+					Usage:   "DNS backend for dns-01 challenges: cloudflare, rfc2136 or powerdns. Settings are read from --env-file, DNS_ZONE_PROVIDERS=zone=provider,... picks a backend per zone.",
+					Sources: cli.EnvVars("DNS_PROVIDER"),
+				},
+				&cli.StringSliceFlag{
+					Name:    "challenge-zone-token",
+					Usage:   "zone=token for CNAME delegated _acme-challenge records whose validation zone needs a different Cloudflare token.",
+					Sources: cli.EnvVars("CF_CHALLENGE_ZONE_TOKENS"),
+				},
+				&cli.StringFlag{
+					Name:    "challenge",
+					Value:   cf_acme.ChallengeDns01,
+					Usage:   "ACME challenge type: dns-01 through Cloudflare, http-01 or tls-alpn-01",
+					Sources: cli.EnvVars("LE_CHALLENGE"),
+				},
+				&cli.StringFlag{
+					Name:    "http-mode",
+					Value:   cf_acme.HttpChallengeListener,
+					Usage:   "How http-01 challenges are served: listener, webroot or s3",
+					Sources: cli.EnvVars("LE_HTTP_MODE"),
+				},
+				&cli.StringFlag{
+					Name:    "http-address",
+					Value:   ":80",
+					Usage:   "Address the built-in http-01 listener binds to.",
+					Sources: cli.EnvVars("LE_HTTP_ADDRESS"),
+				},
+				&cli.StringFlag{
+					Name:    "webroot",
+					Usage:   "Directory served as the site root, challenge files are written to .well-known/acme-challenge below it.",
+					Sources: cli.EnvVars("LE_HTTP_WEBROOT"),
+				},
+				&cli.StringFlag{
+					Name:    "http-s3-bucket",
+					Usage:   "Bucket serving the site for http-01 s3 mode. Defaults to S3_DEFAULT_BUCKET.",
+					Sources: cli.EnvVars("LE_HTTP_S3_BUCKET"),
+				},
+				&cli.StringFlag{
+					Name:    "http-s3-prefix",
+					Usage:   "Object prefix the site root is served from in the http-01 s3 bucket.",
+					Sources: cli.EnvVars("LE_HTTP_S3_PREFIX"),
+				},
+				&cli.StringFlag{
+					Name:    "tls-alpn-address",
+					Value:   ":443",
+					Usage:   "Address the tls-alpn-01 listener binds to.",
+					Sources: cli.EnvVars("LE_TLS_ALPN_ADDRESS"),
+				},
+				&cli.StringFlag{
+					Name:    "account-dir",
+					Value:   cf_acme.DefaultAccountStoreDir(),
+					Usage:   "Directory where ACME account keys and registrations are stored and reused across renewals.",
+					Sources: cli.EnvVars("ACME_ACCOUNT_DIR"),
+				},
+				&cli.StringFlag{
+					Name:    "existing-cert-source",
+					Aliases: []string{"check-cert-source"},
+					Usage:   "Check the existing certificate before renewing: zip, pem, pem-dir or s3. Renews unconditionally when unset.",
+					Sources: cli.EnvVars("LE_EXISTING_CERT_SOURCE"),
+				},
+				&cli.StringFlag{
+					Name:    "existing-cert",
+					Aliases: []string{"existing-cert-path"},
+					Usage:   "Zip file, PEM file, PEM directory or S3 object name of the existing certificate. Defaults to --zip-name.",
+					Sources: cli.EnvVars("LE_EXISTING_CERT"),
+				},
+				&cli.DurationFlag{
+					Name:    "renew-before",
+					Value:   30 * 24 * time.Hour,
+					Usage:   "Only renew when the existing certificate expires within this duration.",
+					Sources: cli.EnvVars("LE_RENEW_BEFORE"),
+				},
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "Renew even if the existing certificate is not due.",
+				},
+				&cli.BoolFlag{
+					Name:    "ari",
+					Usage:   "Use the CA's ACME Renewal Information window instead of --renew-before, and mark the new order as replacing the existing certificate.",
+					Sources: cli.EnvVars("LE_USE_ARI"),
+				},
+				&cli.StringFlag{
+					Name:    "key-type",
+					Value:   "RSA2048",
+					Usage:   "Certificate key algorithm: EC256, EC384, RSA2048, RSA3072, RSA4096, RSA8192",
+					Sources: cli.EnvVars("LE_KEY_TYPE"),
+				},
+				&cli.BoolFlag{
+					Name:    "reuse-key",
+					Usage:   "Reuse the private key of the existing certificate instead of generating a new one.",
+					Sources: cli.EnvVars("LE_REUSE_KEY"),
+				},
+				&cli.StringFlag{
+					Name:    "csr",
+					Aliases: []string{"csr-file"},
+					Usage:   "PEM or DER CSR to obtain the certificate for. Domains are taken from the CSR and no private key is generated.",
+					Sources: cli.EnvVars("LE_CSR_FILE"),
+				},
 			},
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
 				if cmd.NArg() == 0 {
 					fmt.Println(cmd.String("acme-url"))
+					keyType, err := cf_acme.ParseKeyType(cmd.String("key-type"))
+					if err != nil {
+						logger.Error(err.Error())
+						return err
+					}
+					zoneTokens, err := cf_acme.ParseZoneTokens(cmd.StringSlice("challenge-zone-token"))
+					if err != nil {
+						logger.Error(err.Error())
+						return err
+					}
 					certRequest := &cf_acme.CertificateRenewalRequest{
 						EnvFile:              cmd.String("env-file"),
 						DomainNames:          cmd.StringSlice("renew-domains"),
@@ -86,8 +210,28 @@ func DnsBaseCommand() []*cli.Command {
 						PushS3:               cmd.Bool("acme-pushs3"),
 						Token:                cmd.String("cf-dns-token"),
 						RecursiveNameServers: cmd.StringSlice("recursive-nameservers"),
-					}
-					_, err := certRequest.CliRenewal()
+						AccountDir:           cmd.String("account-dir"),
+						ExistingCertSource:   cmd.String("existing-cert-source"),
+						ExistingCertPath:     cmd.String("existing-cert"),
+						RenewBefore:          cmd.Duration("renew-before"),
+						Force:                cmd.Bool("force"),
+						UseARI:               cmd.Bool("ari"),
+						KeyType:              keyType,
+						ReuseKey:             cmd.Bool("reuse-key"),
+						CsrFile:              cmd.String("csr"),
+						EabKid:               cmd.String("eab-kid"),
+						EabHmac:              cmd.String("eab-hmac"),
+						ChallengeZoneTokens:  zoneTokens,
+						Challenge:            cmd.String("challenge"),
+						DnsProvider:          cmd.String("dns-provider"),
+						HttpMode:             cmd.String("http-mode"),
+						HttpAddress:          cmd.String("http-address"),
+						HttpWebroot:          cmd.String("webroot"),
+						HttpS3Bucket:         cmd.String("http-s3-bucket"),
+						HttpS3Prefix:         cmd.String("http-s3-prefix"),
+						TlsAlpnAddress:       cmd.String("tls-alpn-address"),
+					}
+					_, err = certRequest.CliRenewal()
 					if err != nil {
 						logger.Errorf("error renewing certificate err: %s renew-domain: %s", err.Error(), cmd.String("renew-domain"))
 						return err
@@ -99,6 +243,7 @@ func DnsBaseCommand() []*cli.Command {
 				return err
 			},
 		},
+		AcmeBaseCommand(),
 		{
 			Name:                  "utils",
 			EnableShellCompletion: true,
@@ -166,12 +311,12 @@ func CoreInfraCommand() *cli.Command {
 
 func cfDnsSubcommandFlags() []cli.Flag {
 	flags := []cli.Flag{
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			Name:    "domain-name",
 			Aliases: []string{"n"},
-			Value:   "trahan.dev",
+			Value:   []string{"trahan.dev"},
 			Sources: cli.EnvVars("CF_DOMAIN_NAME"),
-			Usage:   "Cloudflare Zone Id to retrieve records for.",
+			Usage:   "Zone to work on, list accepts it more than once.",
 		},
 		&cli.StringFlag{
 			Name:    "new-content",
@@ -229,10 +374,138 @@ func cfDnsSubcommandFlags() []cli.Flag {
 			Value: os.Getenv("CF_TOKEN"),
 			Usage: "Cloudflare token for performing dns functions",
 		},
+		&cli.StringFlag{
+			Name:    "dns-provider",
+			Value:   cf_acme.DnsProviderCloudflare,
+			Usage:   "DNS backend: cloudflare, rfc2136 or powerdns. Settings are read from --env-file, DNS_ZONE_PROVIDERS=zone=provider,... picks a backend per zone.",
+			Sources: cli.EnvVars("DNS_PROVIDER"),
+		},
+		&cli.BoolFlag{
+			Name:    "allow-unaudited",
+			Usage:   "Make record changes even when they cannot be written to the audit log in SQLITE_DB_PATH.",
+			Sources: cli.EnvVars("DNS_ALLOW_UNAUDITED"),
+		},
 	}
 	return flags
 }
 
+// domainNameFromCli returns the first --domain-name, which is the zone for every command but list.
+func domainNameFromCli(cmd *cli.Command) string {
+	names := cmd.StringSlice("domain-name")
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// dnsCommandFromCli builds the command utils for --dns-provider, using --env-file or --dns-token for Cloudflare.
+func dnsCommandFromCli(cmd *cli.Command) *CloudflareCommandUtils {
+	return dnsCommandForDomain(cmd, domainNameFromCli(cmd))
+}
+
+// dnsCommandForDomain is dnsCommandFromCli for a zone other than --domain-name.
+func dnsCommandForDomain(cmd *cli.Command, domainName string) *CloudflareCommandUtils {
+	var cfcmd *CloudflareCommandUtils
+	switch {
+	case useDnsProviderCommand(cmd):
+		cfcmd = NewDnsProviderCommand(cmd.String("dns-provider"), cmd.String("env-file"), cmd.String("dns-token"), domainName)
+	case cmd.Bool("use-env"):
+		cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), domainName)
+	default:
+		cfcmd = NewCloudflareCommand(cmd.String("dns-token"), domainName)
+	}
+	cfcmd.AllowUnaudited = cmd.Bool("allow-unaudited")
+	return cfcmd
+}
+
+// dnsCommandFromEnv is dnsCommandFromCli for the positional argument forms, which always read credentials from --env-file.
+func dnsCommandFromEnv(cmd *cli.Command) *CloudflareCommandUtils {
+	var cfcmd *CloudflareCommandUtils
+	if useDnsProviderCommand(cmd) {
+		cfcmd = NewDnsProviderCommand(cmd.String("dns-provider"), cmd.String("env-file"), "", domainNameFromCli(cmd))
+	} else {
+		cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), domainNameFromCli(cmd))
+	}
+	cfcmd.AllowUnaudited = cmd.Bool("allow-unaudited")
+	return cfcmd
+}
+
+// useDnsProviderCommand reports whether --dns-provider or DNS_ZONE_PROVIDERS in --env-file
+// selects something other than plain Cloudflare.
+func useDnsProviderCommand(cmd *cli.Command) bool {
+	providerName := cmd.String("dns-provider")
+	if providerName != "" && providerName != cf_acme.DnsProviderCloudflare {
+		return true
+	}
+	godotenv.Load(cmd.String("env-file"))
+	return cf_acme.ZoneRoutesConfigured()
+}
+
+// recordSelectorFlags narrow --record-name to the records get, update and delete act on.
+func recordSelectorFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "match-content",
+			Aliases: []string{"content"},
+			Usage:   "Only select records named --record-name with this content.",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Act on every record matching --record-name instead of refusing when more than one matches.",
+		},
+	}
+}
+
+// selectDnsRecords looks up the records matching --record-name, --type and --content, printing
+// the matches when the selection is ambiguous.
+func selectDnsRecords(cmd *cli.Command, cfcmd *CloudflareCommandUtils) []cloudflare.DNSRecord {
+	records := cfcmd.FindDnsRecords(cmd.String("record-name"), strings.ToUpper(cmd.String("type")), cmd.String("match-content"), cmd.Bool("all"))
+	if cfcmd.Error != nil {
+		if len(records) > 1 {
+			cfcmd.PrintDnsRecordsTable(records)
+		}
+		logger.Error(cfcmd.Error.Error())
+	}
+	return records
+}
+
+// updateParamsFromCli builds the update for id from the flags that were set.
+func updateParamsFromCli(cmd *cli.Command, id string) cloudflare.UpdateDNSRecordParams {
+	params := cloudflare.UpdateDNSRecordParams{ID: id}
+	if cmd.IsSet("new-content") {
+		params.Content = cmd.String("new-content")
+	}
+	if cmd.IsSet("record-name") {
+		params.Name = cmd.String("record-name")
+	}
+	if cmd.IsSet("new-name") {
+		params.Name = cmd.String("new-name")
+	}
+	if cmd.IsSet("type") {
+		params.Type = cmd.String("type")
+	}
+	if cmd.IsSet("priority") {
+		priority64 := cmd.Uint("priority")
+		pr16 := uint16(priority64)
+		params.Priority = &pr16
+	}
+	if cmd.IsSet("ttl") {
+		params.TTL = int(cmd.Int("ttl"))
+	}
+	if cmd.IsSet("proxied") {
+		proxied := cmd.Bool("proxied")
+		params.Proxied = &proxied
+	}
+	if cmd.IsSet("comment") {
+		comment := cmd.String("comment")
+		params.Comment = &comment
+	}
+	if cmd.IsSet("tags") {
+		params.Tags = cmd.StringSlice("tags")
+	}
+	return params
+}
+
 func cfDnsComandAuthors() []any {
 	authors := []any{
 		&UrFaveCliDocumentationSucks{
@@ -268,13 +541,7 @@ func GetDnsSubCommands() []*cli.Command {
 			EnableShellCompletion: true,
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
 				if cmd.NArg() == 0 {
-					var cfcmd *CloudflareCommandUtils
-					fromEnv := cmd.Bool("use-env")
-					if fromEnv {
-						cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
-					} else {
-						cfcmd = NewCloudflareCommand(cmd.String("dns-token"), cmd.String("domain-name"))
-					}
+					cfcmd := dnsCommandFromCli(cmd)
 
 					if cfcmd.Error != nil {
 						logger.Error(cfcmd.Error.Error())
@@ -344,16 +611,37 @@ func GetDnsSubCommands() []*cli.Command {
 					Usage:   "Return list of all records that match query params",
 					Value:   false,
 				},
+				&cli.IntFlag{
+					Name:  "page",
+					Usage: "Only return this page of records. Without it every page is fetched.",
+				},
+				&cli.IntFlag{
+					Name:  "per-page",
+					Value: 100,
+					Usage: "Records per page requested from the dns provider.",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "Stop after this many records.",
+				},
+				&cli.BoolFlag{
+					Name:  "stream",
+					Usage: "Print records as pages arrive instead of after the whole zone is fetched. JSON output becomes one record per line.",
+				},
+				&cli.BoolFlag{
+					Name:  "all-zones",
+					Usage: "List the records of every zone the credentials can reach instead of --domain-name.",
+				},
 			},
 			Category:              "dns",
 			EnableShellCompletion: true,
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+				allZones := cmd.Bool("all-zones") || len(cmd.StringSlice("domain-name")) > 1
 				var cfcmd *CloudflareCommandUtils
-				fromEnv := cmd.Bool("use-env")
-				if fromEnv {
-					cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
+				if allZones {
+					cfcmd = dnsCommandForDomain(cmd, "")
 				} else {
-					cfcmd = NewCloudflareCommand(cmd.String("dns-token"), cmd.String("domain-name"))
+					cfcmd = dnsCommandFromCli(cmd)
 				}
 
 				params := &cloudflare.ListDNSRecordsParams{}
@@ -386,26 +674,73 @@ func GetDnsSubCommands() []*cli.Command {
 					if cmd.IsSet("qry-record-tags") {
 						params.Tags = cmd.StringSlice("tags")
 					}
-					records, _ := cfcmd.ListDNSRecords(*params)
+				}
+				params.Page = int(cmd.Int("page"))
+				params.PerPage = int(cmd.Int("per-page"))
+				limit := int(cmd.Int("limit"))
+
+				if allZones {
+					zones, err := cfcmd.ListZoneRecords(ctx, cmd, *params, limit)
+					if err != nil {
+						logger.Error(err.Error())
+						return err
+					}
+					failed := 0
+					for _, v := range zones {
+						if v.Error != "" {
+							logger.Error(fmt.Sprintf("error listing records in %s: %s", v.ZoneName, v.Error))
+							failed++
+						}
+					}
 					if cmd.Bool("to-db") {
 						cfcmd.InitializeDatabaseConnection()
 						defer cfcmd.DbConn.Close()
-						cfcmd.CreateDnsDbRecords(records)
+						for _, v := range zones {
+							cfcmd.forZone(v.Zone()).CreateDnsDbRecords(v.Records)
+						}
+					}
+					if cmd.Bool("print-json") {
+						cfcmd.PrintCommandResultAsJson(zones)
+					} else {
+						PrintZoneRecordsTable(zones)
+					}
+					if failed > 0 {
+						return fmt.Errorf("listing failed in %d of %d zones", failed, len(zones))
 					}
-					cfcmd.PrintDnsRecordsTable(records)
 					return cfcmd.Error
 				}
-				records, _ := cfcmd.ListDNSRecords(*params)
+
+				var onPage func([]cloudflare.DNSRecord)
 				if cmd.Bool("to-db") {
 					cfcmd.InitializeDatabaseConnection()
 					defer cfcmd.DbConn.Close()
-					cfcmd.CreateDnsDbRecords(records)
+					onPage = cfcmd.CreateDnsDbRecords
+				}
+				if cmd.Bool("stream") {
+					if cmd.Bool("print-json") {
+						cfcmd.StreamDnsRecordsJson(*params, limit, onPage)
+					} else {
+						cfcmd.StreamDnsRecordsTable(*params, limit, onPage)
+					}
+					return cfcmd.Error
+				}
+
+				records, info := cfcmd.ListDNSRecordsLimit(*params, limit)
+				if cfcmd.Error != nil {
+					logger.Error(cfcmd.Error.Error())
+					return cfcmd.Error
+				}
+				if onPage != nil {
+					onPage(records)
 				}
 				if cmd.Bool("print-json") {
 					cfcmd.PrintCommandResultAsJson(records)
 					return cfcmd.Error
 				}
 				cfcmd.PrintDnsRecordsTable(records)
+				if params.Page > 0 {
+					pretty.Printf("Page %d of %d, %d records in total.", info.Page, info.TotalPages, info.Total)
+				}
 				return cfcmd.Error
 			},
 		},
@@ -414,28 +749,34 @@ func GetDnsSubCommands() []*cli.Command {
 			Version: versionNumber,
 			Authors: cfDnsComandAuthors(),
 			Aliases: []string{"get-record", "cat"},
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:    "get-record-id",
 					Aliases: []string{"qry-record-id"},
-					Usage:   "The ID for Record you want to get details for.",
+					Usage:   "The ID for Record you want to get details for. Without it the record is selected with --record-name.",
 				},
-			},
+			}, recordSelectorFlags()...),
 			Category:              "dns",
 			EnableShellCompletion: true,
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
 				if cmd.NArg() == 0 {
-					var cfcmd *CloudflareCommandUtils
-					fromEnv := cmd.Bool("use-env")
-					if fromEnv {
-						cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
-					} else {
-						cfcmd = NewCloudflareCommand(cmd.String("dns-token"), cmd.String("domain-name"))
-					}
+					cfcmd := dnsCommandFromCli(cmd)
 					if cfcmd.Error != nil {
 						logger.Error(cfcmd.Error.Error())
 						return cfcmd.Error
 					}
+					if !cmd.IsSet("get-record-id") && cmd.IsSet("record-name") {
+						records := selectDnsRecords(cmd, cfcmd)
+						if cfcmd.Error != nil {
+							return cfcmd.Error
+						}
+						if cmd.Bool("print-json") {
+							cfcmd.PrintCommandResultAsJson(records)
+							return cfcmd.Error
+						}
+						cfcmd.PrintDnsRecordsTable(records)
+						return cfcmd.Error
+					}
 					record := cfcmd.GetDnsRecord(cmd.String("get-record-id"))
 					if cmd.Bool("print-json") {
 						cfcmd.PrintCommandResultAsJson(record)
@@ -444,7 +785,7 @@ func GetDnsSubCommands() []*cli.Command {
 					printDnsRecord(record)
 					return cfcmd.Error
 				}
-				cfcmd := NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
+				cfcmd := dnsCommandFromEnv(cmd)
 				if cfcmd.Error != nil {
 					logger.Error(cfcmd.Error.Error())
 					return cfcmd.Error
@@ -462,61 +803,58 @@ func GetDnsSubCommands() []*cli.Command {
 			Name:    "update",
 			Version: versionNumber,
 			Authors: cfDnsComandAuthors(),
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
-					Name:     "update-record-id",
-					Required: true,
-					Aliases:  []string{"record-id"},
-					Sources:  cli.EnvVars("CF_REC_UPDATE_ID"),
-					Usage:    "The ID for Record you want to update.",
+					Name:    "update-record-id",
+					Aliases: []string{"record-id"},
+					Sources: cli.EnvVars("CF_REC_UPDATE_ID"),
+					Usage:   "The ID for Record you want to update. Without it the record is selected with --record-name.",
 				},
-			},
+				&cli.StringFlag{
+					Name:  "new-name",
+					Usage: "New name for a record selected with --record-name.",
+				},
+			}, recordSelectorFlags()...),
 			Aliases:               []string{"set", "update-record"},
 			Category:              "dns",
 			EnableShellCompletion: true,
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
 				if cmd.NArg() == 0 {
-					params := &cloudflare.UpdateDNSRecordParams{ID: cmd.String("record-id")}
-					var cfcmd *CloudflareCommandUtils
-					fromEnv := cmd.Bool("use-env")
-					if fromEnv {
-						cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
-					} else {
-						cfcmd = NewCloudflareCommand(cmd.String("dns-token"), cmd.String("domain-name"))
-					}
+					cfcmd := dnsCommandFromCli(cmd)
 					if cfcmd.Error != nil {
 						logger.Error(cfcmd.Error.Error())
 						return cfcmd.Error
 					}
-					if cmd.IsSet("new-content") {
-						params.Content = cmd.String("new-content")
-					}
-					if cmd.IsSet("record-name") {
-						params.Name = cmd.String("record-name")
-					}
-					if cmd.IsSet("type") {
-						params.Type = cmd.String("type")
-					}
-					if cmd.IsSet("priority") {
-						priority64 := cmd.Uint("priority")
-						pr16 := uint16(priority64)
-						params.Priority = &pr16
-					}
-					if cmd.IsSet("ttl") {
-						params.TTL = int(cmd.Int("ttl"))
-					}
-					if cmd.IsSet("proxied") {
-						proxied := cmd.Bool("proxied")
-						params.Proxied = &proxied
-					}
-					if cmd.IsSet("comment") {
-						comment := cmd.String("comment")
-						params.Comment = &comment
+					if !cmd.IsSet("record-id") && cmd.IsSet("record-name") {
+						records := selectDnsRecords(cmd, cfcmd)
+						if cfcmd.Error != nil {
+							return cfcmd.Error
+						}
+						updated := []cloudflare.DNSRecord{}
+						for _, v := range records {
+							// --record-name and --type select the records here, only --new-name renames.
+							params := updateParamsFromCli(cmd, v.ID)
+							params.Name = cmd.String("new-name")
+							params.Type = ""
+							updated = append(updated, cfcmd.CreateOrUpdateDNSRecord(params))
+							if cfcmd.Error != nil {
+								return cfcmd.Error
+							}
+						}
+						if cmd.Bool("print-json") {
+							cfcmd.PrintCommandResultAsJson(updated)
+							return cfcmd.Error
+						}
+						cfcmd.PrintDnsRecordsTable(updated)
+						return cfcmd.Error
 					}
-					if cmd.IsSet("tags") {
-						params.Tags = cmd.StringSlice("tags")
+					if !cmd.IsSet("record-id") {
+						err = fmt.Errorf("set --record-id or select the record with --record-name")
+						logger.Error(err.Error())
+						return err
 					}
-					record := cfcmd.CreateOrUpdateDNSRecord(*params)
+					params := updateParamsFromCli(cmd, cmd.String("record-id"))
+					record := cfcmd.CreateOrUpdateDNSRecord(params)
 					if cmd.Bool("print-json") {
 						cfcmd.PrintCommandResultAsJson(record)
 						return cfcmd.Error
@@ -531,34 +869,38 @@ func GetDnsSubCommands() []*cli.Command {
 			Name:    "delete",
 			Version: versionNumber,
 			Authors: cfDnsComandAuthors(),
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:    "rm-record-id",
 					Aliases: []string{"remove-id", "remove-record-id", "delete-record-id"},
 					Sources: cli.EnvVars("CF_RECORD_DELETE_ID"),
-					Usage:   "The ID for Record you want to update.",
+					Usage:   "The ID for Record you want to delete. Without it the record is selected with --record-name.",
 				},
-			},
+			}, recordSelectorFlags()...),
 			Aliases:               []string{"rm", "remove-record"},
 			Category:              "dns",
 			EnableShellCompletion: true,
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
 				if cmd.NArg() == 0 {
-					var cfcmd *CloudflareCommandUtils
-					fromEnv := cmd.Bool("use-env")
-					if fromEnv {
-						cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
-					} else {
-						cfcmd = NewCloudflareCommand(cmd.String("dns-token"), cmd.String("domain-name"))
-					}
+					cfcmd := dnsCommandFromCli(cmd)
 					if cfcmd.Error != nil {
 						logger.Error(cfcmd.Error.Error())
 						return cfcmd.Error
 					}
+					if !cmd.IsSet("rm-record-id") && cmd.IsSet("record-name") {
+						records := selectDnsRecords(cmd, cfcmd)
+						for _, v := range records {
+							if cfcmd.Error != nil {
+								break
+							}
+							cfcmd.DeleteCloudflareRecord(v.ID)
+						}
+						return cfcmd.Error
+					}
 					cfcmd.DeleteCloudflareRecord(cmd.String("rm-record-id"))
 					return cfcmd.Error
 				}
-				cfcmd := NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
+				cfcmd := dnsCommandFromEnv(cmd)
 				if cfcmd.Error != nil {
 					logger.Error(cfcmd.Error.Error())
 					return cfcmd.Error
@@ -567,6 +909,16 @@ func GetDnsSubCommands() []*cli.Command {
 				return cfcmd.Error
 			},
 		},
+		zonePlanCommand("plan", false),
+		zonePlanCommand("apply", true),
+		zoneExportCommand(),
+		zoneImportCommand(),
+		ddnsCommand(),
+		bulkCommand(),
+		zonesCommand(),
+		verifyCommand(),
+		driftCommand(),
+		historyCommand(),
 		{
 			Name:                  "create",
 			Version:               versionNumber,
@@ -577,13 +929,7 @@ func GetDnsSubCommands() []*cli.Command {
 			Action: func(ctx context.Context, cmd *cli.Command) (err error) {
 				if cmd.NArg() == 0 {
 					params := &cloudflare.CreateDNSRecordParams{}
-					var cfcmd *CloudflareCommandUtils
-					fromEnv := cmd.Bool("use-env")
-					if fromEnv {
-						cfcmd = NewCloudflareCommandFromEnv(cmd.String("env-file"), cmd.String("domain-name"))
-					} else {
-						cfcmd = NewCloudflareCommand(cmd.String("dns-token"), cmd.String("domain-name"))
-					}
+					cfcmd := dnsCommandFromCli(cmd)
 					if cfcmd.Error != nil {
 						logger.Error(cfcmd.Error.Error())
 						return cfcmd.Error
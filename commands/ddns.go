@@ -0,0 +1,361 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/internal/pretty"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	DefaultIPv4EchoUrls = []string{"https://api.ipify.org", "https://ipv4.icanhazip.com", "https://ifconfig.me/ip"}
+	DefaultIPv6EchoUrls = []string{"https://api6.ipify.org", "https://ipv6.icanhazip.com"}
+)
+
+// DdnsUpdater keeps the A and AAAA records for Names pointed at the host's public address.
+type DdnsUpdater struct {
+	Cmd        *CloudflareCommandUtils
+	Names      []string
+	IPv4       bool
+	IPv6       bool
+	EchoUrls4  []string
+	EchoUrls6  []string
+	Interface  string
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	// ResyncEvery lists the records again every ResyncEvery syncs even when the address did
+	// not change, so edits made outside the updater are corrected. 0 disables it.
+	ResyncEvery int
+	Logger      *pretty.CustomLogger
+	// synced holds the address last written for each record type, records are only listed
+	// again once the detected address moves away from it or on a resync.
+	synced map[string]string
+	syncs  int
+	// clients holds one echo client per address family, reused by every sync.
+	clients map[string]*http.Client
+}
+
+func NewDdnsUpdater(cfcmd *CloudflareCommandUtils, names []string) *DdnsUpdater {
+	return &DdnsUpdater{
+		Cmd:         cfcmd,
+		Names:       names,
+		IPv4:        true,
+		EchoUrls4:   DefaultIPv4EchoUrls,
+		EchoUrls6:   DefaultIPv6EchoUrls,
+		Interval:    5 * time.Minute,
+		MaxBackoff:  30 * time.Minute,
+		ResyncEvery: 12,
+		Logger:      logger,
+		synced:      make(map[string]string),
+		clients:     map[string]*http.Client{"tcp4": newEchoClient("tcp4"), "tcp6": newEchoClient("tcp6")},
+	}
+}
+
+// newEchoClient returns a client pinned to network, tcp4 or tcp6, so a dual stack host reports
+// the address of the family asked for.
+func newEchoClient(network string) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	return &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _ string, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			IdleConnTimeout: 90 * time.Second,
+		},
+	}
+}
+
+// DetectIP returns the public address for recordType, A or AAAA, read from Interface when set
+// and from the first echo endpoint that answers otherwise.
+func (d *DdnsUpdater) DetectIP(ctx context.Context, recordType string) (string, error) {
+	if d.Interface != "" {
+		return interfaceIP(d.Interface, recordType == "AAAA")
+	}
+	urls, network := d.EchoUrls4, "tcp4"
+	if recordType == "AAAA" {
+		urls, network = d.EchoUrls6, "tcp6"
+	}
+
+	var lastErr error
+	for _, url := range urls {
+		ip, err := echoIP(ctx, d.clients[network], url, recordType == "AAAA")
+		if err == nil {
+			return ip, nil
+		}
+		d.Logger.Warning(fmt.Sprintf("ip echo endpoint %s failed: %s", url, err.Error()))
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no ip echo endpoints configured for %s records", recordType)
+	}
+	return "", lastErr
+}
+
+func echoIP(ctx context.Context, client *http.Client, url string, ipv6 bool) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 128))
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || (ip.To4() == nil) != ipv6 {
+		return "", fmt.Errorf("response %q is not an ip address of the requested family", strings.TrimSpace(string(body)))
+	}
+	return ip.String(), nil
+}
+
+// interfaceIP returns the first global address of the family on the interface, preferring
+// public over private ranges.
+func interfaceIP(name string, ipv6 bool) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	private := ""
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() || (ipNet.IP.To4() == nil) != ipv6 {
+			continue
+		}
+		if !ipNet.IP.IsPrivate() {
+			return ipNet.IP.String(), nil
+		}
+		if private == "" {
+			private = ipNet.IP.String()
+		}
+	}
+	if private == "" {
+		family := "ipv4"
+		if ipv6 {
+			family = "ipv6"
+		}
+		return "", fmt.Errorf("interface %s has no global %s address", name, family)
+	}
+	return private, nil
+}
+
+// Sync detects the current addresses and updates every matching record whose content differs.
+func (d *DdnsUpdater) Sync(ctx context.Context) error {
+	d.syncs++
+	if d.ResyncEvery > 0 && d.syncs%d.ResyncEvery == 0 {
+		clear(d.synced)
+	}
+	recordTypes := []string{}
+	if d.IPv4 {
+		recordTypes = append(recordTypes, "A")
+	}
+	if d.IPv6 {
+		recordTypes = append(recordTypes, "AAAA")
+	}
+
+	for _, recordType := range recordTypes {
+		ip, err := d.DetectIP(ctx, recordType)
+		if err != nil {
+			return fmt.Errorf("error detecting %s address: %w", recordType, err)
+		}
+		if d.synced[recordType] == ip {
+			d.Logger.Debug(fmt.Sprintf("%s address %s unchanged", recordType, ip))
+			continue
+		}
+		for _, name := range d.Names {
+			err = d.updateRecords(dnsprovider.QualifyName(name, d.Cmd.ZoneName), recordType, ip)
+			if err != nil {
+				return err
+			}
+		}
+		d.synced[recordType] = ip
+	}
+	return nil
+}
+
+func (d *DdnsUpdater) updateRecords(name string, recordType string, ip string) error {
+	records, _ := d.Cmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{Name: name, Type: recordType})
+	if d.Cmd.Error != nil {
+		return fmt.Errorf("error listing %s records for %s: %w", recordType, name, d.Cmd.Error)
+	}
+	if len(records) == 0 {
+		d.Logger.Warning(fmt.Sprintf("no %s record for %s in zone %s, nothing to update", recordType, name, d.Cmd.ZoneName))
+		return nil
+	}
+	for _, v := range records {
+		if v.Content == ip {
+			d.Logger.Info(fmt.Sprintf("%s %s already points to %s", name, recordType, ip))
+			continue
+		}
+		d.Cmd.CreateOrUpdateDNSRecord(cloudflare.UpdateDNSRecordParams{ID: v.ID, Name: v.Name, Type: v.Type, Content: ip, TTL: v.TTL, Proxied: v.Proxied})
+		if d.Cmd.Error != nil {
+			return fmt.Errorf("error updating %s %s: %w", name, recordType, d.Cmd.Error)
+		}
+		d.Logger.Info(fmt.Sprintf("updated %s %s from %s to %s", name, recordType, v.Content, ip))
+	}
+	return nil
+}
+
+// Run syncs every Interval until ctx is done. Failed syncs are retried after a backoff that
+// doubles from 10 seconds up to MaxBackoff.
+func (d *DdnsUpdater) Run(ctx context.Context) error {
+	if d.Interval <= 0 {
+		return fmt.Errorf("ddns interval must be positive, got %s", d.Interval)
+	}
+	backoff := time.Duration(0)
+	for {
+		wait := d.Interval
+		err := d.Sync(ctx)
+		switch {
+		case ctx.Err() != nil:
+			d.Logger.Info("ddns updater stopped")
+			return nil
+		case err != nil:
+			backoff = min(max(backoff*2, 10*time.Second), d.MaxBackoff)
+			wait = backoff
+			d.Logger.Error(fmt.Sprintf("%s, retrying in %s", err.Error(), wait))
+		default:
+			backoff = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			d.Logger.Info("ddns updater stopped")
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func ddnsCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "ddns",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Keep A/AAAA records pointed at this host's public address.",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "ddns-name",
+				Aliases: []string{"names"},
+				Usage:   "Record names to update, relative names are in --domain-name. Defaults to --record-name.",
+				Sources: cli.EnvVars("DDNS_NAMES"),
+			},
+			&cli.BoolFlag{
+				Name:  "ipv4",
+				Value: true,
+				Usage: "Update A records.",
+			},
+			&cli.BoolFlag{
+				Name:  "ipv6",
+				Usage: "Update AAAA records.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "echo-url",
+				Value:   DefaultIPv4EchoUrls,
+				Usage:   "HTTP endpoints returning the caller's IPv4 address, tried in order.",
+				Sources: cli.EnvVars("DDNS_ECHO_URLS"),
+			},
+			&cli.StringSliceFlag{
+				Name:    "echo-url6",
+				Value:   DefaultIPv6EchoUrls,
+				Usage:   "HTTP endpoints returning the caller's IPv6 address, tried in order.",
+				Sources: cli.EnvVars("DDNS_ECHO_URLS6"),
+			},
+			&cli.StringFlag{
+				Name:    "interface",
+				Aliases: []string{"i"},
+				Usage:   "Read the address from this local interface instead of the echo endpoints.",
+				Sources: cli.EnvVars("DDNS_INTERFACE"),
+			},
+			&cli.DurationFlag{
+				Name:    "interval",
+				Value:   5 * time.Minute,
+				Usage:   "Time between checks.",
+				Sources: cli.EnvVars("DDNS_INTERVAL"),
+			},
+			&cli.IntFlag{
+				Name:  "resync-every",
+				Value: 12,
+				Usage: "List the records again every N checks even when the address did not change, correcting edits made elsewhere. 0 disables it.",
+			},
+			&cli.DurationFlag{
+				Name:  "max-backoff",
+				Value: 30 * time.Minute,
+				Usage: "Longest wait between retries after errors.",
+			},
+			&cli.BoolFlag{
+				Name:  "once",
+				Usage: "Sync once and exit instead of running as a daemon.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			if cmd.Duration("interval") <= 0 || cmd.Int("resync-every") < 0 {
+				err = fmt.Errorf("--interval must be positive and --resync-every not negative")
+				logger.Error(err.Error())
+				return err
+			}
+			names := cmd.StringSlice("ddns-name")
+			if len(names) == 0 && cmd.String("record-name") != "" {
+				names = []string{cmd.String("record-name")}
+			}
+			if len(names) == 0 {
+				err = fmt.Errorf("no records to update, set --ddns-name or --record-name")
+				logger.Error(err.Error())
+				return err
+			}
+
+			cfcmd := dnsCommandFromCli(cmd)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			updater := NewDdnsUpdater(cfcmd, names)
+			updater.IPv4 = cmd.Bool("ipv4")
+			updater.IPv6 = cmd.Bool("ipv6")
+			updater.EchoUrls4 = cmd.StringSlice("echo-url")
+			updater.EchoUrls6 = cmd.StringSlice("echo-url6")
+			updater.Interface = cmd.String("interface")
+			updater.Interval = cmd.Duration("interval")
+			updater.MaxBackoff = cmd.Duration("max-backoff")
+			updater.ResyncEvery = int(cmd.Int("resync-every"))
+
+			if cmd.Bool("once") {
+				err = updater.Sync(ctx)
+				if err != nil {
+					logger.Error(err.Error())
+				}
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			logger.Info(fmt.Sprintf("ddns updater started for %s in %s, checking every %s", strings.Join(names, ", "), cfcmd.ZoneName, updater.Interval))
+			return updater.Run(ctx)
+		},
+	}
+	return cmd
+}
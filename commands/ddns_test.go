@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+)
+
+// newTestDdnsUpdater returns an updater for home.example.com whose echo endpoint answers ip.
+func newTestDdnsUpdater(t *testing.T, p *testProvider, ip *string) *DdnsUpdater {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(*ip + "\n"))
+	}))
+	t.Cleanup(srv.Close)
+	d := NewDdnsUpdater(newTestCommand(p), []string{"home"})
+	d.EchoUrls4 = []string{srv.URL}
+	return d
+}
+
+func TestDdnsSyncCorrectsOutOfBandEdits(t *testing.T) {
+	p := newTestProvider("example.com", dnsprovider.Record{Name: "home", Type: "A", Content: "192.0.2.1", TTL: 300})
+	ip := "127.0.0.1"
+	d := newTestDdnsUpdater(t, p, &ip)
+	d.ResyncEvery = 3
+	ctx := context.Background()
+
+	if err := d.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if p.records[0].Content != ip {
+		t.Fatalf("expected the record updated to %s, got %s", ip, p.records[0].Content)
+	}
+
+	p.records[0].Content = "192.0.2.9"
+	d.Sync(ctx)
+	if p.records[0].Content != "192.0.2.9" {
+		t.Fatal("expected an unchanged address not to list the records before the resync")
+	}
+	d.Sync(ctx)
+	if p.records[0].Content != ip {
+		t.Fatalf("expected the resync to correct the record, got %s", p.records[0].Content)
+	}
+
+	d.ResyncEvery = 0
+	p.records[0].Content = "192.0.2.9"
+	for range 5 {
+		d.Sync(ctx)
+	}
+	if p.records[0].Content != "192.0.2.9" {
+		t.Fatal("expected no resync when it is disabled")
+	}
+}
+
+func TestDdnsRunRejectsNonPositiveInterval(t *testing.T) {
+	ip := "127.0.0.1"
+	d := newTestDdnsUpdater(t, newTestProvider("example.com"), &ip)
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		d.Interval = interval
+		err := d.Run(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "must be positive") {
+			t.Fatalf("expected interval %s to be rejected, got %v", d.Interval, err)
+		}
+	}
+}
+
+func TestDetectIPReusesConnections(t *testing.T) {
+	conns := &atomic.Int32{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("127.0.0.1\n"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	d := NewDdnsUpdater(newTestCommand(newTestProvider("example.com")), []string{"home"})
+	d.EchoUrls4 = []string{srv.URL}
+
+	for range 3 {
+		if ip, err := d.DetectIP(context.Background(), "A"); err != nil || ip != "127.0.0.1" {
+			t.Fatalf("DetectIP returned %q, %v", ip, err)
+		}
+	}
+	if conns.Load() != 1 {
+		t.Fatalf("expected the syncs to share one connection, got %d", conns.Load())
+	}
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	DriftAdded    = "added"
+	DriftRemoved  = "removed"
+	DriftModified = "modified"
+)
+
+// RecordDrift is a difference between the sqlite inventory and the live zone. Added records are
+// live only, removed records are only in the inventory.
+type RecordDrift struct {
+	Change  string                `json:"change"`
+	Name    string                `json:"name"`
+	Type    string                `json:"type"`
+	Stored  *cloudflare.DNSRecord `json:"stored,omitempty"`
+	Live    *cloudflare.DNSRecord `json:"live,omitempty"`
+	Changes []string              `json:"changes,omitempty"`
+}
+
+// storedRecordDiff lists the inventoried fields that differ between stored and live.
+func storedRecordDiff(stored cloudflare.DNSRecord, live cloudflare.DNSRecord) []string {
+	diff := []string{}
+	if stored.Type != live.Type {
+		diff = append(diff, fmt.Sprintf("type %s -> %s", stored.Type, live.Type))
+	}
+	if normalizeContent(stored.Type, stored.Content) != normalizeContent(live.Type, live.Content) {
+		diff = append(diff, fmt.Sprintf("content %s -> %s", stored.Content, live.Content))
+	}
+	if stored.TTL != live.TTL {
+		diff = append(diff, fmt.Sprintf("ttl %d -> %d", stored.TTL, live.TTL))
+	}
+	if stored.Comment != live.Comment {
+		diff = append(diff, fmt.Sprintf("comment %q -> %q", stored.Comment, live.Comment))
+	}
+	return diff
+}
+
+// DetectDrift matches stored and live records by ID. Records left over on both sides with the same
+// name and type, one each, are reported as modified since some providers change the ID along with
+// the content, with the ID change listed first. Live records of types the inventory cannot hold are ignored.
+func DetectDrift(stored []cloudflare.DNSRecord, live []cloudflare.DNSRecord) []RecordDrift {
+	drift := []RecordDrift{}
+	liveByID := make(map[string]int, len(live))
+	for i, v := range live {
+		liveByID[v.ID] = i
+	}
+	matched := make(map[int]bool, len(live))
+	removed := make(map[string][]int)
+	for i, v := range stored {
+		j, ok := liveByID[v.ID]
+		if !ok {
+			key := rrsetKey(v.Name, v.Type)
+			removed[key] = append(removed[key], i)
+			continue
+		}
+		matched[j] = true
+		if diff := storedRecordDiff(v, live[j]); len(diff) > 0 {
+			drift = append(drift, RecordDrift{Change: DriftModified, Name: live[j].Name, Type: live[j].Type, Stored: &stored[i], Live: &live[j], Changes: diff})
+		}
+	}
+
+	added := make(map[string][]int)
+	for j, v := range live {
+		if _, ok := recordTypeMap[v.Type]; !ok || matched[j] {
+			continue
+		}
+		key := rrsetKey(v.Name, v.Type)
+		added[key] = append(added[key], j)
+	}
+	for key, js := range added {
+		is := removed[key]
+		if len(js) == 1 && len(is) == 1 {
+			i, j := is[0], js[0]
+			diff := append([]string{fmt.Sprintf("id %s -> %s", stored[i].ID, live[j].ID)}, storedRecordDiff(stored[i], live[j])...)
+			drift = append(drift, RecordDrift{Change: DriftModified, Name: live[j].Name, Type: live[j].Type, Stored: &stored[i], Live: &live[j], Changes: diff})
+			delete(removed, key)
+			continue
+		}
+		for _, j := range js {
+			drift = append(drift, RecordDrift{Change: DriftAdded, Name: live[j].Name, Type: live[j].Type, Live: &live[j]})
+		}
+	}
+	for _, is := range removed {
+		for _, i := range is {
+			drift = append(drift, RecordDrift{Change: DriftRemoved, Name: stored[i].Name, Type: stored[i].Type, Stored: &stored[i]})
+		}
+	}
+
+	sort.SliceStable(drift, func(a, b int) bool {
+		if drift[a].Name != drift[b].Name {
+			return drift[a].Name < drift[b].Name
+		}
+		if drift[a].Type != drift[b].Type {
+			return drift[a].Type < drift[b].Type
+		}
+		return drift[a].Change < drift[b].Change
+	})
+	return drift
+}
+
+func PrintRecordDrift(drift []RecordDrift) error {
+	var colorInt int32 = 97
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dmChange\tName\tType\tID\tDetails\x1b[0m\n", colorInt)
+	fmt.Fprintf(tw, "\x1b[1;%dm------\t----\t----\t--\t-------\x1b[0m\n", colorInt)
+	for _, v := range drift {
+		id, details := "", ""
+		switch v.Change {
+		case DriftAdded:
+			colorInt = 92
+			id, details = v.Live.ID, fmt.Sprintf("content %s ttl %d", v.Live.Content, v.Live.TTL)
+		case DriftRemoved:
+			colorInt = 91
+			id, details = v.Stored.ID, fmt.Sprintf("content %s ttl %d", v.Stored.Content, v.Stored.TTL)
+		default:
+			colorInt = 93
+			id, details = v.Live.ID, strings.Join(v.Changes, ", ")
+		}
+		fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\x1b[0m\n", colorInt, v.Change, v.Name, v.Type, id, details)
+	}
+	return tw.Flush()
+}
+
+func driftCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "drift",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Compare the sqlite inventory of the zone with its live records, exiting non-zero on drift.",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "update",
+				Usage: "Replace the inventory with the live records after reporting the drift, accepting it.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			cfcmd := dnsCommandFromCli(cmd)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			live, _ := cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{})
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+
+			cfcmd.InitializeDatabaseConnection()
+			defer cfcmd.DbConn.Close()
+			stored := cfcmd.DnsRecordsFromDb()
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			if len(stored) == 0 {
+				err = fmt.Errorf("no records for %s in the inventory, snapshot the zone with list --to-db first", cfcmd.ZoneName)
+				logger.Error(err.Error())
+				return err
+			}
+
+			drift := DetectDrift(stored, live)
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(drift)
+			} else if len(drift) > 0 {
+				PrintRecordDrift(drift)
+			}
+			if cmd.Bool("update") && len(drift) > 0 {
+				cfcmd.ReplaceDnsDbRecords(live)
+				if cfcmd.Error != nil {
+					logger.Error(fmt.Sprintf("error updating the inventory: %s", cfcmd.Error.Error()))
+					return cfcmd.Error
+				}
+				logger.Info(fmt.Sprintf("inventory of %s updated, %d drifted records accepted", cfcmd.ZoneName, len(drift)))
+				return nil
+			}
+			if len(drift) > 0 {
+				return fmt.Errorf("%d records in %s drifted from the inventory", len(drift), cfcmd.ZoneName)
+			}
+			logger.Info(fmt.Sprintf("%d records in %s match the inventory", len(stored), cfcmd.ZoneName))
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// driftSummary lists drift as "change name type", sorted like DetectDrift returns it.
+func driftSummary(drift []RecordDrift) []string {
+	summary := []string{}
+	for _, v := range drift {
+		summary = append(summary, v.Change+" "+v.Name+" "+v.Type)
+	}
+	return summary
+}
+
+func TestDetectDrift(t *testing.T) {
+	www := cloudflare.DNSRecord{ID: "rec1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 300}
+	tests := []struct {
+		name    string
+		stored  []cloudflare.DNSRecord
+		live    []cloudflare.DNSRecord
+		want    []string
+		changes []string
+	}{
+		{
+			name:   "same id and fields",
+			stored: []cloudflare.DNSRecord{www},
+			live:   []cloudflare.DNSRecord{www},
+			want:   []string{},
+		},
+		{
+			name:    "same id with changed fields",
+			stored:  []cloudflare.DNSRecord{www},
+			live:    []cloudflare.DNSRecord{{ID: "rec1", Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: 600, Comment: "moved"}},
+			want:    []string{"modified www.example.com A"},
+			changes: []string{"content 192.0.2.1 -> 192.0.2.2", "ttl 300 -> 600", `comment "" -> "moved"`},
+		},
+		{
+			name:    "content matches after normalizing",
+			stored:  []cloudflare.DNSRecord{{ID: "rec2", Name: "alias.example.com", Type: "CNAME", Content: "WWW.example.com.", TTL: 300}},
+			live:    []cloudflare.DNSRecord{{ID: "rec2", Name: "alias.example.com", Type: "CNAME", Content: "www.example.com", TTL: 300}},
+			want:    []string{},
+			changes: nil,
+		},
+		{
+			name:    "one record each with a new id pairs into modified",
+			stored:  []cloudflare.DNSRecord{www},
+			live:    []cloudflare.DNSRecord{{ID: "rec9", Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: 300}},
+			want:    []string{"modified www.example.com A"},
+			changes: []string{"id rec1 -> rec9", "content 192.0.2.1 -> 192.0.2.2"},
+		},
+		{
+			name:    "one record each differing only by id",
+			stored:  []cloudflare.DNSRecord{www},
+			live:    []cloudflare.DNSRecord{{ID: "rec9", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 300}},
+			want:    []string{"modified www.example.com A"},
+			changes: []string{"id rec1 -> rec9"},
+		},
+		{
+			name:   "several records with new ids are added and removed",
+			stored: []cloudflare.DNSRecord{www, {ID: "rec2", Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: 300}},
+			live:   []cloudflare.DNSRecord{{ID: "rec8", Name: "www.example.com", Type: "A", Content: "192.0.2.3", TTL: 300}, {ID: "rec9", Name: "www.example.com", Type: "A", Content: "192.0.2.4", TTL: 300}},
+			want:   []string{"added www.example.com A", "added www.example.com A", "removed www.example.com A", "removed www.example.com A"},
+		},
+		{
+			name:   "records of another name are added and removed",
+			stored: []cloudflare.DNSRecord{www},
+			live:   []cloudflare.DNSRecord{{ID: "rec9", Name: "api.example.com", Type: "A", Content: "192.0.2.1", TTL: 300}},
+			want:   []string{"added api.example.com A", "removed www.example.com A"},
+		},
+		{
+			name:   "types the inventory cannot hold are ignored",
+			stored: []cloudflare.DNSRecord{www},
+			live:   []cloudflare.DNSRecord{www, {ID: "rec3", Name: "_sip._tcp.example.com", Type: "SRV", Content: "10 5060 sip.example.com", TTL: 300}, {ID: "rec4", Name: "example.com", Type: "CAA", Content: `0 issue "letsencrypt.org"`, TTL: 300}},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift := DetectDrift(tt.stored, tt.live)
+			if got := driftSummary(drift); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected drift %q, got %q", tt.want, got)
+			}
+			if tt.changes != nil && !slices.Equal(drift[0].Changes, tt.changes) {
+				t.Fatalf("expected changes %q, got %q", tt.changes, drift[0].Changes)
+			}
+		})
+	}
+}
+
+func TestReplaceDnsDbRecords(t *testing.T) {
+	cfcmd := &CloudflareCommandUtils{ZomeId: "zone1", ZoneName: "example.com", DbConn: newTestDb(t)}
+	cfcmd.CreateDnsDbRecords([]cloudflare.DNSRecord{
+		{ID: "rec1", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 300, Comment: "web", Tags: []string{"env:prod"}},
+		{ID: "rec2", Name: "old.example.com", Type: "A", Content: "192.0.2.2", TTL: 300, Comment: "retired"},
+	})
+	other := &CloudflareCommandUtils{ZomeId: "zone2", ZoneName: "example.net", DbConn: cfcmd.DbConn}
+	other.CreateDnsDbRecords([]cloudflare.DNSRecord{{ID: "rec3", Name: "www.example.net", Type: "A", Content: "192.0.2.3", TTL: 300}})
+
+	live := []cloudflare.DNSRecord{
+		{ID: "rec1", Name: "www.example.com", Type: "A", Content: "192.0.2.5", TTL: 300},
+		{ID: "rec4", Name: "_sip._tcp.example.com", Type: "SRV", Content: "10 5060 sip.example.com", TTL: 300},
+	}
+	cfcmd.ReplaceDnsDbRecords(live)
+	if cfcmd.Error != nil {
+		t.Fatalf("ReplaceDnsDbRecords: %v", cfcmd.Error)
+	}
+
+	stored := cfcmd.DnsRecordsFromDb()
+	if cfcmd.Error != nil {
+		t.Fatalf("DnsRecordsFromDb: %v", cfcmd.Error)
+	}
+	if len(stored) != 1 || stored[0].Content != "192.0.2.5" || stored[0].Comment != "" || len(stored[0].Tags) != 0 {
+		t.Fatalf("expected only the live A record without the old comment and tags, got %+v", stored)
+	}
+	if drift := DetectDrift(stored, live); len(drift) != 0 {
+		t.Fatalf("expected no drift after the update, got %q", driftSummary(drift))
+	}
+	if records := other.DnsRecordsFromDb(); len(records) != 1 {
+		t.Fatalf("expected the other zone's inventory untouched, got %+v", records)
+	}
+	var comments int
+	cfcmd.DbConn.QueryRow("SELECT count(*) FROM record_comments WHERE comment = 'retired'").Scan(&comments)
+	if comments != 0 {
+		t.Fatalf("expected the comments of removed records deleted, found %d", comments)
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"text/tabwriter"
 
+	"github.com/babbage88/go-acme-cli/cloud_providers/cf_acme"
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
 	"github.com/babbage88/go-acme-cli/database/infracli_db"
 	"github.com/babbage88/go-acme-cli/internal/pretty"
 	"github.com/cloudflare/cloudflare-go"
@@ -19,21 +22,23 @@ import (
 const versionNumber = "v1.0.0"
 
 type CloudflareCommandUtils struct {
-	ZomeId    string          `json:"zoneId"`
-	ZoneName  string          `json:"zoneName"`
-	EnvFile   string          `json:"envFile"`
-	Error     error           `json:"error"`
-	ApiClient *cloudflare.API `json:"clouflareApi"`
-	DbConn    *sql.DB         `json:"db"`
-	UseEnv    bool            `json:"useEnv"`
+	ZomeId         string               `json:"zoneId"`
+	ZoneName       string               `json:"zoneName"`
+	EnvFile        string               `json:"envFile"`
+	Error          error                `json:"error"`
+	ApiClient      *cloudflare.API      `json:"clouflareApi"`
+	Provider       dnsprovider.Provider `json:"-"`
+	DbConn         *sql.DB              `json:"db"`
+	UseEnv         bool                 `json:"useEnv"`
+	AllowUnaudited bool                 `json:"allowUnaudited"`
 }
 
 func NewCloudflareCommandFromEnv(envfile string, domainName string) *CloudflareCommandUtils {
 	cfcmd := &CloudflareCommandUtils{EnvFile: envfile, ZoneName: domainName, UseEnv: true}
 	cfcmd.Error = godotenv.Load(cfcmd.EnvFile)
 	cfcmd.NewApiClientFromEnv()
-	if cfcmd.Error == nil {
-		cfcmd.ZomeId, cfcmd.Error = cfcmd.ApiClient.ZoneIDByName(domainName)
+	if cfcmd.Error == nil && domainName != "" {
+		cfcmd.ResolveZone(domainName)
 	}
 	cfcmd.UseEnv = true
 
@@ -43,20 +48,58 @@ func NewCloudflareCommandFromEnv(envfile string, domainName string) *CloudflareC
 func NewCloudflareCommand(token string, domainName string) *CloudflareCommandUtils {
 	cfcmd := &CloudflareCommandUtils{UseEnv: false, ZoneName: domainName}
 	cfcmd.NewApiClientFromToken(token)
-	if cfcmd.Error == nil {
-		cfcmd.ZomeId, cfcmd.Error = cfcmd.ApiClient.ZoneIDByName(domainName)
+	if cfcmd.Error == nil && domainName != "" {
+		cfcmd.ResolveZone(domainName)
+	}
+
+	return cfcmd
+}
+
+// NewDnsProviderCommand runs the dns commands against providerName, or the per zone backends in
+// DNS_ZONE_PROVIDERS, configured in envfile. cfToken falls back to CF_TOKEN.
+func NewDnsProviderCommand(providerName string, envfile string, cfToken string, domainName string) *CloudflareCommandUtils {
+	cfcmd := &CloudflareCommandUtils{EnvFile: envfile, ZoneName: domainName, UseEnv: true}
+	err := godotenv.Load(cfcmd.EnvFile)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("error loading .env: %s", err.Error()))
+	}
+	if cfToken == "" {
+		cfToken = os.Getenv("CF_TOKEN")
+	}
+	cfcmd.Provider, cfcmd.Error = cf_acme.NewDnsProviderFromConfig(providerName, cfToken)
+	if cfcmd.Error == nil && domainName != "" {
+		cfcmd.ResolveZone(domainName)
 	}
 
 	return cfcmd
 }
 
+// ResolveZone finds the longest zone containing domainName, so --domain-name accepts record
+// names, multi-label suffixes like example.co.uk and delegated subzones.
+func (cf *CloudflareCommandUtils) ResolveZone(domainName string) {
+	zone, err := cf.Provider.ZoneForName(context.Background(), domainName)
+	cf.Error = err
+	if err != nil {
+		return
+	}
+	cf.ZomeId = zone.ID
+	cf.ZoneName = zone.Name
+}
+
+// Zone returns the resolved zone for Provider calls.
+func (cf *CloudflareCommandUtils) Zone() dnsprovider.Zone {
+	return dnsprovider.Zone{ID: cf.ZomeId, Name: cf.ZoneName}
+}
+
 func (cf *CloudflareCommandUtils) NewApiClientFromEnv() {
 	cf.Error = godotenv.Load(cf.EnvFile)
 	cf.ApiClient, cf.Error = cloudflare.NewWithAPIToken(os.Getenv("CF_TOKEN"))
+	cf.Provider = cf_acme.NewCloudflareProvider(cf.ApiClient)
 }
 
 func (cf *CloudflareCommandUtils) NewApiClientFromToken(token string) {
 	cf.ApiClient, cf.Error = cloudflare.NewWithAPIToken(token)
+	cf.Provider = cf_acme.NewCloudflareProvider(cf.ApiClient)
 }
 
 type UrFaveCliDocumentationSucks struct {
@@ -64,39 +107,139 @@ type UrFaveCliDocumentationSucks struct {
 	Email string `json:"email"`
 }
 
+// ListDNSRecords returns the records matching params. params.Page selects a single page of
+// params.PerPage records, otherwise every page is fetched.
 func (cfcmd *CloudflareCommandUtils) ListDNSRecords(params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, *cloudflare.ResultInfo) {
+	return cfcmd.ListDNSRecordsLimit(params, 0)
+}
+
+// ListDNSRecordsLimit is ListDNSRecords stopping after limit records when limit > 0.
+func (cfcmd *CloudflareCommandUtils) ListDNSRecordsLimit(params cloudflare.ListDNSRecordsParams, limit int) ([]cloudflare.DNSRecord, *cloudflare.ResultInfo) {
 	records := []cloudflare.DNSRecord{}
-	results := &cloudflare.ResultInfo{}
-	records, results, cfcmd.Error = cfcmd.ApiClient.ListDNSRecords(context.Background(), cloudflare.ZoneIdentifier(cfcmd.ZomeId), params)
-	return records, results
+	info := cfcmd.StreamDNSRecords(params, limit, func(page []cloudflare.DNSRecord) error {
+		records = append(records, page...)
+		return nil
+	})
+	return records, info
+}
+
+// StreamDNSRecords hands the records matching params to fn a page at a time, in page order, as
+// the pages arrive. Paging follows ListDNSRecordsLimit.
+func (cfcmd *CloudflareCommandUtils) StreamDNSRecords(params cloudflare.ListDNSRecordsParams, limit int, fn func([]cloudflare.DNSRecord) error) *cloudflare.ResultInfo {
+	filter := dnsprovider.RecordFilter{
+		Name:     params.Name,
+		Type:     params.Type,
+		Content:  params.Content,
+		Comment:  params.Comment,
+		Tags:     params.Tags,
+		Priority: params.Priority,
+		Proxied:  params.Proxied,
+	}
+	convert := func(page []dnsprovider.Record) error {
+		records := make([]cloudflare.DNSRecord, 0, len(page))
+		for _, v := range page {
+			records = append(records, cf_acme.CloudflareRecord(v))
+		}
+		return fn(records)
+	}
+
+	ctx := context.Background()
+	var info dnsprovider.PageInfo
+	if params.Page > 0 {
+		var found []dnsprovider.Record
+		found, info, cfcmd.Error = dnsprovider.ListPage(ctx, cfcmd.Provider, cfcmd.Zone(), filter, params.Page, params.PerPage)
+		if limit > 0 && len(found) > limit {
+			found = found[:limit]
+		}
+		if cfcmd.Error == nil && len(found) > 0 {
+			cfcmd.Error = convert(found)
+		}
+	} else {
+		info, cfcmd.Error = dnsprovider.StreamRecords(ctx, cfcmd.Provider, cfcmd.Zone(), filter, params.PerPage, dnsprovider.DefaultPageConcurrency, limit, convert)
+	}
+	return &cloudflare.ResultInfo{Page: info.Page, PerPage: info.PerPage, TotalPages: info.TotalPages, Count: info.Count, Total: info.Total}
 }
 
 func (cfcmd *CloudflareCommandUtils) GetDnsRecord(recordId string) cloudflare.DNSRecord {
-	record := cloudflare.DNSRecord{}
-	record, cfcmd.Error = cfcmd.ApiClient.GetDNSRecord(context.Background(), cloudflare.ZoneIdentifier(cfcmd.ZomeId), recordId)
-	return record
+	record, err := cfcmd.Provider.GetRecord(context.Background(), cfcmd.Zone(), recordId)
+	cfcmd.Error = err
+	return cf_acme.CloudflareRecord(record)
+}
+
+// FindDnsRecords returns the records named name, narrowed by recordType and content when set.
+// Relative names are in the zone. More than one match is an error unless all is set.
+func (cfcmd *CloudflareCommandUtils) FindDnsRecords(name string, recordType string, content string, all bool) []cloudflare.DNSRecord {
+	name = dnsprovider.QualifyName(name, cfcmd.ZoneName)
+	records, _ := cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{Name: name, Type: recordType, Content: content})
+	if cfcmd.Error != nil {
+		return records
+	}
+	selector := strings.TrimSpace(strings.Join([]string{name, recordType, content}, " "))
+	switch {
+	case len(records) == 0:
+		cfcmd.Error = fmt.Errorf("no dns record matches %s in zone %s", selector, cfcmd.ZoneName)
+	case len(records) > 1 && !all:
+		cfcmd.Error = fmt.Errorf("%d dns records match %s, narrow it with --type/--content or pass --all", len(records), selector)
+	}
+	return records
 }
 
 func (cfcmd *CloudflareCommandUtils) CreateOrUpdateDNSRecord(params any) cloudflare.DNSRecord {
-	record := cloudflare.DNSRecord{}
+	record := dnsprovider.Record{}
 
 	switch v := any(params).(type) {
 	case cloudflare.UpdateDNSRecordParams:
-		record, cfcmd.Error = createOrUpdateCloudflareDnsRecord(*cfcmd.ApiClient, cfcmd.ZomeId, v)
+		update := dnsprovider.Record{ID: v.ID, Name: v.Name, Type: v.Type, Content: v.Content, TTL: v.TTL, Priority: v.Priority, Proxied: v.Proxied, Comment: v.Comment, Tags: v.Tags}
+		if cfcmd.Error = cfcmd.checkAuditLog(); cfcmd.Error != nil {
+			logger.Error(cfcmd.Error.Error())
+			break
+		}
+		before := cfcmd.auditBefore(v.ID)
+		record, cfcmd.Error = cfcmd.Provider.UpdateRecord(context.Background(), cfcmd.Zone(), update)
+		if cfcmd.Error != nil {
+			logger.Error(fmt.Sprintf("Error updating DNS record %s in Zone: %s err: %s", v.ID, cfcmd.ZomeId, cfcmd.Error.Error()))
+			break
+		}
+		after := cf_acme.CloudflareRecord(record)
+		cfcmd.auditRecordChange(AuditActionUpdate, after.ID, after.Name, before, &after)
 	case cloudflare.CreateDNSRecordParams:
-		record, cfcmd.Error = createOrUpdateCloudflareDnsRecord(*cfcmd.ApiClient, cfcmd.ZomeId, v)
+		create := dnsprovider.Record{Name: v.Name, Type: v.Type, Content: v.Content, TTL: v.TTL, Priority: v.Priority, Proxied: v.Proxied, Comment: &v.Comment, Tags: v.Tags}
+		if cfcmd.Error = cfcmd.checkAuditLog(); cfcmd.Error != nil {
+			logger.Error(cfcmd.Error.Error())
+			break
+		}
+		record, cfcmd.Error = cfcmd.Provider.CreateRecord(context.Background(), cfcmd.Zone(), create)
+		if cfcmd.Error != nil {
+			logger.Error(fmt.Sprintf("Error creating DNS record %s in Zone: %s err: %s", v.Name, cfcmd.ZomeId, cfcmd.Error.Error()))
+			break
+		}
+		after := cf_acme.CloudflareRecord(record)
+		cfcmd.auditRecordChange(AuditActionCreate, after.ID, after.Name, nil, &after)
 	default:
 		cfcmd.Error = fmt.Errorf("unsupported DNS record operation: %T", params)
 	}
 
-	return record
+	return cf_acme.CloudflareRecord(record)
 }
 
 func (cfcmd *CloudflareCommandUtils) PrintDnsRecordsTable(records []cloudflare.DNSRecord) {
 	var colorInt int32 = 97
 	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 1, ' ', 0)
+	writeDnsRecordsHeader(tw)
+	colorInt = writeDnsRecordRows(tw, records)
+	tw.Flush()
+	fmt.Printf("\x1b[1;%dm\nFound %d records in ZoneID: %s Name: %s\x1b[0m\n", colorInt, len(records), cfcmd.ZomeId, cfcmd.ZoneName)
+}
+
+func writeDnsRecordsHeader(tw *tabwriter.Writer) {
+	var colorInt int32 = 97
 	fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%s\t%s\x1b[0m\n", colorInt, "ID", "Name", "Content", "Type", "CreatedOn", "ModifiedOn", "Comment")
 	fmt.Fprintf(tw, "\x1b[1;%dm--\t----\t-------\t----\t---------\t----------\t-------\x1b[0m\n", colorInt)
+}
+
+// writeDnsRecordRows writes one colored row per record and returns the color of the last row.
+func writeDnsRecordRows(tw *tabwriter.Writer, records []cloudflare.DNSRecord) int32 {
+	var colorInt int32 = 97
 	for _, v := range records {
 		switch v.Type {
 		case "A":
@@ -108,15 +251,58 @@ func (cfcmd *CloudflareCommandUtils) PrintDnsRecordsTable(records []cloudflare.D
 		}
 		fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%s\t%s\x1b[0m\n", colorInt, v.ID, v.Name, v.Content, v.Type, pretty.DateTimeSting(v.CreatedOn), pretty.DateTimeSting(v.ModifiedOn), v.Comment)
 	}
+	return colorInt
+}
+
+// StreamDnsRecordsTable prints each page of records as it arrives, under a single header. Columns
+// are aligned per page.
+func (cfcmd *CloudflareCommandUtils) StreamDnsRecordsTable(params cloudflare.ListDNSRecordsParams, limit int, onPage func([]cloudflare.DNSRecord)) *cloudflare.ResultInfo {
+	var colorInt int32 = 97
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 1, ' ', 0)
+	writeDnsRecordsHeader(tw)
+	info := cfcmd.StreamDNSRecords(params, limit, func(page []cloudflare.DNSRecord) error {
+		colorInt = writeDnsRecordRows(tw, page)
+		if onPage != nil {
+			onPage(page)
+		}
+		return tw.Flush()
+	})
 	tw.Flush()
-	fmt.Printf("\x1b[1;%dm\nFound %d records in ZoneID: %s Name: %s\x1b[0m\n", colorInt, len(records), cfcmd.ZomeId, cfcmd.ZoneName)
+	fmt.Printf("\x1b[1;%dm\nFound %d records in ZoneID: %s Name: %s\x1b[0m\n", colorInt, info.Count, cfcmd.ZomeId, cfcmd.ZoneName)
+	return info
+}
+
+// StreamDnsRecordsJson prints each record as a line of JSON as its page arrives.
+func (cfcmd *CloudflareCommandUtils) StreamDnsRecordsJson(params cloudflare.ListDNSRecordsParams, limit int, onPage func([]cloudflare.DNSRecord)) *cloudflare.ResultInfo {
+	encoder := json.NewEncoder(os.Stdout)
+	return cfcmd.StreamDNSRecords(params, limit, func(page []cloudflare.DNSRecord) error {
+		for _, v := range page {
+			if err := encoder.Encode(v); err != nil {
+				return err
+			}
+		}
+		if onPage != nil {
+			onPage(page)
+		}
+		return nil
+	})
 }
 
 func (cfcmd *CloudflareCommandUtils) DeleteCloudflareRecord(recordId string) {
-	cfcmd.Error = cfcmd.ApiClient.DeleteDNSRecord(context.Background(), cloudflare.ZoneIdentifier(cfcmd.ZomeId), recordId)
+	if cfcmd.Error = cfcmd.checkAuditLog(); cfcmd.Error != nil {
+		logger.Error(cfcmd.Error.Error())
+		return
+	}
+	before := cfcmd.auditBefore(recordId)
+	cfcmd.Error = cfcmd.Provider.DeleteRecord(context.Background(), cfcmd.Zone(), recordId)
 	if cfcmd.Error == nil {
 		msg := fmt.Sprintf("DNS RecordID: %s in Zone: %s has been deleted succesfully", recordId, cfcmd.ZomeId)
 		logger.Info(msg)
+		name := ""
+		if before != nil {
+			name = before.Name
+		}
+		cfcmd.auditRecordChange(AuditActionDelete, recordId, name, before, nil)
 	}
 }
 
@@ -210,49 +396,65 @@ func (author *UrFaveCliDocumentationSucks) String() string {
 	return fmt.Sprintf("Name: %s Email: %s", author.Name, author.Email)
 }
 
-func createOrUpdateCloudflareDnsRecord[T DnsRequestHandler](api cloudflare.API, zoneId string, params T) (cloudflare.DNSRecord, error) {
-	record := cloudflare.DNSRecord{}
-	var err error = nil
-	switch v := any(params).(type) {
-	case cloudflare.UpdateDNSRecordParams:
-		record, err = api.UpdateDNSRecord(context.Background(), cloudflare.ZoneIdentifier(zoneId), v)
-		if err != nil {
-			msg := fmt.Sprintf("Error updating DNS record %s in Zone: %s err: %s", v.ID, zoneId, err.Error())
-			logger.Error(msg)
-			return record, err
-		}
-		return record, err
-	case cloudflare.CreateDNSRecordParams:
-		record, err = api.CreateDNSRecord(context.Background(), cloudflare.ZoneIdentifier(zoneId), v)
-		if err != nil {
-			msg := fmt.Sprintf("Error updating DNS record %s in Zone: %s err: %s", v.Name, zoneId, err.Error())
-			logger.Error(msg)
-			return record, err
-		}
-	default:
-		err = fmt.Errorf("unsupported DNS record operation %T. Must use cloudflare.UpdateDNSRecordParams or CreateDNSRecordParams", params)
+func (cfcmd *CloudflareCommandUtils) CreateDnsDbRecords(records []cloudflare.DNSRecord) {
+	if cfcmd.DbConn == nil {
+		logger.Error("DbConn is nil.")
+		cfcmd.InitializeDatabaseConnection()
+		defer cfcmd.DbConn.Close()
+	}
+
+	err := insertDnsDbRecords(context.Background(), infracli_db.New(cfcmd.DbConn), cfcmd.ZomeId, records)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
-	return record, err
 }
 
-func (cfcmd *CloudflareCommandUtils) CreateDnsDbRecords(records []cloudflare.DNSRecord) {
+// ReplaceDnsDbRecords makes records the whole inventory of the zone in one transaction, so
+// records deleted from the zone leave the inventory too. Records of types the inventory cannot
+// hold are skipped.
+func (cfcmd *CloudflareCommandUtils) ReplaceDnsDbRecords(records []cloudflare.DNSRecord) {
 	if cfcmd.DbConn == nil {
-		logger.Error("DbConn is nil.")
 		cfcmd.InitializeDatabaseConnection()
 		defer cfcmd.DbConn.Close()
 	}
+	supported := []cloudflare.DNSRecord{}
+	for _, v := range records {
+		if _, ok := recordTypeMap[v.Type]; ok {
+			supported = append(supported, v)
+		}
+	}
 
-	queries := infracli_db.New(cfcmd.DbConn)
+	ctx := context.Background()
+	tx, err := cfcmd.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		cfcmd.Error = err
+		return
+	}
+	defer tx.Rollback()
+	queries := infracli_db.New(cfcmd.DbConn).WithTx(tx)
+	for _, deleteRows := range []func(context.Context, string) error{queries.DeleteRecordCommentsByZoneUid, queries.DeleteRecordTagsByZoneUid, queries.DeleteRecordByZoneId} {
+		if err := deleteRows(ctx, cfcmd.ZomeId); err != nil {
+			cfcmd.Error = fmt.Errorf("error clearing the inventory of %s: %w", cfcmd.ZoneName, err)
+			return
+		}
+	}
+	if err := insertDnsDbRecords(ctx, queries, cfcmd.ZomeId, supported); err != nil {
+		cfcmd.Error = err
+		return
+	}
+	cfcmd.Error = tx.Commit()
+}
 
+func insertDnsDbRecords(ctx context.Context, queries *infracli_db.Queries, zoneUid string, records []cloudflare.DNSRecord) error {
 	for _, v := range records {
 		recTypeId, ok := recordTypeMap[v.Type]
 		if !ok {
-			log.Fatalf("Unknown record type: %s", v.Type)
+			return fmt.Errorf("Unknown record type: %s", v.Type)
 		}
 
 		params := infracli_db.CreateDnsRecordParams{
 			RecordUid: v.ID,
-			ZoneUid:   cfcmd.ZomeId,
+			ZoneUid:   zoneUid,
 			Name:      v.Name,
 			Content:   sql.NullString{String: v.Content, Valid: true},
 			TypeID:    recTypeId,
@@ -260,24 +462,38 @@ func (cfcmd *CloudflareCommandUtils) CreateDnsDbRecords(records []cloudflare.DNS
 			Created:   sql.NullString{String: v.CreatedOn.String(), Valid: true},
 			Modified:  sql.NullString{String: v.ModifiedOn.String(), Valid: true},
 		}
+		if v.Priority != nil {
+			params.Priority = sql.NullInt64{Int64: int64(*v.Priority), Valid: true}
+		}
 
-		row, err := queries.CreateDnsRecord(context.Background(), params)
+		row, err := queries.CreateDnsRecord(ctx, params)
 		if err != nil {
-			log.Fatalf("Failed to create DNS record: %v", err)
+			return fmt.Errorf("Failed to create DNS record: %w", err)
 		}
 
 		if row.ID == 0 {
-			log.Fatalf("Invalid record ID returned from CreateDnsRecord")
+			return fmt.Errorf("Invalid record ID returned from CreateDnsRecord")
 		}
 
 		commentParams := infracli_db.CreateRecordCommentParams{
 			RecordID: row.ID,
 			Comment:  sql.NullString{String: v.Comment, Valid: true},
 		}
-		if err := queries.CreateRecordComment(context.Background(), commentParams); err != nil {
-			log.Fatalf("Failed to create record comment: %v", err)
+		if err := queries.CreateRecordComment(ctx, commentParams); err != nil {
+			return fmt.Errorf("Failed to create record comment: %w", err)
+		}
+
+		if len(v.Tags) > 0 {
+			tagParams := infracli_db.CreateRecordTagParams{
+				RecordID: row.ID,
+				Tags:     sql.NullString{String: strings.Join(v.Tags, ","), Valid: true},
+			}
+			if err := queries.CreateRecordTag(ctx, tagParams); err != nil {
+				return fmt.Errorf("Failed to create record tags: %w", err)
+			}
 		}
 	}
+	return nil
 }
 
 var recordTypeMap = map[string]int64{
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+)
+
+func newSelectTestProvider() *testProvider {
+	return newTestProvider("example.com",
+		dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300},
+		dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.2", TTL: 300},
+		dnsprovider.Record{Name: "www", Type: "AAAA", Content: "2001:db8::1", TTL: 300},
+		dnsprovider.Record{Name: "api", Type: "CNAME", Content: "www.example.com", TTL: 300},
+	)
+}
+
+func TestFindDnsRecords(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordName string
+		recordType string
+		content    string
+		all        bool
+		want       int
+		wantErr    string
+	}{
+		{name: "no match", recordName: "mail", wantErr: "no dns record matches mail.example.com"},
+		{name: "one match", recordName: "api", want: 1},
+		{name: "several matches refused", recordName: "www", want: 3, wantErr: "3 dns records match www.example.com"},
+		{name: "type narrows", recordName: "www.example.com", recordType: "AAAA", want: 1},
+		{name: "type and content narrow", recordName: "www", recordType: "A", content: "192.0.2.2", want: 1},
+		{name: "several matches with all", recordName: "www", recordType: "A", all: true, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfcmd := newTestCommand(newSelectTestProvider())
+			records := cfcmd.FindDnsRecords(tt.recordName, tt.recordType, tt.content, tt.all)
+			if len(records) != tt.want {
+				t.Fatalf("expected %d records, got %+v", tt.want, records)
+			}
+			if tt.wantErr == "" {
+				if cfcmd.Error != nil {
+					t.Fatalf("unexpected error %v", cfcmd.Error)
+				}
+				return
+			}
+			if cfcmd.Error == nil || !strings.Contains(cfcmd.Error.Error(), tt.wantErr) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, cfcmd.Error)
+			}
+		})
+	}
+}
+
+func TestSelectDnsRecordsFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		content []string
+		failed  bool
+	}{
+		{args: []string{"--record-name", "www", "--type", "a", "--content", "192.0.2.1"}, content: []string{"192.0.2.1"}},
+		{args: []string{"--record-name", "www", "--type", "a"}, failed: true},
+		{args: []string{"--record-name", "www", "--type", "a", "--all"}, content: []string{"192.0.2.1", "192.0.2.2"}},
+		{args: []string{"--record-name", "gone", "--all"}, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			cfcmd := newTestCommand(newSelectTestProvider())
+			var records []cloudflare.DNSRecord
+			cmd := &cli.Command{
+				Name: "select",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{Name: "record-name"},
+					&cli.StringFlag{Name: "type"},
+				}, recordSelectorFlags()...),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					records = selectDnsRecords(cmd, cfcmd)
+					return nil
+				},
+			}
+			if err := cmd.Run(context.Background(), append([]string{"select"}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.failed {
+				if cfcmd.Error == nil {
+					t.Fatalf("expected the selection refused, got %+v", records)
+				}
+				return
+			}
+			content := []string{}
+			for _, v := range records {
+				content = append(content, v.Content)
+			}
+			if cfcmd.Error != nil || strings.Join(content, " ") != strings.Join(tt.content, " ") {
+				t.Fatalf("expected %q selected, got %q, %v", tt.content, content, cfcmd.Error)
+			}
+		})
+	}
+}
+
+// pagedTestProvider is a testProvider whose listings can be paged, remembering the pages asked for.
+type pagedTestProvider struct {
+	*testProvider
+	requested []string
+}
+
+func (p *pagedTestProvider) ListRecordsPage(ctx context.Context, zone dnsprovider.Zone, filter dnsprovider.RecordFilter, page int, perPage int) ([]dnsprovider.Record, dnsprovider.PageInfo, error) {
+	p.requested = append(p.requested, fmt.Sprintf("%d/%d", page, perPage))
+	return dnsprovider.ListPage(ctx, p.testProvider, zone, filter, page, perPage)
+}
+
+func TestListDNSRecordsSinglePage(t *testing.T) {
+	records := []dnsprovider.Record{}
+	for i := range 5 {
+		records = append(records, dnsprovider.Record{Name: fmt.Sprintf("host%d", i), Type: "A", Content: fmt.Sprintf("192.0.2.%d", i), TTL: 300})
+	}
+	paged := &pagedTestProvider{testProvider: newTestProvider("example.com", records...)}
+	for _, provider := range []dnsprovider.Provider{paged.testProvider, paged} {
+		t.Run(fmt.Sprintf("%T", provider), func(t *testing.T) {
+			cfcmd := &CloudflareCommandUtils{ZomeId: "zone1", ZoneName: "example.com", Provider: provider}
+			found, info := cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{ResultInfo: cloudflare.ResultInfo{Page: 2, PerPage: 2}})
+			if cfcmd.Error != nil {
+				t.Fatalf("ListDNSRecords: %v", cfcmd.Error)
+			}
+			if len(found) != 2 || found[0].Content != "192.0.2.2" || found[1].Content != "192.0.2.3" {
+				t.Fatalf("expected the second page of two records, got %+v", found)
+			}
+			if info.Page != 2 || info.TotalPages != 3 || info.Total != 5 || info.Count != 2 {
+				t.Fatalf("unexpected page info %+v", info)
+			}
+		})
+	}
+	if strings.Join(paged.requested, " ") != "2/2" {
+		t.Fatalf("expected only page 2 of 2 records requested from the paged provider, got %q", paged.requested)
+	}
+}
@@ -0,0 +1,361 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/miekg/dns"
+	"github.com/urfave/cli/v3"
+)
+
+var DefaultVerifyResolvers = []string{"1.1.1.1", "8.8.8.8", "9.9.9.9"}
+
+// ResolverAnswer is what one nameserver returned for the record being verified.
+type ResolverAnswer struct {
+	Resolver      string   `json:"resolver"`
+	Authoritative bool     `json:"authoritative"`
+	Rcode         string   `json:"rcode"`
+	Answers       []string `json:"answers"`
+	TTL           uint32   `json:"ttl"`
+	Match         bool     `json:"match"`
+	Proxied       bool     `json:"proxied,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// PropagationCheck queries Name and Type on the zone's authoritative nameservers and on public
+// resolvers and compares the answers with Expected. An empty Expected checks that the record is gone.
+// Proxied records resolve to the proxy's addresses, so for them any answer matches.
+type PropagationCheck struct {
+	Name          string
+	Type          string
+	Expected      []string
+	Proxied       bool
+	Authoritative []string
+	Resolvers     []string
+	Timeout       time.Duration
+}
+
+// proxiedRecords reports whether the provider proxies records, which then resolve to the
+// proxy's addresses instead of their content.
+func proxiedRecords(records []cloudflare.DNSRecord) bool {
+	return slices.ContainsFunc(records, func(v cloudflare.DNSRecord) bool {
+		return v.Proxied != nil && *v.Proxied && slices.Contains([]string{"A", "AAAA", "CNAME"}, strings.ToUpper(v.Type))
+	})
+}
+
+// rdataString returns the presentation form of the rdata of rr, lowercased except for TXT so
+// answers compare regardless of name case.
+func rdataString(rr dns.RR) string {
+	rdata := strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+	if rr.Header().Rrtype == dns.TypeTXT || rr.Header().Rrtype == dns.TypeSPF {
+		return rdata
+	}
+	return strings.ToLower(rdata)
+}
+
+// expectedRData converts record content as the dns commands take it to the rdata resolvers return.
+func expectedRData(name string, record cloudflare.DNSRecord) (string, error) {
+	rr, err := dns.NewRR(fmt.Sprintf("%s 300 IN %s %s", dns.Fqdn(name), strings.ToUpper(record.Type), bindRData(record)))
+	if err != nil {
+		return "", err
+	}
+	if rr == nil {
+		return "", fmt.Errorf("empty %s record content", record.Type)
+	}
+	return rdataString(rr), nil
+}
+
+// AuthoritativeNameservers looks up the NS records of zone through resolvers and returns them as
+// host:53 addresses.
+func AuthoritativeNameservers(zone string, resolvers []string, timeout time.Duration) ([]string, error) {
+	client := &dns.Client{Timeout: timeout}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeNS)
+	msg.RecursionDesired = true
+
+	var lastErr error
+	for _, resolver := range dns01.ParseNameservers(resolvers) {
+		resp, _, err := client.Exchange(msg, resolver)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf("NS lookup for %s on %s returned %s", zone, resolver, dns.RcodeToString[resp.Rcode])
+			continue
+		}
+		nameservers := []string{}
+		for _, rr := range resp.Answer {
+			if ns, ok := rr.(*dns.NS); ok {
+				nameservers = append(nameservers, net.JoinHostPort(strings.TrimSuffix(ns.Ns, "."), "53"))
+			}
+		}
+		if len(nameservers) > 0 {
+			slices.Sort(nameservers)
+			return nameservers, nil
+		}
+		lastErr = fmt.Errorf("no NS records for %s on %s", zone, resolver)
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no resolvers to look up the nameservers of %s", zone)
+	}
+	return nil, lastErr
+}
+
+// Query asks every nameserver in parallel. Authoritative servers are asked without recursion.
+func (c *PropagationCheck) Query(ctx context.Context) []ResolverAnswer {
+	servers := []string{}
+	servers = append(servers, c.Authoritative...)
+	servers = append(servers, dns01.ParseNameservers(c.Resolvers)...)
+	answers := make([]ResolverAnswer, len(servers))
+	runParallel(len(servers), len(servers), func(i int) {
+		answers[i] = c.query(ctx, servers[i], i < len(c.Authoritative))
+	})
+	return answers
+}
+
+func (c *PropagationCheck) query(ctx context.Context, server string, authoritative bool) ResolverAnswer {
+	answer := ResolverAnswer{Resolver: server, Authoritative: authoritative, Answers: []string{}}
+	qtype := dns.StringToType[strings.ToUpper(c.Type)]
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(c.Name), qtype)
+	msg.RecursionDesired = !authoritative
+
+	client := &dns.Client{Timeout: c.Timeout}
+	resp, _, err := client.ExchangeContext(ctx, msg, server)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.ExchangeContext(ctx, msg, server)
+	}
+	if err != nil {
+		answer.Error = err.Error()
+		return answer
+	}
+
+	answer.Rcode = dns.RcodeToString[resp.Rcode]
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		if len(answer.Answers) == 0 || rr.Header().Ttl < answer.TTL {
+			answer.TTL = rr.Header().Ttl
+		}
+		answer.Answers = append(answer.Answers, rdataString(rr))
+	}
+	slices.Sort(answer.Answers)
+	if c.Proxied {
+		// A proxied CNAME is flattened into the proxy's addresses and has no CNAME answer.
+		answer.Proxied = true
+		answer.Match = resp.Rcode == dns.RcodeSuccess && (len(answer.Answers) > 0 || qtype == dns.TypeCNAME)
+		return answer
+	}
+	rcodeOk := resp.Rcode == dns.RcodeSuccess || (resp.Rcode == dns.RcodeNameError && len(c.Expected) == 0)
+	answer.Match = rcodeOk && slices.Equal(answer.Answers, c.Expected)
+	return answer
+}
+
+// Wait queries every interval until all nameservers match or ctx is done, returning the last answers.
+func (c *PropagationCheck) Wait(ctx context.Context, interval time.Duration) ([]ResolverAnswer, bool) {
+	for {
+		answers := c.Query(ctx)
+		if allMatch(answers) {
+			return answers, true
+		}
+		pending := []string{}
+		for _, v := range answers {
+			if !v.Match {
+				pending = append(pending, v.Resolver)
+			}
+		}
+		logger.Info(fmt.Sprintf("waiting on %s", strings.Join(pending, ", ")))
+		select {
+		case <-ctx.Done():
+			return answers, false
+		case <-time.After(interval):
+		}
+	}
+}
+
+func allMatch(answers []ResolverAnswer) bool {
+	for _, v := range answers {
+		if !v.Match {
+			return false
+		}
+	}
+	return len(answers) > 0
+}
+
+func PrintResolverAnswers(answers []ResolverAnswer) error {
+	var colorInt int32 = 97
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dmResolver\tKind\tRcode\tTTL\tAnswers\tMatch\x1b[0m\n", colorInt)
+	fmt.Fprintf(tw, "\x1b[1;%dm--------\t----\t-----\t---\t-------\t-----\x1b[0m\n", colorInt)
+	for _, v := range answers {
+		kind := "recursive"
+		if v.Authoritative {
+			kind = "authoritative"
+		}
+		result := strings.Join(v.Answers, ", ")
+		if v.Proxied {
+			result = strings.TrimSpace("proxied " + result)
+		}
+		switch {
+		case v.Error != "":
+			colorInt = 91
+			result = v.Error
+		case v.Match:
+			colorInt = 92
+		default:
+			colorInt = 93
+		}
+		fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%d\t%s\t%t\x1b[0m\n", colorInt, v.Resolver, kind, v.Rcode, v.TTL, result, v.Match)
+	}
+	return tw.Flush()
+}
+
+func verifyCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "verify",
+		Version:               versionNumber,
+		Aliases:               []string{"propagation"},
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Check that --record-name resolves to the provider's records on the authoritative nameservers and public resolvers.",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "expect",
+				Usage: "Expected record content. Defaults to the records the dns provider holds, none expects the record to be gone.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "recursive-nameservers",
+				Aliases: []string{"resolver"},
+				Value:   DefaultVerifyResolvers,
+				Usage:   "Public resolvers to check, host or host:port.",
+				Sources: cli.EnvVars("DNS_VERIFY_RESOLVERS"),
+			},
+			&cli.StringSliceFlag{
+				Name:  "nameserver",
+				Usage: "Authoritative nameservers to check, host or host:port. Defaults to the NS records of the zone.",
+			},
+			&cli.BoolFlag{
+				Name:  "wait",
+				Usage: "Keep checking until every nameserver agrees or --timeout expires.",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Value: 5 * time.Minute,
+				Usage: "How long --wait keeps checking.",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Value: 10 * time.Second,
+				Usage: "Time between checks with --wait.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			if cmd.String("record-name") == "" {
+				err = fmt.Errorf("set --record-name to the record to verify")
+				logger.Error(err.Error())
+				return err
+			}
+			cfcmd := dnsCommandFromCli(cmd)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			recordType := strings.ToUpper(cmd.String("type"))
+			if recordType == "" {
+				recordType = "A"
+			}
+			if _, ok := dns.StringToType[recordType]; !ok {
+				err = fmt.Errorf("unknown record type %s", recordType)
+				logger.Error(err.Error())
+				return err
+			}
+
+			check := &PropagationCheck{
+				Name:      dnsprovider.QualifyName(cmd.String("record-name"), cfcmd.ZoneName),
+				Type:      recordType,
+				Expected:  []string{},
+				Resolvers: cmd.StringSlice("recursive-nameservers"),
+				Timeout:   5 * time.Second,
+			}
+			records := []cloudflare.DNSRecord{}
+			if cmd.IsSet("expect") {
+				for _, v := range cmd.StringSlice("expect") {
+					if !strings.EqualFold(v, "none") {
+						records = append(records, cloudflare.DNSRecord{Type: recordType, Content: v, Priority: updateParamsFromCli(cmd, "").Priority})
+					}
+				}
+			} else {
+				records, _ = cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{Name: check.Name, Type: recordType})
+				if cfcmd.Error != nil {
+					logger.Error(cfcmd.Error.Error())
+					return cfcmd.Error
+				}
+				check.Proxied = proxiedRecords(records)
+			}
+			for _, v := range records {
+				rdata, err := expectedRData(check.Name, v)
+				if err != nil {
+					err = fmt.Errorf("invalid %s content %q: %w", recordType, v.Content, err)
+					logger.Error(err.Error())
+					return err
+				}
+				check.Expected = append(check.Expected, rdata)
+			}
+			slices.Sort(check.Expected)
+
+			if cmd.IsSet("nameserver") {
+				check.Authoritative = dns01.ParseNameservers(cmd.StringSlice("nameserver"))
+			} else {
+				check.Authoritative, err = AuthoritativeNameservers(cfcmd.ZoneName, check.Resolvers, check.Timeout)
+				if err != nil {
+					logger.Error(fmt.Sprintf("error finding the nameservers of %s: %s", cfcmd.ZoneName, err.Error()))
+					return err
+				}
+			}
+
+			expected := strings.Join(check.Expected, ", ")
+			if expected == "" {
+				expected = "no records"
+			}
+			if check.Proxied {
+				expected = "the proxy's addresses, the record is proxied"
+			}
+			logger.Info(fmt.Sprintf("verifying %s %s resolves to %s", check.Name, recordType, expected))
+
+			var answers []ResolverAnswer
+			agreed := false
+			if cmd.Bool("wait") {
+				waitCtx, cancel := context.WithTimeout(ctx, cmd.Duration("timeout"))
+				defer cancel()
+				answers, agreed = check.Wait(waitCtx, cmd.Duration("interval"))
+			} else {
+				answers = check.Query(ctx)
+				agreed = allMatch(answers)
+			}
+
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(answers)
+			} else {
+				PrintResolverAnswers(answers)
+			}
+			if !agreed {
+				return fmt.Errorf("%s %s has not propagated to every nameserver", check.Name, recordType)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
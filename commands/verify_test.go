@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
+)
+
+// testNameserver answers A queries from answers over udp and tcp. Names in truncated get an
+// empty truncated reply over udp, names missing from answers get NXDOMAIN.
+type testNameserver struct {
+	mu        sync.Mutex
+	answers   map[string][]string
+	truncated map[string]bool
+	queries   map[string]int
+}
+
+func (s *testNameserver) set(name string, ips ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.answers[name] = ips
+}
+
+func (s *testNameserver) count(network string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[network]
+}
+
+func (s *testNameserver) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	network := w.RemoteAddr().Network()
+	s.queries[network]++
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	q := r.Question[0]
+	ips, ok := s.answers[q.Name]
+	switch {
+	case !ok:
+		msg.SetRcode(r, dns.RcodeNameError)
+	case s.truncated[q.Name] && network == "udp":
+		msg.Truncated = true
+	default:
+		for _, ip := range ips {
+			msg.Answer = append(msg.Answer, &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP(ip)})
+		}
+	}
+	w.WriteMsg(msg)
+}
+
+func startTestNameserver(t *testing.T) (*testNameserver, string) {
+	t.Helper()
+	s := &testNameserver{answers: make(map[string][]string), truncated: make(map[string]bool), queries: make(map[string]int)}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, server := range []*dns.Server{{PacketConn: conn, Handler: s}, {Listener: ln, Handler: s}} {
+		go server.ActivateAndServe()
+		t.Cleanup(func() { server.Shutdown() })
+	}
+	return s, conn.LocalAddr().String()
+}
+
+func TestPropagationCheckQuery(t *testing.T) {
+	server, addr := startTestNameserver(t)
+	server.set("www.example.com.", "192.0.2.2", "192.0.2.1")
+	server.set("big.example.com.", "192.0.2.3")
+	server.truncated["big.example.com."] = true
+	server.set("proxied.example.com.", "104.16.0.1")
+
+	tests := []struct {
+		name     string
+		record   string
+		expected []string
+		proxied  bool
+		match    bool
+	}{
+		{name: "matching answers", record: "www.example.com", expected: []string{"192.0.2.1", "192.0.2.2"}, match: true},
+		{name: "missing answer", record: "www.example.com", expected: []string{"192.0.2.1"}, match: false},
+		{name: "truncated reply retried over tcp", record: "big.example.com", expected: []string{"192.0.2.3"}, match: true},
+		{name: "nxdomain with none expected", record: "gone.example.com", expected: []string{}, match: true},
+		{name: "nxdomain with records expected", record: "gone.example.com", expected: []string{"192.0.2.1"}, match: false},
+		{name: "proxied record resolves to the proxy", record: "proxied.example.com", expected: []string{"192.0.2.1"}, proxied: true, match: true},
+		{name: "proxied record that does not resolve", record: "gone.example.com", expected: []string{"192.0.2.1"}, proxied: true, match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &PropagationCheck{Name: tt.record, Type: "A", Expected: tt.expected, Proxied: tt.proxied, Authoritative: []string{addr}, Resolvers: []string{addr}, Timeout: 2 * time.Second}
+			answers := check.Query(context.Background())
+			if len(answers) != 2 || !answers[0].Authoritative || answers[1].Authoritative {
+				t.Fatalf("expected an authoritative and a recursive answer, got %+v", answers)
+			}
+			for _, v := range answers {
+				if v.Error != "" || v.Match != tt.match || v.Proxied != tt.proxied {
+					t.Fatalf("expected match %t, got %+v", tt.match, v)
+				}
+			}
+		})
+	}
+	if server.count("tcp") != 2 {
+		t.Fatalf("expected the truncated replies retried over tcp, got %d tcp queries", server.count("tcp"))
+	}
+}
+
+func TestPropagationCheckWait(t *testing.T) {
+	server, addr := startTestNameserver(t)
+	server.set("www.example.com.", "192.0.2.1")
+	check := &PropagationCheck{Name: "www.example.com", Type: "A", Expected: []string{"192.0.2.2"}, Resolvers: []string{addr}, Timeout: 2 * time.Second}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.set("www.example.com.", "192.0.2.2")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	answers, ok := check.Wait(ctx, 10*time.Millisecond)
+	if !ok || !slices.Equal(answers[0].Answers, []string{"192.0.2.2"}) {
+		t.Fatalf("expected the check to pass once the record changed, got %+v", answers)
+	}
+
+	check.Expected = []string{"192.0.2.9"}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if answers, ok := check.Wait(ctx, 10*time.Millisecond); ok || answers[0].Match {
+		t.Fatalf("expected the check to give up when the context ends, got %+v", answers)
+	}
+}
+
+func TestProxiedRecords(t *testing.T) {
+	proxied, direct := true, false
+	tests := []struct {
+		records []cloudflare.DNSRecord
+		want    bool
+	}{
+		{records: []cloudflare.DNSRecord{{Type: "A", Proxied: &proxied}}, want: true},
+		{records: []cloudflare.DNSRecord{{Type: "CNAME", Proxied: &proxied}}, want: true},
+		{records: []cloudflare.DNSRecord{{Type: "A", Proxied: &direct}, {Type: "A"}}, want: false},
+		{records: []cloudflare.DNSRecord{{Type: "TXT", Proxied: &proxied}}, want: false},
+	}
+	for _, tt := range tests {
+		if got := proxiedRecords(tt.records); got != tt.want {
+			t.Fatalf("expected %t for %+v", tt.want, tt.records)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/database/infracli_db"
+	"github.com/babbage88/go-acme-cli/internal/pretty"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
+	"github.com/urfave/cli/v3"
+)
+
+const ZoneFormatBind = "bind"
+
+// WriteBindZone writes records as an RFC 1035 master file for origin. Records with Cloudflare's
+// automatic TTL of 1 use defaultTTL, comments, tags, proxying and the automatic TTL are kept as
+// ; comments so import restores them. Providers
+// do not list the SOA, so one is synthesized when records has none and import skips it again.
+func WriteBindZone(w io.Writer, origin string, defaultTTL int, records []cloudflare.DNSRecord) error {
+	origin = dnsprovider.TrimDot(origin)
+	sorted := slices.Clone(records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return bindOwner(sorted[i].Name, origin) < bindOwner(sorted[j].Name, origin)
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+
+	fmt.Fprintf(w, "; zone %s exported %s\n", origin, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "$ORIGIN %s.\n", origin)
+	fmt.Fprintf(w, "$TTL %d\n", defaultTTL)
+	if !slices.ContainsFunc(records, func(v cloudflare.DNSRecord) bool { return strings.EqualFold(v.Type, "SOA") }) {
+		fmt.Fprintf(w, "@\tIN\tSOA\t%s ; synthesized, the dns provider serves the real SOA\n", bindSoa(origin, defaultTTL, records))
+	}
+	for _, v := range sorted {
+		ttl := ""
+		if v.TTL > 1 {
+			ttl = fmt.Sprint(v.TTL)
+		}
+		line := fmt.Sprintf("%s\t%s\tIN\t%s\t%s", bindOwner(v.Name, origin), ttl, v.Type, bindRData(v))
+
+		// Parse the fully qualified form so a record the zone file cannot represent is kept as a
+		// comment instead of breaking the whole file.
+		fqdnLine := fmt.Sprintf("%s. %d IN %s %s", dnsprovider.TrimDot(v.Name), max(v.TTL, 1), v.Type, bindRData(v))
+		if note := bindComment(v); note != "" {
+			line += "\t; " + note
+		}
+		if _, err := dns.NewRR(fqdnLine); err != nil {
+			line = fmt.Sprintf("; %s\n; record above is not valid in a zone file: %s", line, err.Error())
+		}
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindSoa returns SOA rdata naming the first apex nameserver of records, with a serial from the
+// current time and defaultTTL as the negative caching TTL.
+func bindSoa(origin string, defaultTTL int, records []cloudflare.DNSRecord) string {
+	mname := "ns1." + origin
+	nameServers := []string{}
+	for _, v := range records {
+		if strings.EqualFold(v.Type, "NS") && dnsprovider.TrimDot(v.Name) == origin {
+			nameServers = append(nameServers, dnsprovider.TrimDot(v.Content))
+		}
+	}
+	if len(nameServers) > 0 {
+		slices.Sort(nameServers)
+		mname = nameServers[0]
+	}
+	serial := time.Now().UTC().Format("2006010215")
+	return fmt.Sprintf("%s. hostmaster.%s. %s 10800 3600 604800 %d", mname, origin, serial, defaultTTL)
+}
+
+// bindOwner returns name relative to origin, @ for the apex.
+func bindOwner(name string, origin string) string {
+	name = dnsprovider.TrimDot(name)
+	switch {
+	case strings.EqualFold(name, origin):
+		return "@"
+	case dnsprovider.InZone(name, origin):
+		return name[:len(name)-len(origin)-1]
+	}
+	return name + "."
+}
+
+func bindRData(record cloudflare.DNSRecord) string {
+	content := record.Content
+	fields := strings.Fields(content)
+	priority := uint16(0)
+	if record.Priority != nil {
+		priority = *record.Priority
+	}
+	switch strings.ToUpper(record.Type) {
+	case "CNAME", "NS", "PTR", "DNAME":
+		return bindFqdn(content)
+	case "MX":
+		if len(fields) == 1 {
+			return fmt.Sprintf("%d %s", priority, bindFqdn(fields[0]))
+		}
+		if len(fields) == 2 {
+			return fmt.Sprintf("%s %s", fields[0], bindFqdn(fields[1]))
+		}
+	case "SRV":
+		if len(fields) == 3 {
+			return fmt.Sprintf("%d %s %s %s", priority, fields[0], fields[1], bindFqdn(fields[2]))
+		}
+		if len(fields) == 4 {
+			return fmt.Sprintf("%s %s %s %s", fields[0], fields[1], fields[2], bindFqdn(fields[3]))
+		}
+	case "TXT", "SPF":
+		return bindTxt(content)
+	}
+	return content
+}
+
+func bindFqdn(name string) string {
+	if name == "." || name == "" {
+		return "."
+	}
+	return dnsprovider.TrimDot(name) + "."
+}
+
+// bindTxt quotes TXT content, splitting it into 255 byte strings. Content the API already
+// returns quoted is written as is.
+func bindTxt(content string) string {
+	if strings.HasPrefix(content, "\"") && strings.HasSuffix(content, "\"") && len(content) > 1 {
+		return content
+	}
+	parts := []string{}
+	for len(content) > 255 {
+		parts = append(parts, content[:255])
+		content = content[255:]
+	}
+	parts = append(parts, content)
+	for i, v := range parts {
+		v = strings.ReplaceAll(v, "\\", "\\\\")
+		parts[i] = "\"" + strings.ReplaceAll(v, "\"", "\\\"") + "\""
+	}
+	return strings.Join(parts, " ")
+}
+
+func bindComment(record cloudflare.DNSRecord) string {
+	notes := []string{}
+	if record.Comment != "" {
+		notes = append(notes, strings.Join(strings.Fields(record.Comment), " "))
+	}
+	if len(record.Tags) > 0 {
+		notes = append(notes, "tags: "+strings.Join(record.Tags, ", "))
+	}
+	if record.Proxied != nil && *record.Proxied {
+		notes = append(notes, "proxied")
+	}
+	if record.TTL == 1 {
+		notes = append(notes, "ttl: auto")
+	}
+	return strings.Join(notes, " | ")
+}
+
+// DnsRecordsFromDb reads the records stored for the zone by --to-db.
+func (cfcmd *CloudflareCommandUtils) DnsRecordsFromDb() []cloudflare.DNSRecord {
+	records := []cloudflare.DNSRecord{}
+	if cfcmd.DbConn == nil {
+		cfcmd.InitializeDatabaseConnection()
+	}
+	queries := infracli_db.New(cfcmd.DbConn)
+	if cfcmd.ZomeId == "" {
+		cfcmd.ZomeId, cfcmd.Error = queries.GetZoneIdByDomainName(context.Background(), cfcmd.ZoneName)
+		if cfcmd.Error != nil {
+			cfcmd.Error = fmt.Errorf("zone %s not found in the local db: %w", cfcmd.ZoneName, cfcmd.Error)
+			return records
+		}
+	}
+
+	rows, err := queries.GetDnsRecordsByZoneUid(context.Background(), cfcmd.ZomeId)
+	if err != nil {
+		cfcmd.Error = err
+		return records
+	}
+	index := make(map[string]int)
+	for _, v := range rows {
+		i, ok := index[v.RecordUid]
+		if !ok {
+			i = len(records)
+			index[v.RecordUid] = i
+			records = append(records, cloudflare.DNSRecord{
+				ID:      v.RecordUid,
+				Name:    v.Name,
+				Type:    v.RecordType,
+				Content: v.Content.String,
+				TTL:     int(v.Ttl),
+			})
+			if v.Priority.Valid {
+				priority := uint16(v.Priority.Int64)
+				records[i].Priority = &priority
+			} else if v.RecordType == "MX" {
+				cfcmd.Error = fmt.Errorf("MX record %s has no priority in the local db, store the zone again with list --to-db", v.Name)
+				return records
+			}
+		}
+		if v.Comment.String != "" {
+			records[i].Comment = v.Comment.String
+		}
+		for _, tag := range strings.Split(v.Tags.String, ",") {
+			if tag != "" && !slices.Contains(records[i].Tags, tag) {
+				records[i].Tags = append(records[i].Tags, tag)
+			}
+		}
+	}
+	return records
+}
+
+func zoneExportCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "export",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Export every record in the zone as a zone file.",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Value: ZoneFormatBind,
+				Usage: "Output format, only bind is supported.",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "File to write the zone to, stdout when not set.",
+			},
+			&cli.BoolFlag{
+				Name:  "from-db",
+				Usage: "Read the records from the local sqlite db instead of the dns provider.",
+			},
+			&cli.IntFlag{
+				Name:  "default-ttl",
+				Value: 3600,
+				Usage: "$TTL for the zone file, used by records with an automatic TTL.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			if cmd.String("format") != ZoneFormatBind {
+				err = fmt.Errorf("unsupported export format %q, use %s", cmd.String("format"), ZoneFormatBind)
+				logger.Error(err.Error())
+				return err
+			}
+
+			var cfcmd *CloudflareCommandUtils
+			var records []cloudflare.DNSRecord
+			if cmd.Bool("from-db") {
+				cfcmd = &CloudflareCommandUtils{EnvFile: cmd.String("env-file"), ZoneName: domainNameFromCli(cmd)}
+				records = cfcmd.DnsRecordsFromDb()
+				if cfcmd.DbConn != nil {
+					defer cfcmd.DbConn.Close()
+				}
+			} else {
+				cfcmd = dnsCommandFromCli(cmd)
+				if cfcmd.Error == nil {
+					records, _ = cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{})
+				}
+			}
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+
+			out := os.Stdout
+			if cmd.String("output") != "" {
+				out, err = os.Create(cmd.String("output"))
+				if err != nil {
+					logger.Error(err.Error())
+					return err
+				}
+				defer out.Close()
+			}
+			err = WriteBindZone(out, cfcmd.ZoneName, int(cmd.Int("default-ttl")), records)
+			if err != nil {
+				logger.Error(err.Error())
+				return err
+			}
+			if cmd.String("output") != "" {
+				logger.Info(fmt.Sprintf("Exported %d records in %s to %s", len(records), cfcmd.ZoneName, cmd.String("output")))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+// ZoneImport sorts the records parsed from a zone file against the live zone.
+type ZoneImport struct {
+	ZoneName  string                             `json:"zoneName"`
+	Create    []cloudflare.CreateDNSRecordParams `json:"create"`
+	Existing  int                                `json:"existing"`
+	Skipped   []string                           `json:"skipped"`
+	Conflicts []string                           `json:"conflicts"`
+}
+
+// ParseBindZone reads a master file, following $INCLUDE relative to path and qualifying relative
+// names with $ORIGIN, which starts as origin. SOA records are left to the provider and skipped.
+func ParseBindZone(path string, origin string) ([]cloudflare.CreateDNSRecordParams, []string, error) {
+	records := []cloudflare.CreateDNSRecordParams{}
+	skipped := []string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return records, skipped, err
+	}
+	defer f.Close()
+
+	zp := dns.NewZoneParser(f, dns.Fqdn(dnsprovider.TrimDot(origin)), path)
+	zp.SetIncludeAllowed(true)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			skipped = append(skipped, fmt.Sprintf("%s SOA is managed by the dns provider", rr.Header().Name))
+			continue
+		}
+		record := createParamsFromRR(rr)
+		applyBindComment(&record, zp.Comment())
+		records = append(records, record)
+	}
+	if err := zp.Err(); err != nil {
+		return records, skipped, err
+	}
+	return records, skipped, nil
+}
+
+func createParamsFromRR(rr dns.RR) cloudflare.CreateDNSRecordParams {
+	hdr := rr.Header()
+	record := cloudflare.CreateDNSRecordParams{
+		Name: dnsprovider.TrimDot(hdr.Name),
+		Type: dns.TypeToString[hdr.Rrtype],
+		TTL:  int(hdr.Ttl),
+	}
+	switch v := rr.(type) {
+	case *dns.A:
+		record.Content = v.A.String()
+	case *dns.AAAA:
+		record.Content = v.AAAA.String()
+	case *dns.CNAME:
+		record.Content = dnsprovider.TrimDot(v.Target)
+	case *dns.NS:
+		record.Content = dnsprovider.TrimDot(v.Ns)
+	case *dns.PTR:
+		record.Content = dnsprovider.TrimDot(v.Ptr)
+	case *dns.MX:
+		priority := v.Preference
+		record.Priority = &priority
+		record.Content = dnsprovider.TrimDot(v.Mx)
+	case *dns.SRV:
+		priority := v.Priority
+		record.Priority = &priority
+		record.Content = fmt.Sprintf("%d %d %s", v.Weight, v.Port, dnsprovider.TrimDot(v.Target))
+	case *dns.TXT:
+		parts := make([]string, 0, len(v.Txt))
+		for _, txt := range v.Txt {
+			parts = append(parts, unescapeTxt(txt))
+		}
+		record.Content = strings.Join(parts, "")
+	default:
+		record.Content = strings.TrimSpace(strings.TrimPrefix(rr.String(), hdr.String()))
+	}
+	return record
+}
+
+// unescapeTxt undoes the \" \\ and \DDD escapes the zone parser keeps in TXT strings.
+func unescapeTxt(txt string) string {
+	if !strings.Contains(txt, "\\") {
+		return txt
+	}
+	out := strings.Builder{}
+	for i := 0; i < len(txt); i++ {
+		if txt[i] != '\\' || i+1 == len(txt) {
+			out.WriteByte(txt[i])
+			continue
+		}
+		if i+3 < len(txt) && isDigits(txt[i+1:i+4]) {
+			n, _ := strconv.Atoi(txt[i+1 : i+4])
+			out.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		i++
+		out.WriteByte(txt[i])
+	}
+	return out.String()
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// applyBindComment reads back the comment, tags, proxied and auto TTL notes WriteBindZone adds
+// to a record.
+func applyBindComment(record *cloudflare.CreateDNSRecordParams, comment string) {
+	comment = strings.TrimSpace(strings.TrimPrefix(comment, ";"))
+	if comment == "" {
+		return
+	}
+	notes := []string{}
+	for _, note := range strings.Split(comment, " | ") {
+		switch {
+		case strings.HasPrefix(note, "tags: "):
+			for _, tag := range strings.Split(strings.TrimPrefix(note, "tags: "), ",") {
+				record.Tags = append(record.Tags, strings.TrimSpace(tag))
+			}
+		case note == "proxied":
+			proxied := true
+			record.Proxied = &proxied
+		case note == "ttl: auto":
+			record.TTL = 1
+		default:
+			notes = append(notes, note)
+		}
+	}
+	record.Comment = strings.Join(notes, " | ")
+}
+
+// PlanZoneImport compares parsed records to the live zone. Identical records are skipped, the
+// same record with another TTL or priority, or a CNAME sharing its name with other records, is a
+// conflict and left alone. Records outside the zone and the apex NS set are skipped.
+func (cfcmd *CloudflareCommandUtils) PlanZoneImport(records []cloudflare.CreateDNSRecordParams, skipped []string) ZoneImport {
+	result := ZoneImport{ZoneName: cfcmd.ZoneName, Create: []cloudflare.CreateDNSRecordParams{}, Skipped: skipped, Conflicts: []string{}}
+	live, _ := cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{})
+	if cfcmd.Error != nil {
+		return result
+	}
+
+	liveByKey := make(map[string]cloudflare.DNSRecord)
+	typesByName := make(map[string][]string)
+	for _, v := range live {
+		liveByKey[planKey(v.Name, v.Type, v.Content)] = v
+		name := strings.ToLower(dnsprovider.TrimDot(v.Name))
+		typesByName[name] = append(typesByName[name], strings.ToUpper(v.Type))
+	}
+	seen := make(map[string]bool)
+	for _, v := range records {
+		desc := fmt.Sprintf("%s %s %s", v.Name, v.Type, v.Content)
+		name := strings.ToLower(v.Name)
+		if !dnsprovider.InZone(v.Name, cfcmd.ZoneName) {
+			result.Skipped = append(result.Skipped, desc+" is outside zone "+cfcmd.ZoneName)
+			continue
+		}
+		if v.Type == "NS" && strings.EqualFold(v.Name, dnsprovider.TrimDot(cfcmd.ZoneName)) {
+			result.Skipped = append(result.Skipped, desc+" apex NS is managed by the dns provider")
+			continue
+		}
+		key := planKey(v.Name, v.Type, v.Content)
+		if seen[key] {
+			result.Skipped = append(result.Skipped, desc+" is repeated in the zone file")
+			continue
+		}
+		seen[key] = true
+
+		if existing, ok := liveByKey[key]; ok {
+			switch {
+			case existing.TTL != v.TTL && existing.TTL != 1:
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s exists with ttl %d, zone file has %d", desc, existing.TTL, v.TTL))
+			case v.Priority != nil && (existing.Priority == nil || *existing.Priority != *v.Priority):
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s exists with another priority", desc))
+			default:
+				result.Existing++
+			}
+			continue
+		}
+		liveTypes := typesByName[name]
+		if len(liveTypes) > 0 && (v.Type == "CNAME" || slices.Contains(liveTypes, "CNAME")) {
+			result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s conflicts with the %s records at %s", desc, strings.Join(liveTypes, ", "), v.Name))
+			continue
+		}
+		result.Create = append(result.Create, v)
+		typesByName[name] = append(typesByName[name], v.Type)
+	}
+	return result
+}
+
+// ImportedRecords shows the records to create in the dns record table layout.
+func (z *ZoneImport) ImportedRecords() []cloudflare.DNSRecord {
+	records := []cloudflare.DNSRecord{}
+	for _, v := range z.Create {
+		records = append(records, cloudflare.DNSRecord{Name: v.Name, Type: v.Type, Content: v.Content, TTL: v.TTL, Priority: v.Priority, Proxied: v.Proxied, Comment: v.Comment, Tags: v.Tags})
+	}
+	return records
+}
+
+func zoneImportCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "import",
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "Create the records from a BIND zone file, e.g. import zone.db",
+		ArgsUsage:             "<zone file>",
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the records that would be created without creating them.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			if cmd.NArg() == 0 {
+				err = fmt.Errorf("zone file argument is required")
+				logger.Error(err.Error())
+				return err
+			}
+			cfcmd := dnsCommandFromCli(cmd)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+
+			records, skipped, err := ParseBindZone(cmd.Args().Get(0), cfcmd.ZoneName)
+			if err != nil {
+				logger.Error(fmt.Sprintf("error parsing zone file: %s", err.Error()))
+				return err
+			}
+			result := cfcmd.PlanZoneImport(records, skipped)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			for _, v := range result.Skipped {
+				logger.Info("skipped " + v)
+			}
+			for _, v := range result.Conflicts {
+				logger.Warning("conflict " + v)
+			}
+
+			if cmd.Bool("dry-run") {
+				if cmd.Bool("print-json") {
+					cfcmd.PrintCommandResultAsJson(result)
+					return nil
+				}
+				cfcmd.PrintDnsRecordsTable(result.ImportedRecords())
+				pretty.Printf("Dry run for %s: %d to create, %d already exist, %d conflicts, %d skipped.", result.ZoneName, len(result.Create), result.Existing, len(result.Conflicts), len(result.Skipped))
+				return nil
+			}
+
+			created := []cloudflare.DNSRecord{}
+			failed := 0
+			for _, v := range result.Create {
+				record := cfcmd.CreateOrUpdateDNSRecord(v)
+				if cfcmd.Error != nil {
+					failed++
+					continue
+				}
+				created = append(created, record)
+			}
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(created)
+			} else {
+				cfcmd.PrintDnsRecordsTable(created)
+			}
+			pretty.Printf("Imported %d records into %s, %d already existed, %d conflicts, %d skipped, %d failed.", len(created), result.ZoneName, result.Existing, len(result.Conflicts), len(result.Skipped), failed)
+			if failed > 0 {
+				return fmt.Errorf("%d of %d records failed to import", failed, len(result.Create))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
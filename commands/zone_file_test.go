@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// newTestDb returns a sqlite db in the test's temp dir with the up migrations applied.
+func newTestDb(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "infracli.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrations, _ := filepath.Glob("../migrations/*.sql")
+	slices.Sort(migrations)
+	for _, path := range migrations {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("migration %s: %v", path, err)
+		}
+	}
+	return db
+}
+
+// writeTestZone exports records for origin and returns the path of the zone file.
+func writeTestZone(t *testing.T, origin string, records []cloudflare.DNSRecord) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := WriteBindZone(buf, origin, 3600, records); err != nil {
+		t.Fatalf("WriteBindZone: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "zone.db")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBindZoneRoundTrip(t *testing.T) {
+	proxied, priority, srvPriority := true, uint16(10), uint16(5)
+	long := strings.Repeat("k", 300)
+	records := []cloudflare.DNSRecord{
+		{Name: "example.com", Type: "NS", Content: "ns2.example.net", TTL: 86400},
+		{Name: "example.com", Type: "NS", Content: "ns1.example.net", TTL: 86400},
+		{Name: "example.com", Type: "MX", Content: "mail.example.com", TTL: 300, Priority: &priority},
+		{Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 300, Proxied: &proxied, Comment: "web  front\nend", Tags: []string{"env:prod", "team:web"}},
+		{Name: "api.example.com", Type: "CNAME", Content: "lb.example.net", TTL: 600, Comment: "load balancer"},
+		{Name: "auto.example.com", Type: "A", Content: "192.0.2.5", TTL: 1, Comment: "automatic ttl"},
+		{Name: "dkim._domainkey.example.com", Type: "TXT", Content: long, TTL: 300},
+		{Name: "quote.example.com", Type: "TXT", Content: `say "hi" \o/`, TTL: 300},
+		{Name: "_sip._tcp.example.com", Type: "SRV", Content: "20 5060 sip.example.com", TTL: 300, Priority: &srvPriority},
+	}
+	path := writeTestZone(t, "example.com", records)
+	data, _ := os.ReadFile(path)
+	zone := string(data)
+	if !strings.Contains(zone, "@\tIN\tSOA\tns1.example.net. hostmaster.example.com. ") {
+		t.Fatalf("expected an SOA naming the first apex nameserver, got\n%s", zone)
+	}
+	if !strings.Contains(zone, "\nwww\t") || !strings.Contains(zone, "\n@\t") {
+		t.Fatalf("expected owners relative to the origin, got\n%s", zone)
+	}
+
+	parsed, skipped, err := ParseBindZone(path, "example.com")
+	if err != nil {
+		t.Fatalf("ParseBindZone: %v\n%s", err, zone)
+	}
+	if len(skipped) != 1 || !strings.Contains(skipped[0], "SOA") {
+		t.Fatalf("expected only the synthesized SOA skipped, got %v", skipped)
+	}
+	if len(parsed) != len(records) {
+		t.Fatalf("expected %d records back, got %d\n%s", len(records), len(parsed), zone)
+	}
+	byKey := make(map[string]cloudflare.CreateDNSRecordParams)
+	for _, v := range parsed {
+		byKey[planKey(v.Name, v.Type, v.Content)] = v
+	}
+	for _, want := range records {
+		got, ok := byKey[planKey(want.Name, want.Type, want.Content)]
+		if !ok {
+			t.Fatalf("record %s %s %q did not survive the round trip\n%s", want.Name, want.Type, want.Content, zone)
+		}
+		if got.TTL != want.TTL {
+			t.Fatalf("%s %s: expected ttl %d, got %d", want.Name, want.Type, want.TTL, got.TTL)
+		}
+		if want.Priority != nil && (got.Priority == nil || *got.Priority != *want.Priority) {
+			t.Fatalf("%s %s lost its priority", want.Name, want.Type)
+		}
+		if (want.Proxied != nil) != (got.Proxied != nil) || !slices.Equal(want.Tags, got.Tags) {
+			t.Fatalf("%s %s: expected proxied %v and tags %v, got %v and %v", want.Name, want.Type, want.Proxied, want.Tags, got.Proxied, got.Tags)
+		}
+		if strings.Join(strings.Fields(want.Comment), " ") != got.Comment {
+			t.Fatalf("%s %s: expected comment %q, got %q", want.Name, want.Type, want.Comment, got.Comment)
+		}
+	}
+}
+
+func TestDnsRecordsFromDbKeepsPriority(t *testing.T) {
+	priority := uint16(20)
+	cfcmd := &CloudflareCommandUtils{ZomeId: "zone1", ZoneName: "example.com", DbConn: newTestDb(t)}
+	cfcmd.CreateDnsDbRecords([]cloudflare.DNSRecord{
+		{ID: "rec1", Name: "example.com", Type: "MX", Content: "mail.example.com", TTL: 300, Priority: &priority},
+		{ID: "rec2", Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 300, Comment: "web", Tags: []string{"env:prod"}},
+	})
+
+	records := cfcmd.DnsRecordsFromDb()
+	if cfcmd.Error != nil {
+		t.Fatalf("DnsRecordsFromDb: %v", cfcmd.Error)
+	}
+	buf := &bytes.Buffer{}
+	WriteBindZone(buf, "example.com", 3600, records)
+	if !strings.Contains(buf.String(), "MX\t20 mail.example.com.") {
+		t.Fatalf("expected the stored MX priority in the export, got\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "; web | tags: env:prod") {
+		t.Fatalf("expected the stored comment and tags in the export, got\n%s", buf.String())
+	}
+
+	if _, err := cfcmd.DbConn.Exec("UPDATE dns_records SET priority = NULL WHERE record_uid = 'rec1'"); err != nil {
+		t.Fatal(err)
+	}
+	cfcmd.DnsRecordsFromDb()
+	if cfcmd.Error == nil || !strings.Contains(cfcmd.Error.Error(), "no priority") {
+		t.Fatalf("expected an MX stored without a priority to be refused, got %v", cfcmd.Error)
+	}
+}
+
+func TestParseBindZoneIncludeAndOrigin(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "hosts.inc"), []byte("db1 300 IN A 192.0.2.10\n"), 0o600)
+	zone := `$TTL 600
+@ IN SOA ns1.example.com. hostmaster.example.com. 1 10800 3600 604800 3600
+www IN A 192.0.2.1
+$INCLUDE hosts.inc
+$ORIGIN lab.example.com.
+gw 120 IN A 192.0.2.20 ; lab gateway
+$INCLUDE hosts.inc
+$ORIGIN example.com.
+mail IN MX 10 mx
+`
+	path := filepath.Join(dir, "zone.db")
+	os.WriteFile(path, []byte(zone), 0o600)
+
+	records, skipped, err := ParseBindZone(path, "example.com")
+	if err != nil {
+		t.Fatalf("ParseBindZone: %v", err)
+	}
+	if len(skipped) != 1 {
+		t.Fatalf("expected the SOA skipped, got %v", skipped)
+	}
+	got := []string{}
+	for _, v := range records {
+		got = append(got, fmt.Sprintf("%s %d %s %s %s", v.Name, v.TTL, v.Type, v.Content, v.Comment))
+	}
+	want := []string{
+		"www.example.com 600 A 192.0.2.1 ",
+		"db1.example.com 300 A 192.0.2.10 ",
+		"gw.lab.example.com 120 A 192.0.2.20 lab gateway",
+		"db1.lab.example.com 300 A 192.0.2.10 ",
+		"mail.example.com 600 MX mx.example.com ",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected records\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	os.WriteFile(path, []byte("$INCLUDE missing.inc\n"), 0o600)
+	if _, _, err := ParseBindZone(path, "example.com"); err == nil {
+		t.Fatal("expected an error for a missing include")
+	}
+}
+
+func TestPlanZoneImport(t *testing.T) {
+	priority, otherPriority := uint16(10), uint16(20)
+	cfcmd := newTestCommand(newTestProvider("example.com",
+		dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300},
+		dnsprovider.Record{Name: "auto", Type: "A", Content: "192.0.2.2", TTL: 1},
+		dnsprovider.Record{Name: "@", Type: "MX", Content: "mail.example.com", TTL: 300, Priority: &priority},
+		dnsprovider.Record{Name: "alias", Type: "CNAME", Content: "www.example.com", TTL: 300},
+		dnsprovider.Record{Name: "txt", Type: "TXT", Content: "hello", TTL: 300},
+	))
+	records := []cloudflare.CreateDNSRecordParams{
+		{Name: "alias.example.com", Type: "CNAME", Content: "www.example.com.", TTL: 300},
+		{Name: "auto.example.com", Type: "A", Content: "192.0.2.2", TTL: 3600},
+		{Name: "www.example.com", Type: "A", Content: "192.0.2.1", TTL: 600},
+		{Name: "example.com", Type: "MX", Content: "mail.example.com", TTL: 300, Priority: &otherPriority},
+		{Name: "alias.example.com", Type: "A", Content: "192.0.2.3", TTL: 300},
+		{Name: "txt.example.com", Type: "CNAME", Content: "www.example.com", TTL: 300},
+		{Name: "example.com", Type: "NS", Content: "ns1.example.net", TTL: 300},
+		{Name: "www.example.org", Type: "A", Content: "192.0.2.4", TTL: 300},
+		{Name: "new.example.com", Type: "A", Content: "192.0.2.5", TTL: 300},
+		{Name: "new.example.com", Type: "A", Content: "192.0.2.5", TTL: 300},
+		{Name: "new.example.com", Type: "CNAME", Content: "www.example.com", TTL: 300},
+	}
+
+	result := cfcmd.PlanZoneImport(records, nil)
+	if cfcmd.Error != nil {
+		t.Fatalf("PlanZoneImport: %v", cfcmd.Error)
+	}
+	if len(result.Create) != 1 || result.Create[0].Name != "new.example.com" || result.Create[0].Type != "A" {
+		t.Fatalf("expected only the new A record created, got %+v", result.Create)
+	}
+	if result.Existing != 2 {
+		t.Fatalf("expected the identical CNAME and the auto ttl record to exist, got %d", result.Existing)
+	}
+	wantConflicts := []string{"exists with ttl 300, zone file has 600", "exists with another priority", "conflicts with the CNAME records at alias.example.com", "conflicts with the TXT records at txt.example.com", "conflicts with the A records at new.example.com"}
+	if len(result.Conflicts) != len(wantConflicts) {
+		t.Fatalf("expected %d conflicts, got %q", len(wantConflicts), result.Conflicts)
+	}
+	for i, want := range wantConflicts {
+		if !strings.Contains(result.Conflicts[i], want) {
+			t.Fatalf("expected conflict %d to mention %q, got %q", i, want, result.Conflicts[i])
+		}
+	}
+	wantSkipped := []string{"apex NS", "outside zone", "repeated"}
+	if len(result.Skipped) != len(wantSkipped) {
+		t.Fatalf("expected %d skipped, got %q", len(wantSkipped), result.Skipped)
+	}
+	for i, want := range wantSkipped {
+		if !strings.Contains(result.Skipped[i], want) {
+			t.Fatalf("expected skipped %d to mention %q, got %q", i, want, result.Skipped[i])
+		}
+	}
+}
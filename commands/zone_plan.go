@@ -0,0 +1,400 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/internal/pretty"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PlanCreate = "create"
+	PlanUpdate = "update"
+	PlanDelete = "delete"
+)
+
+// ZoneConfig is the desired state of a zone read from YAML. Record names may be relative to Zone.
+type ZoneConfig struct {
+	Zone    string             `yaml:"zone" json:"zone"`
+	Records []ZoneConfigRecord `yaml:"records" json:"records"`
+}
+
+type ZoneConfigRecord struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     string   `yaml:"type" json:"type"`
+	Content  string   `yaml:"content" json:"content"`
+	TTL      int      `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+	Priority *uint16  `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Proxied  *bool    `yaml:"proxied,omitempty" json:"proxied,omitempty"`
+	Comment  string   `yaml:"comment,omitempty" json:"comment,omitempty"`
+	Tags     []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+type PlanChange struct {
+	Action  string                `json:"action"`
+	Name    string                `json:"name"`
+	Type    string                `json:"type"`
+	Content string                `json:"content"`
+	Before  *cloudflare.DNSRecord `json:"before,omitempty"`
+	After   *ZoneConfigRecord     `json:"after,omitempty"`
+	Diff    []string              `json:"diff,omitempty"`
+}
+
+type ZonePlan struct {
+	ZoneName  string       `json:"zoneName"`
+	ZoneID    string       `json:"zoneId"`
+	Prune     bool         `json:"prune"`
+	Changes   []PlanChange `json:"changes"`
+	Unchanged int          `json:"unchanged"`
+	Unmanaged int          `json:"unmanaged"`
+}
+
+func LoadZoneConfig(path string) (ZoneConfig, error) {
+	config := ZoneConfig{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("error parsing zone config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (z *ZonePlan) Count(action string) int {
+	count := 0
+	for _, change := range z.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// normalizeContent makes live and desired content comparable: TXT without quotes and host
+// names without the trailing dot or case differences.
+func normalizeContent(recordType string, content string) string {
+	switch strings.ToUpper(recordType) {
+	case "TXT":
+		return strings.Trim(content, "\"")
+	case "CNAME", "NS", "MX", "PTR", "SRV":
+		return strings.ToLower(strings.TrimSuffix(content, "."))
+	}
+	return content
+}
+
+func planKey(name string, recordType string, content string) string {
+	return dnsprovider.TrimDot(name) + "|" + strings.ToUpper(recordType) + "|" + normalizeContent(recordType, content)
+}
+
+func rrsetKey(name string, recordType string) string {
+	return dnsprovider.TrimDot(name) + "|" + strings.ToUpper(recordType)
+}
+
+// recordDiff lists the fields set on desired that differ from live. Unset fields are not managed.
+func recordDiff(desired ZoneConfigRecord, live cloudflare.DNSRecord) []string {
+	diff := []string{}
+	if normalizeContent(desired.Type, desired.Content) != normalizeContent(live.Type, live.Content) {
+		diff = append(diff, fmt.Sprintf("content %s -> %s", live.Content, desired.Content))
+	}
+	if desired.TTL != 0 && desired.TTL != live.TTL {
+		diff = append(diff, fmt.Sprintf("ttl %d -> %d", live.TTL, desired.TTL))
+	}
+	if desired.Priority != nil && (live.Priority == nil || *live.Priority != *desired.Priority) {
+		before := "none"
+		if live.Priority != nil {
+			before = fmt.Sprint(*live.Priority)
+		}
+		diff = append(diff, fmt.Sprintf("priority %s -> %d", before, *desired.Priority))
+	}
+	if desired.Proxied != nil && (live.Proxied == nil || *live.Proxied != *desired.Proxied) {
+		diff = append(diff, fmt.Sprintf("proxied -> %t", *desired.Proxied))
+	}
+	if desired.Comment != "" && desired.Comment != live.Comment {
+		diff = append(diff, fmt.Sprintf("comment %q -> %q", live.Comment, desired.Comment))
+	}
+	if desired.Tags != nil {
+		want, have := slices.Clone(desired.Tags), slices.Clone(live.Tags)
+		slices.Sort(want)
+		slices.Sort(have)
+		if !slices.Equal(want, have) {
+			diff = append(diff, fmt.Sprintf("tags [%s] -> [%s]", strings.Join(have, ","), strings.Join(want, ",")))
+		}
+	}
+	return diff
+}
+
+// PlanZone diffs config against the live records of the zone. Records match on name, type and
+// content; leftover desired and live records with the same name and type become updates. Live
+// records that still do not match are deleted with prune and left alone otherwise. The zone
+// apex NS records are never pruned.
+func (cfcmd *CloudflareCommandUtils) PlanZone(config ZoneConfig, prune bool) ZonePlan {
+	plan := ZonePlan{ZoneName: cfcmd.ZoneName, ZoneID: cfcmd.ZomeId, Prune: prune, Changes: []PlanChange{}}
+
+	desired := make([]ZoneConfigRecord, 0, len(config.Records))
+	seen := make(map[string]bool)
+	for _, v := range config.Records {
+		if v.Name == "" || v.Type == "" || v.Content == "" {
+			cfcmd.Error = fmt.Errorf("zone config record %+v needs a name, type and content", v)
+			return plan
+		}
+		v.Name = dnsprovider.QualifyName(v.Name, cfcmd.ZoneName)
+		v.Type = strings.ToUpper(v.Type)
+		key := planKey(v.Name, v.Type, v.Content)
+		if seen[key] {
+			cfcmd.Error = fmt.Errorf("duplicate record %s %s %s in zone config", v.Name, v.Type, v.Content)
+			return plan
+		}
+		seen[key] = true
+		desired = append(desired, v)
+	}
+
+	live, _ := cfcmd.ListDNSRecords(cloudflare.ListDNSRecordsParams{})
+	if cfcmd.Error != nil {
+		return plan
+	}
+	sort.Slice(live, func(i, j int) bool {
+		return planKey(live[i].Name, live[i].Type, live[i].Content) < planKey(live[j].Name, live[j].Type, live[j].Content)
+	})
+
+	liveByKey := make(map[string]int)
+	for i, v := range live {
+		liveByKey[planKey(v.Name, v.Type, v.Content)] = i
+	}
+	matched := make([]bool, len(live))
+	unmatched := []ZoneConfigRecord{}
+	for _, v := range desired {
+		i, ok := liveByKey[planKey(v.Name, v.Type, v.Content)]
+		if !ok {
+			unmatched = append(unmatched, v)
+			continue
+		}
+		matched[i] = true
+		diff := recordDiff(v, live[i])
+		if len(diff) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		plan.Changes = append(plan.Changes, PlanChange{Action: PlanUpdate, Name: v.Name, Type: v.Type, Content: v.Content, Before: &live[i], After: &v, Diff: diff})
+	}
+
+	for _, v := range unmatched {
+		paired := -1
+		for i, l := range live {
+			if !matched[i] && rrsetKey(l.Name, l.Type) == rrsetKey(v.Name, v.Type) {
+				paired = i
+				break
+			}
+		}
+		if paired < 0 {
+			plan.Changes = append(plan.Changes, PlanChange{Action: PlanCreate, Name: v.Name, Type: v.Type, Content: v.Content, After: &v})
+			continue
+		}
+		matched[paired] = true
+		plan.Changes = append(plan.Changes, PlanChange{Action: PlanUpdate, Name: v.Name, Type: v.Type, Content: v.Content, Before: &live[paired], After: &v, Diff: recordDiff(v, live[paired])})
+	}
+
+	for i, l := range live {
+		if matched[i] {
+			continue
+		}
+		apexNS := strings.EqualFold(l.Type, "NS") && dnsprovider.TrimDot(l.Name) == dnsprovider.TrimDot(cfcmd.ZoneName)
+		if !prune || apexNS {
+			plan.Unmanaged++
+			continue
+		}
+		plan.Changes = append(plan.Changes, PlanChange{Action: PlanDelete, Name: l.Name, Type: l.Type, Content: l.Content, Before: &live[i]})
+	}
+	return plan
+}
+
+// ApplyZonePlan runs deletes first, so a CNAME can replace other records at the same name, then
+// updates and creates. A failed change is logged and the rest are still applied; cfcmd.Error
+// reports how many failed.
+func (cfcmd *CloudflareCommandUtils) ApplyZonePlan(plan ZonePlan) (applied int, failed int) {
+	for _, action := range []string{PlanDelete, PlanUpdate, PlanCreate} {
+		for _, change := range plan.Changes {
+			if change.Action != action {
+				continue
+			}
+			cfcmd.Error = nil
+			switch change.Action {
+			case PlanDelete:
+				cfcmd.DeleteCloudflareRecord(change.Before.ID)
+			case PlanUpdate:
+				params := cloudflare.UpdateDNSRecordParams{
+					ID:       change.Before.ID,
+					Name:     change.After.Name,
+					Type:     change.After.Type,
+					Content:  change.After.Content,
+					TTL:      change.After.TTL,
+					Priority: change.After.Priority,
+					Proxied:  change.After.Proxied,
+					Tags:     change.Before.Tags,
+				}
+				if change.After.Comment != "" {
+					params.Comment = &change.After.Comment
+				}
+				if change.After.Tags != nil {
+					params.Tags = change.After.Tags
+				}
+				cfcmd.CreateOrUpdateDNSRecord(params)
+			case PlanCreate:
+				cfcmd.CreateOrUpdateDNSRecord(cloudflare.CreateDNSRecordParams{
+					Name:     change.After.Name,
+					Type:     change.After.Type,
+					Content:  change.After.Content,
+					TTL:      change.After.TTL,
+					Priority: change.After.Priority,
+					Proxied:  change.After.Proxied,
+					Comment:  change.After.Comment,
+					Tags:     change.After.Tags,
+				})
+			}
+			if cfcmd.Error != nil {
+				logger.Error(fmt.Sprintf("error applying %s of %s %s %s: %s", change.Action, change.Name, change.Type, change.Content, cfcmd.Error.Error()))
+				failed++
+				continue
+			}
+			logger.Info(fmt.Sprintf("applied %s of %s %s %s", change.Action, change.Name, change.Type, change.Content))
+			applied++
+		}
+	}
+	cfcmd.Error = nil
+	if failed > 0 {
+		cfcmd.Error = fmt.Errorf("%d of %d zone changes failed", failed, applied+failed)
+	}
+	return applied, failed
+}
+
+// confirmDeletes asks on r whether to delete count records, anything but y or yes declines.
+func confirmDeletes(r io.Reader, zoneName string, count int) bool {
+	fmt.Printf("Delete %d records from %s? [y/N] ", count, zoneName)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func PrintZonePlan(plan ZonePlan) {
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\x1b[0m\n", 97, "Action", "Name", "Type", "Content", "Changes")
+	fmt.Fprintf(tw, "\x1b[1;%dm------\t----\t----\t-------\t-------\x1b[0m\n", 97)
+	for _, v := range plan.Changes {
+		var colorInt int32
+		symbol := ""
+		switch v.Action {
+		case PlanCreate:
+			colorInt, symbol = 92, "+"
+		case PlanUpdate:
+			colorInt, symbol = 93, "~"
+		case PlanDelete:
+			colorInt, symbol = 91, "-"
+		}
+		line := fmt.Sprintf("%s %s\t%s\t%s\t%s\t%s", symbol, v.Action, v.Name, v.Type, v.Content, strings.Join(v.Diff, ", "))
+		fmt.Fprintln(tw, pretty.PrettyStringWithColor("%s", colorInt, line))
+	}
+	tw.Flush()
+	fmt.Println()
+
+	summary := fmt.Sprintf("Plan for %s: %d to create, %d to update, %d to delete. %d unchanged", plan.ZoneName, plan.Count(PlanCreate), plan.Count(PlanUpdate), plan.Count(PlanDelete), plan.Unchanged)
+	if plan.Unmanaged > 0 {
+		summary += fmt.Sprintf(", %d unmanaged records left alone", plan.Unmanaged)
+	}
+	if len(plan.Changes) == 0 {
+		pretty.Print(summary + ". No changes.")
+		return
+	}
+	pretty.PrintWarningf("%s.", summary)
+}
+
+func zonePlanCommand(name string, apply bool) *cli.Command {
+	usage := "Show the changes needed to make the zone match a YAML zone config."
+	if apply {
+		usage = "Make the zone match a YAML zone config."
+	}
+	cmd := &cli.Command{
+		Name:                  name,
+		Version:               versionNumber,
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 usage,
+		EnableShellCompletion: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Aliases:  []string{"f", "zone-file"},
+				Required: true,
+				Usage:    "YAML zone config with the desired records.",
+			},
+			&cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Delete live records missing from the zone config. Without it unmanaged records are left alone.",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			config, err := LoadZoneConfig(cmd.String("file"))
+			if err != nil {
+				logger.Error(err.Error())
+				return err
+			}
+			zoneName := config.Zone
+			if zoneName == "" {
+				zoneName = domainNameFromCli(cmd)
+			}
+
+			cfcmd := dnsCommandForDomain(cmd, zoneName)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			plan := cfcmd.PlanZone(config, cmd.Bool("prune"))
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(plan)
+			} else {
+				PrintZonePlan(plan)
+			}
+			if !apply || len(plan.Changes) == 0 {
+				return nil
+			}
+			if deletes := plan.Count(PlanDelete); deletes > 0 && !cmd.Bool("yes") && !confirmDeletes(cmd.Root().Reader, plan.ZoneName, deletes) {
+				err = fmt.Errorf("apply cancelled, pass --yes to delete records without asking")
+				logger.Error(err.Error())
+				return err
+			}
+			applied, failed := cfcmd.ApplyZonePlan(plan)
+			if failed > 0 {
+				pretty.PrintErrorf("Applied %d of %d changes to %s, %d failed.", applied, applied+failed, plan.ZoneName, failed)
+				return cfcmd.Error
+			}
+			pretty.Printf("Applied %d changes to %s.", applied, plan.ZoneName)
+			return nil
+		},
+	}
+	if apply {
+		cmd.Flags = append(cmd.Flags, &cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"y"},
+			Usage:   "Apply deletes from --prune without asking for confirmation.",
+		})
+	}
+	return cmd
+}
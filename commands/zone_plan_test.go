@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+)
+
+// testProvider keeps the records of one zone in memory. fail, when set, is asked before every
+// change and its error is returned instead of applying it.
+type testProvider struct {
+	zone    dnsprovider.Zone
+	records []dnsprovider.Record
+	nextID  int
+	fail    func(action string, record dnsprovider.Record) error
+}
+
+func newTestProvider(zoneName string, records ...dnsprovider.Record) *testProvider {
+	p := &testProvider{zone: dnsprovider.Zone{ID: "zone1", Name: zoneName}}
+	for _, v := range records {
+		p.add(v)
+	}
+	return p
+}
+
+// newTestCommand returns command utils for the provider's zone without a database.
+func newTestCommand(p *testProvider) *CloudflareCommandUtils {
+	return &CloudflareCommandUtils{ZomeId: p.zone.ID, ZoneName: p.zone.Name, Provider: p}
+}
+
+func (p *testProvider) add(record dnsprovider.Record) dnsprovider.Record {
+	p.nextID++
+	record.ID = fmt.Sprintf("rec%d", p.nextID)
+	record.ZoneID, record.ZoneName = p.zone.ID, p.zone.Name
+	record.Name = dnsprovider.QualifyName(record.Name, p.zone.Name)
+	p.records = append(p.records, record)
+	return record
+}
+
+func (p *testProvider) check(action string, record dnsprovider.Record) error {
+	if p.fail == nil {
+		return nil
+	}
+	return p.fail(action, record)
+}
+
+func (p *testProvider) Name() string { return "test" }
+
+func (p *testProvider) ZoneForName(ctx context.Context, name string) (dnsprovider.Zone, error) {
+	if !dnsprovider.InZone(dnsprovider.TrimDot(name), p.zone.Name) {
+		return dnsprovider.Zone{}, fmt.Errorf("no zone contains %s", name)
+	}
+	return p.zone, nil
+}
+
+func (p *testProvider) ListRecords(ctx context.Context, zone dnsprovider.Zone, filter dnsprovider.RecordFilter) ([]dnsprovider.Record, error) {
+	records := []dnsprovider.Record{}
+	for _, v := range p.records {
+		if filter.Matches(v) {
+			records = append(records, v)
+		}
+	}
+	return records, nil
+}
+
+func (p *testProvider) GetRecord(ctx context.Context, zone dnsprovider.Zone, id string) (dnsprovider.Record, error) {
+	for _, v := range p.records {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return dnsprovider.Record{}, dnsprovider.ErrRecordNotFound
+}
+
+func (p *testProvider) CreateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	if err := p.check(PlanCreate, record); err != nil {
+		return dnsprovider.Record{}, err
+	}
+	return p.add(record), nil
+}
+
+func (p *testProvider) UpdateRecord(ctx context.Context, zone dnsprovider.Zone, record dnsprovider.Record) (dnsprovider.Record, error) {
+	if err := p.check(PlanUpdate, record); err != nil {
+		return dnsprovider.Record{}, err
+	}
+	for i, v := range p.records {
+		if v.ID != record.ID {
+			continue
+		}
+		if record.Content != "" {
+			v.Content = record.Content
+		}
+		if record.TTL != 0 {
+			v.TTL = record.TTL
+		}
+		if record.Comment != nil {
+			v.Comment = record.Comment
+		}
+		p.records[i] = v
+		return v, nil
+	}
+	return dnsprovider.Record{}, dnsprovider.ErrRecordNotFound
+}
+
+func (p *testProvider) DeleteRecord(ctx context.Context, zone dnsprovider.Zone, id string) error {
+	record, err := p.GetRecord(ctx, zone, id)
+	if err != nil {
+		return err
+	}
+	if err := p.check(PlanDelete, record); err != nil {
+		return err
+	}
+	p.records = slices.DeleteFunc(p.records, func(v dnsprovider.Record) bool { return v.ID == id })
+	return nil
+}
+
+func (p *testProvider) CreateTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string, ttl int) (dnsprovider.Record, error) {
+	return p.CreateRecord(ctx, zone, dnsprovider.Record{Name: fqdn, Type: "TXT", Content: value, TTL: ttl})
+}
+
+func (p *testProvider) DeleteTXTRecord(ctx context.Context, zone dnsprovider.Zone, fqdn string, value string) error {
+	for _, v := range p.records {
+		if v.Name == dnsprovider.TrimDot(fqdn) && v.Type == "TXT" && v.Content == value {
+			return p.DeleteRecord(ctx, zone, v.ID)
+		}
+	}
+	return nil
+}
+
+// planSummary lists the changes of plan as "action name type content", sorted.
+func planSummary(plan ZonePlan) []string {
+	summary := []string{}
+	for _, v := range plan.Changes {
+		summary = append(summary, strings.Join([]string{v.Action, v.Name, v.Type, v.Content}, " "))
+	}
+	slices.Sort(summary)
+	return summary
+}
+
+func TestPlanZone(t *testing.T) {
+	live := []dnsprovider.Record{
+		{Name: "@", Type: "NS", Content: "ns1.example.net", TTL: 86400},
+		{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300},
+		{Name: "www", Type: "A", Content: "192.0.2.2", TTL: 300},
+		{Name: "mail", Type: "CNAME", Content: "mx.example.net", TTL: 300},
+		{Name: "old", Type: "TXT", Content: "stale", TTL: 300},
+	}
+	tests := []struct {
+		name      string
+		records   []ZoneConfigRecord
+		prune     bool
+		want      []string
+		unchanged int
+		unmanaged int
+	}{
+		{
+			name:      "matching records are unchanged",
+			records:   []ZoneConfigRecord{{Name: "www", Type: "a", Content: "192.0.2.1"}, {Name: "www.example.com", Type: "A", Content: "192.0.2.2", TTL: 300}},
+			want:      []string{},
+			unchanged: 2,
+			unmanaged: 3,
+		},
+		{
+			name:      "content matches after normalizing",
+			records:   []ZoneConfigRecord{{Name: "mail", Type: "CNAME", Content: "MX.example.net."}},
+			want:      []string{},
+			unchanged: 1,
+			unmanaged: 4,
+		},
+		{
+			name:      "field changes on a matched record are updates",
+			records:   []ZoneConfigRecord{{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 600}},
+			want:      []string{"update www.example.com A 192.0.2.1"},
+			unmanaged: 4,
+		},
+		{
+			name:      "leftover desired and live records of an rrset pair into updates",
+			records:   []ZoneConfigRecord{{Name: "www", Type: "A", Content: "192.0.2.1"}, {Name: "www", Type: "A", Content: "192.0.2.3"}, {Name: "www", Type: "A", Content: "192.0.2.4"}},
+			want:      []string{"create www.example.com A 192.0.2.4", "update www.example.com A 192.0.2.3"},
+			unchanged: 1,
+			unmanaged: 3,
+		},
+		{
+			name:      "prune deletes unmatched records except the apex NS",
+			records:   []ZoneConfigRecord{{Name: "www", Type: "A", Content: "192.0.2.1"}, {Name: "api", Type: "A", Content: "192.0.2.9"}},
+			prune:     true,
+			want:      []string{"create api.example.com A 192.0.2.9", "delete mail.example.com CNAME mx.example.net", "delete old.example.com TXT stale", "delete www.example.com A 192.0.2.2"},
+			unchanged: 1,
+			unmanaged: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfcmd := newTestCommand(newTestProvider("example.com", live...))
+			plan := cfcmd.PlanZone(ZoneConfig{Records: tt.records}, tt.prune)
+			if cfcmd.Error != nil {
+				t.Fatalf("PlanZone: %v", cfcmd.Error)
+			}
+			if got := planSummary(plan); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected changes %q, got %q", tt.want, got)
+			}
+			if plan.Unchanged != tt.unchanged || plan.Unmanaged != tt.unmanaged {
+				t.Fatalf("expected %d unchanged and %d unmanaged, got %d and %d", tt.unchanged, tt.unmanaged, plan.Unchanged, plan.Unmanaged)
+			}
+		})
+	}
+}
+
+func TestPlanZoneRejectsInvalidConfig(t *testing.T) {
+	for _, records := range [][]ZoneConfigRecord{
+		{{Name: "www", Type: "A"}},
+		{{Name: "www", Type: "A", Content: "192.0.2.1"}, {Name: "www.example.com.", Type: "a", Content: "192.0.2.1"}},
+	} {
+		cfcmd := newTestCommand(newTestProvider("example.com"))
+		cfcmd.PlanZone(ZoneConfig{Records: records}, false)
+		if cfcmd.Error == nil {
+			t.Fatalf("expected an error for %+v", records)
+		}
+	}
+}
+
+func TestApplyZonePlanContinuesPastFailures(t *testing.T) {
+	p := newTestProvider("example.com",
+		dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300},
+		dnsprovider.Record{Name: "old", Type: "TXT", Content: "stale", TTL: 300},
+	)
+	p.fail = func(action string, record dnsprovider.Record) error {
+		if action == PlanCreate && record.Name == "bad.example.com" {
+			return fmt.Errorf("rejected")
+		}
+		return nil
+	}
+	cfcmd := newTestCommand(p)
+	plan := cfcmd.PlanZone(ZoneConfig{Records: []ZoneConfigRecord{
+		{Name: "www", Type: "A", Content: "192.0.2.5"},
+		{Name: "bad", Type: "A", Content: "192.0.2.6"},
+		{Name: "api", Type: "A", Content: "192.0.2.7"},
+	}}, true)
+	if cfcmd.Error != nil {
+		t.Fatalf("PlanZone: %v", cfcmd.Error)
+	}
+
+	applied, failed := cfcmd.ApplyZonePlan(plan)
+	if applied != 3 || failed != 1 {
+		t.Fatalf("expected 3 applied and 1 failed, got %d and %d", applied, failed)
+	}
+	if cfcmd.Error == nil || !strings.Contains(cfcmd.Error.Error(), "1 of 4") {
+		t.Fatalf("expected the failure count in the error, got %v", cfcmd.Error)
+	}
+	contents := []string{}
+	for _, v := range p.records {
+		contents = append(contents, v.Name+" "+v.Content)
+	}
+	slices.Sort(contents)
+	if !slices.Equal(contents, []string{"api.example.com 192.0.2.7", "www.example.com 192.0.2.5"}) {
+		t.Fatalf("expected the changes after the failure applied, got %v", contents)
+	}
+}
+
+func TestConfirmDeletes(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		if got := confirmDeletes(strings.NewReader(answer), "example.com", 2); got != want {
+			t.Fatalf("answer %q: expected %t, got %t", answer, want, got)
+		}
+	}
+}
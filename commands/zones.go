@@ -0,0 +1,190 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/internal/pretty"
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/urfave/cli/v3"
+)
+
+// ZoneRecords holds the records listed from one zone by list --all-zones.
+type ZoneRecords struct {
+	ZoneID   string                 `json:"zoneId"`
+	ZoneName string                 `json:"zoneName"`
+	Records  []cloudflare.DNSRecord `json:"records"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+func (z ZoneRecords) Zone() dnsprovider.Zone {
+	return dnsprovider.Zone{ID: z.ZoneID, Name: z.ZoneName}
+}
+
+// runParallel calls fn for 0..n-1 with at most workers calls running at once.
+func runParallel(n int, workers int, fn func(i int)) {
+	slots := make(chan struct{}, max(workers, 1))
+	wg := sync.WaitGroup{}
+	for i := range n {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+// forZone returns a copy of cfcmd working on zone. Copies share the provider and database
+// connection but report errors through their own Error field.
+func (cfcmd *CloudflareCommandUtils) forZone(zone dnsprovider.Zone) *CloudflareCommandUtils {
+	return &CloudflareCommandUtils{ZomeId: zone.ID, ZoneName: zone.Name, EnvFile: cfcmd.EnvFile, ApiClient: cfcmd.ApiClient, Provider: cfcmd.Provider, DbConn: cfcmd.DbConn, UseEnv: cfcmd.UseEnv, AllowUnaudited: cfcmd.AllowUnaudited}
+}
+
+// ListZones returns every zone the provider can reach with its record count.
+func (cfcmd *CloudflareCommandUtils) ListZones(ctx context.Context) []dnsprovider.ZoneInfo {
+	zones, err := dnsprovider.ListZones(ctx, cfcmd.Provider)
+	if err != nil {
+		cfcmd.Error = err
+		return nil
+	}
+	errs := make([]error, len(zones))
+	runParallel(len(zones), dnsprovider.DefaultPageConcurrency, func(i int) {
+		errs[i] = dnsprovider.DescribeZone(ctx, cfcmd.Provider, &zones[i])
+	})
+	for i, err := range errs {
+		if err != nil {
+			logger.Warning(fmt.Sprintf("error counting records in %s: %s", zones[i].Name, err.Error()))
+		}
+	}
+	return zones
+}
+
+func PrintZonesTable(zones []dnsprovider.ZoneInfo) error {
+	var colorInt int32 = 97
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dmZone\tID\tStatus\tPlan\tNameServers\tRecords\x1b[0m\n", colorInt)
+	fmt.Fprintf(tw, "\x1b[1;%dm----\t--\t------\t----\t-----------\t-------\x1b[0m\n", colorInt)
+	for _, v := range zones {
+		switch v.Status {
+		case "active":
+			colorInt = 92
+		case "pending", "initializing":
+			colorInt = 93
+		case "moved", "deleted":
+			colorInt = 91
+		default:
+			colorInt = 97
+		}
+		fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%d\x1b[0m\n", colorInt, v.Name, v.ID, v.Status, v.Plan, strings.Join(v.NameServers, ", "), v.RecordCount)
+	}
+	return tw.Flush()
+}
+
+// ListZoneRecords lists the records of every zone with --all-zones, or of each --domain-name
+// otherwise, querying the zones in parallel. Failures are reported per zone.
+func (cfcmd *CloudflareCommandUtils) ListZoneRecords(ctx context.Context, cmd *cli.Command, params cloudflare.ListDNSRecordsParams, limit int) ([]ZoneRecords, error) {
+	zones := []dnsprovider.Zone{}
+	if cmd.Bool("all-zones") {
+		infos, err := dnsprovider.ListZones(ctx, cfcmd.Provider)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range infos {
+			zones = append(zones, v.Zone)
+		}
+	} else {
+		for _, name := range cmd.StringSlice("domain-name") {
+			zone, err := cfcmd.Provider.ZoneForName(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving zone for %s: %w", name, err)
+			}
+			zones = append(zones, zone)
+		}
+	}
+
+	results := make([]ZoneRecords, len(zones))
+	runParallel(len(zones), dnsprovider.DefaultPageConcurrency, func(i int) {
+		zc := cfcmd.forZone(zones[i])
+		records, _ := zc.ListDNSRecordsLimit(params, limit)
+		results[i] = ZoneRecords{ZoneID: zones[i].ID, ZoneName: zones[i].Name, Records: records}
+		if zc.Error != nil {
+			results[i].Error = zc.Error.Error()
+		}
+	})
+	return results, nil
+}
+
+// PrintZoneRecordsTable prints the records of every zone in one table with a Zone column.
+func PrintZoneRecordsTable(zones []ZoneRecords) error {
+	var colorInt int32 = 97
+	tw := tabwriter.NewWriter(os.Stdout, 2, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%s\t%s\x1b[0m\n", colorInt, "Zone", "ID", "Name", "Content", "Type", "ModifiedOn", "Comment")
+	fmt.Fprintf(tw, "\x1b[1;%dm----\t--\t----\t-------\t----\t----------\t-------\x1b[0m\n", colorInt)
+	total := 0
+	for _, zone := range zones {
+		for _, v := range zone.Records {
+			switch v.Type {
+			case "A":
+				colorInt = int32(96)
+			case "CNAME":
+				colorInt = int32(92)
+			default:
+				colorInt = int32(97)
+			}
+			fmt.Fprintf(tw, "\x1b[1;%dm%s\t%s\t%s\t%s\t%s\t%s\t%s\x1b[0m\n", colorInt, zone.ZoneName, v.ID, v.Name, v.Content, v.Type, pretty.DateTimeSting(v.ModifiedOn), v.Comment)
+		}
+		total += len(zone.Records)
+	}
+	err := tw.Flush()
+	fmt.Printf("\x1b[1;%dm\nFound %d records in %d zones\x1b[0m\n", colorInt, total, len(zones))
+	return err
+}
+
+func zonesCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:                  "zones",
+		Version:               versionNumber,
+		Aliases:               []string{"list-zones"},
+		Authors:               cfDnsComandAuthors(),
+		Category:              "dns",
+		Usage:                 "List every zone the credentials can reach with status, plan, nameservers and record count.",
+		EnableShellCompletion: true,
+		Action: func(ctx context.Context, cmd *cli.Command) (err error) {
+			cfcmd := dnsCommandForDomain(cmd, "")
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+			zones := cfcmd.ListZones(ctx)
+			if cfcmd.Error != nil {
+				logger.Error(cfcmd.Error.Error())
+				return cfcmd.Error
+			}
+
+			if os.Getenv("SQLITE_DB_PATH") != "" {
+				cfcmd.InitializeDatabaseConnection()
+				defer cfcmd.DbConn.Close()
+				for _, v := range zones {
+					cfcmd.forZone(v.Zone).CreateZoneInDb()
+				}
+			} else {
+				logger.Info("SQLITE_DB_PATH is not set, zones not stored in the inventory")
+			}
+
+			if cmd.Bool("print-json") {
+				cfcmd.PrintCommandResultAsJson(zones)
+				return cfcmd.Error
+			}
+			return PrintZonesTable(zones)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/babbage88/go-acme-cli/cloud_providers/dnsprovider"
+	"github.com/babbage88/go-acme-cli/database/infracli_db"
+)
+
+// zoneListTestProvider is a testProvider that lists its zone with two nameservers.
+type zoneListTestProvider struct {
+	*testProvider
+}
+
+func (p *zoneListTestProvider) ListZones(ctx context.Context) ([]dnsprovider.ZoneInfo, error) {
+	return []dnsprovider.ZoneInfo{{Zone: p.zone, Status: "active", Plan: "Free", NameServers: []string{"ns1.example.net", "ns2.example.net"}}}, nil
+}
+
+// captureStdout returns what fn prints to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestListZones(t *testing.T) {
+	p := &zoneListTestProvider{newTestProvider("example.com",
+		dnsprovider.Record{Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300},
+		dnsprovider.Record{Name: "api", Type: "A", Content: "192.0.2.2", TTL: 300},
+		dnsprovider.Record{Name: "@", Type: "MX", Content: "mail.example.com", TTL: 300},
+	)}
+	cfcmd := &CloudflareCommandUtils{Provider: p}
+	zones := cfcmd.ListZones(context.Background())
+	if cfcmd.Error != nil {
+		t.Fatalf("ListZones: %v", cfcmd.Error)
+	}
+	if len(zones) != 1 || zones[0].RecordCount != 3 || len(zones[0].NameServers) != 2 {
+		t.Fatalf("expected example.com with 3 records and 2 nameservers, got %+v", zones)
+	}
+
+	out := captureStdout(t, func() { PrintZonesTable(zones) })
+	var row string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "example.com") {
+			row = line
+		}
+	}
+	fields := strings.Fields(strings.TrimSuffix(row, "\x1b[0m"))
+	if len(fields) < 2 || !strings.Contains(row, "ns1.example.net, ns2.example.net") || fields[len(fields)-1] != "3" {
+		t.Fatalf("expected the nameservers and record count columns, got\n%s", out)
+	}
+
+	cfcmd.DbConn = newTestDb(t)
+	for range 2 {
+		for _, v := range zones {
+			cfcmd.forZone(v.Zone).CreateZoneInDb()
+		}
+	}
+	stored, err := infracli_db.New(cfcmd.DbConn).GetZonesFromDb(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].ZoneUid != "zone1" || stored[0].DomainName != "example.com" {
+		t.Fatalf("expected the zone stored once, got %+v", stored)
+	}
+}
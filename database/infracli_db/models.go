@@ -18,6 +18,7 @@ type DnsRecord struct {
 	Ttl       int64
 	Created   sql.NullString
 	Modified  sql.NullString
+	Priority  sql.NullInt64
 }
 
 type DnsZone struct {
@@ -26,6 +27,19 @@ type DnsZone struct {
 	DomainName string
 }
 
+type RecordAuditLog struct {
+	ID         int64
+	Created    string
+	OsUser     string
+	ZoneUid    string
+	ZoneName   string
+	RecordUid  string
+	RecordName string
+	Action     string
+	BeforeJson sql.NullString
+	AfterJson  sql.NullString
+}
+
 type RecordComment struct {
 	ID       int64
 	RecordID int64
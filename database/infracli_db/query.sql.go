@@ -11,8 +11,8 @@ import (
 )
 
 const createDnsRecord = `-- name: CreateDnsRecord :one
-INSERT OR REPLACE INTO dns_records (record_uid, zone_uid, name, content, type_id, modified, created, ttl)
-VALUES(?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (record_uid) DO 
+INSERT OR REPLACE INTO dns_records (record_uid, zone_uid, name, content, type_id, modified, created, ttl, priority)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (record_uid) DO 
 UPDATE SET 
 zone_uid = excluded.zone_uid,
 name = excluded.name,
@@ -20,7 +20,8 @@ content = excluded.content,
 type_id = excluded.type_id,
 modified = excluded.modified,
 created = excluded.created,
-ttl = excluded.ttl
+ttl = excluded.ttl,
+priority = excluded.priority
 RETURNING id, record_uid
 `
 
@@ -33,6 +34,7 @@ type CreateDnsRecordParams struct {
 	Modified  sql.NullString
 	Created   sql.NullString
 	Ttl       int64
+	Priority  sql.NullInt64
 }
 
 type CreateDnsRecordRow struct {
@@ -50,6 +52,7 @@ func (q *Queries) CreateDnsRecord(ctx context.Context, arg CreateDnsRecordParams
 		arg.Modified,
 		arg.Created,
 		arg.Ttl,
+		arg.Priority,
 	)
 	var i CreateDnsRecordRow
 	err := row.Scan(&i.ID, &i.RecordUid)
@@ -71,6 +74,36 @@ func (q *Queries) CreateDnsZone(ctx context.Context, arg CreateDnsZoneParams) er
 	return err
 }
 
+const createRecordAuditLog = `-- name: CreateRecordAuditLog :exec
+INSERT INTO record_audit_log (os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateRecordAuditLogParams struct {
+	OsUser     string
+	ZoneUid    string
+	ZoneName   string
+	RecordUid  string
+	RecordName string
+	Action     string
+	BeforeJson sql.NullString
+	AfterJson  sql.NullString
+}
+
+func (q *Queries) CreateRecordAuditLog(ctx context.Context, arg CreateRecordAuditLogParams) error {
+	_, err := q.db.ExecContext(ctx, createRecordAuditLog,
+		arg.OsUser,
+		arg.ZoneUid,
+		arg.ZoneName,
+		arg.RecordUid,
+		arg.RecordName,
+		arg.Action,
+		arg.BeforeJson,
+		arg.AfterJson,
+	)
+	return err
+}
+
 const createRecordComment = `-- name: CreateRecordComment :exec
 INSERT OR REPLACE INTO record_comments (record_id, comment) VALUES(?, ?)
 `
@@ -117,6 +150,180 @@ func (q *Queries) DeleteRecordByZoneId(ctx context.Context, zoneUid string) erro
 	return err
 }
 
+const deleteRecordCommentsByZoneUid = `-- name: DeleteRecordCommentsByZoneUid :exec
+DELETE FROM record_comments WHERE record_id IN (SELECT id FROM dns_records WHERE zone_uid = ?)
+`
+
+func (q *Queries) DeleteRecordCommentsByZoneUid(ctx context.Context, zoneUid string) error {
+	_, err := q.db.ExecContext(ctx, deleteRecordCommentsByZoneUid, zoneUid)
+	return err
+}
+
+const deleteRecordTagsByZoneUid = `-- name: DeleteRecordTagsByZoneUid :exec
+DELETE FROM record_tags WHERE record_id IN (SELECT id FROM dns_records WHERE zone_uid = ?)
+`
+
+func (q *Queries) DeleteRecordTagsByZoneUid(ctx context.Context, zoneUid string) error {
+	_, err := q.db.ExecContext(ctx, deleteRecordTagsByZoneUid, zoneUid)
+	return err
+}
+
+const getDnsRecordsByZoneUid = `-- name: GetDnsRecordsByZoneUid :many
+SELECT
+    r.record_uid,
+    r.name,
+    r.content,
+    r.ttl,
+    r.priority,
+    rt.record_type,
+    c.comment,
+    t.tags
+FROM dns_records r
+JOIN record_types rt ON r.type_id = rt.id
+LEFT JOIN record_comments c ON r.id = c.record_id
+LEFT JOIN record_tags t ON r.id = t.record_id
+WHERE r.zone_uid = ?
+ORDER BY r.name, rt.record_type
+`
+
+type GetDnsRecordsByZoneUidRow struct {
+	RecordUid  string
+	Name       string
+	Content    sql.NullString
+	Ttl        int64
+	Priority   sql.NullInt64
+	RecordType string
+	Comment    sql.NullString
+	Tags       sql.NullString
+}
+
+func (q *Queries) GetDnsRecordsByZoneUid(ctx context.Context, zoneUid string) ([]GetDnsRecordsByZoneUidRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDnsRecordsByZoneUid, zoneUid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDnsRecordsByZoneUidRow
+	for rows.Next() {
+		var i GetDnsRecordsByZoneUidRow
+		if err := rows.Scan(
+			&i.RecordUid,
+			&i.Name,
+			&i.Content,
+			&i.Ttl,
+			&i.Priority,
+			&i.RecordType,
+			&i.Comment,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecordAuditLogByRecordName = `-- name: GetRecordAuditLogByRecordName :many
+SELECT id, created, os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json
+FROM record_audit_log
+WHERE zone_uid = ? AND record_name = ?
+ORDER BY id DESC
+LIMIT ?
+`
+
+type GetRecordAuditLogByRecordNameParams struct {
+	ZoneUid    string
+	RecordName string
+	Limit      int64
+}
+
+func (q *Queries) GetRecordAuditLogByRecordName(ctx context.Context, arg GetRecordAuditLogByRecordNameParams) ([]RecordAuditLog, error) {
+	rows, err := q.db.QueryContext(ctx, getRecordAuditLogByRecordName, arg.ZoneUid, arg.RecordName, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RecordAuditLog
+	for rows.Next() {
+		var i RecordAuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.Created,
+			&i.OsUser,
+			&i.ZoneUid,
+			&i.ZoneName,
+			&i.RecordUid,
+			&i.RecordName,
+			&i.Action,
+			&i.BeforeJson,
+			&i.AfterJson,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecordAuditLogByZoneUid = `-- name: GetRecordAuditLogByZoneUid :many
+SELECT id, created, os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json
+FROM record_audit_log
+WHERE zone_uid = ?
+ORDER BY id DESC
+LIMIT ?
+`
+
+type GetRecordAuditLogByZoneUidParams struct {
+	ZoneUid string
+	Limit   int64
+}
+
+func (q *Queries) GetRecordAuditLogByZoneUid(ctx context.Context, arg GetRecordAuditLogByZoneUidParams) ([]RecordAuditLog, error) {
+	rows, err := q.db.QueryContext(ctx, getRecordAuditLogByZoneUid, arg.ZoneUid, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RecordAuditLog
+	for rows.Next() {
+		var i RecordAuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.Created,
+			&i.OsUser,
+			&i.ZoneUid,
+			&i.ZoneName,
+			&i.RecordUid,
+			&i.RecordName,
+			&i.Action,
+			&i.BeforeJson,
+			&i.AfterJson,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRecordIdByRecordUid = `-- name: GetRecordIdByRecordUid :one
 SELECT id FROM dns_records WHERE record_uid = ? LIMIT 1
 `
@@ -242,7 +449,7 @@ SET name = ?,
     ttl = ?,
     modified = datetime()
 WHERE record_uid = ?
-RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified
+RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified, priority
 `
 
 type UpdateDnsRecordByRecordUidParams struct {
@@ -272,6 +479,7 @@ func (q *Queries) UpdateDnsRecordByRecordUid(ctx context.Context, arg UpdateDnsR
 		&i.Ttl,
 		&i.Created,
 		&i.Modified,
+		&i.Priority,
 	)
 	return i, err
 }
@@ -281,7 +489,7 @@ UPDATE dns_records
 SET content = ?,
     modified = datetime()
 WHERE record_uid = ?
-RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified
+RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified, priority
 `
 
 type UpdateDnsRecordContentByRecordUidParams struct {
@@ -302,6 +510,7 @@ func (q *Queries) UpdateDnsRecordContentByRecordUid(ctx context.Context, arg Upd
 		&i.Ttl,
 		&i.Created,
 		&i.Modified,
+		&i.Priority,
 	)
 	return i, err
 }
@@ -311,7 +520,7 @@ UPDATE dns_records
 SET name = ?,
     modified = datetime()
 WHERE record_uid = ?
-RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified
+RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified, priority
 `
 
 type UpdateDnsRecordNameByRecordUidParams struct {
@@ -332,6 +541,7 @@ func (q *Queries) UpdateDnsRecordNameByRecordUid(ctx context.Context, arg Update
 		&i.Ttl,
 		&i.Created,
 		&i.Modified,
+		&i.Priority,
 	)
 	return i, err
 }
@@ -341,7 +551,7 @@ UPDATE dns_records
 SET ttl = ?,
     modified = datetime()
 WHERE record_uid = ?
-RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified
+RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified, priority
 `
 
 type UpdateDnsRecordTtlByRecordUidParams struct {
@@ -362,6 +572,7 @@ func (q *Queries) UpdateDnsRecordTtlByRecordUid(ctx context.Context, arg UpdateD
 		&i.Ttl,
 		&i.Created,
 		&i.Modified,
+		&i.Priority,
 	)
 	return i, err
 }
@@ -371,7 +582,7 @@ UPDATE dns_records
 SET type_id = ?,
     modified = datetime()
 WHERE record_uid = ?
-RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified
+RETURNING id, record_uid, zone_uid, type_id, name, content, ttl, created, modified, priority
 `
 
 type UpdateDnsRecordTypeIdByRecordUidParams struct {
@@ -392,6 +603,7 @@ func (q *Queries) UpdateDnsRecordTypeIdByRecordUid(ctx context.Context, arg Upda
 		&i.Ttl,
 		&i.Created,
 		&i.Modified,
+		&i.Priority,
 	)
 	return i, err
 }
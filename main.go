@@ -14,5 +14,6 @@ func main() {
 	cmd := commands.CoreInfraCommand()
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
 		logger.Error(err.Error())
+		os.Exit(1)
 	}
 }
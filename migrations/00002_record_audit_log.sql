@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE record_audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created TEXT NOT NULL DEFAULT (datetime()),
+    os_user TEXT NOT NULL,
+    zone_uid TEXT NOT NULL,
+    zone_name TEXT NOT NULL,
+    record_uid TEXT NOT NULL,
+    record_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    before_json TEXT,
+    after_json TEXT
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX record_audit_log_zone_name ON record_audit_log (zone_uid, record_name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX record_audit_log_zone_name;
+DROP TABLE record_audit_log;
+-- +goose StatementEnd
@@ -11,6 +11,23 @@ SELECT id FROM dns_zones WHERE zone_uid = ? LIMIT 1;
 -- name: GetRecordIdByRecordUid :one
 SELECT id FROM dns_records WHERE record_uid = ? LIMIT 1;
 
+-- name: GetDnsRecordsByZoneUid :many
+SELECT
+    r.record_uid,
+    r.name,
+    r.content,
+    r.ttl,
+    r.priority,
+    rt.record_type,
+    c.comment,
+    t.tags
+FROM dns_records r
+JOIN record_types rt ON r.type_id = rt.id
+LEFT JOIN record_comments c ON r.id = c.record_id
+LEFT JOIN record_tags t ON r.id = t.record_id
+WHERE r.zone_uid = ?
+ORDER BY r.name, rt.record_type;
+
 -- name: GetRecordsByZoneId :many
 SELECT 
     r.id,
@@ -30,8 +47,8 @@ INSERT INTO dns_zones (zone_uid, domain_name) VALUES(?, ?)
 ON CONFLICT (zone_uid) DO UPDATE SET domain_name = excluded.domain_name;
 
 -- name: CreateDnsRecord :one
-INSERT OR REPLACE INTO dns_records (record_uid, zone_uid, name, content, type_id, modified, created, ttl)
-VALUES(?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (record_uid) DO 
+INSERT OR REPLACE INTO dns_records (record_uid, zone_uid, name, content, type_id, modified, created, ttl, priority)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (record_uid) DO 
 UPDATE SET 
 zone_uid = excluded.zone_uid,
 name = excluded.name,
@@ -39,7 +56,8 @@ content = excluded.content,
 type_id = excluded.type_id,
 modified = excluded.modified,
 created = excluded.created,
-ttl = excluded.ttl
+ttl = excluded.ttl,
+priority = excluded.priority
 RETURNING id, record_uid;
 
 -- name: CreateRecordComment :exec
@@ -48,6 +66,24 @@ INSERT OR REPLACE INTO record_comments (record_id, comment) VALUES(?, ?);
 -- name: CreateRecordTag :exec
 INSERT OR REPLACE INTO record_tags (record_id, tags) VALUES(?, ?);
 
+-- name: CreateRecordAuditLog :exec
+INSERT INTO record_audit_log (os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetRecordAuditLogByZoneUid :many
+SELECT id, created, os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json
+FROM record_audit_log
+WHERE zone_uid = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: GetRecordAuditLogByRecordName :many
+SELECT id, created, os_user, zone_uid, zone_name, record_uid, record_name, action, before_json, after_json
+FROM record_audit_log
+WHERE zone_uid = ? AND record_name = ?
+ORDER BY id DESC
+LIMIT ?;
+
 -- name: UpdateDnsRecordByRecordUid :one
 UPDATE dns_records
 SET name = ?,
@@ -91,3 +127,9 @@ DELETE FROM dns_records WHERE record_uid = ?;
 
 -- name: DeleteRecordByZoneId :exec
 DELETE FROM dns_records WHERE zone_uid = ?;
+
+-- name: DeleteRecordCommentsByZoneUid :exec
+DELETE FROM record_comments WHERE record_id IN (SELECT id FROM dns_records WHERE zone_uid = ?);
+
+-- name: DeleteRecordTagsByZoneUid :exec
+DELETE FROM record_tags WHERE record_id IN (SELECT id FROM dns_records WHERE zone_uid = ?);